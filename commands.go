@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Subcomandos de la línea de comandos. Sin subcomando se usa run, para que las invocaciones
+// anteriores (solo flags) sigan funcionando.
+const (
+	commandRun     = "run"
+	commandVerify  = "verify"
+	commandSweep   = "sweep"
+	commandCompare = "compare"
+)
+
+// splitCommand separa el subcomando de args. Si el primer argumento es una flag (o no hay
+// argumentos) el subcomando es run y args se devuelve sin cambios.
+func splitCommand(args []string) (string, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commandRun, args, nil
+	}
+	switch args[0] {
+	case commandRun, commandVerify, commandSweep, commandCompare:
+		return args[0], args[1:], nil
+	}
+	return "", nil, fmt.Errorf("subcomando desconocido %q (use %s, %s, %s o %s)", args[0], commandRun, commandVerify, commandSweep, commandCompare)
+}
+
+// newCommandFlagSet crea el conjunto de flags del subcomando name, cuya ayuda indica cómo
+// invocarlo. Parse devuelve los errores en lugar de terminar el proceso, para que el subcomando los
+// informe como los demás errores de configuración (-h devuelve flag.ErrHelp).
+func newCommandFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	operands := ""
+	if name == commandCompare {
+		operands = " <base.csv> <nuevo.csv>"
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "uso: %s %s [flags]%s\n", filepath.Base(os.Args[0]), name, operands)
+		fmt.Fprintf(fs.Output(), "subcomandos: %s (por defecto), %s, %s, %s\n", commandRun, commandVerify, commandSweep, commandCompare)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// exitCommandError termina el proceso por el error err de un subcomando: con código 0 si es
+// flag.ErrHelp (la ayuda de -h ya se imprimió) y, si no, con código 1 tras informarlo en stderr
+// precedido de prefix.
+func exitCommandError(prefix string, err error) {
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	fmt.Fprintf(os.Stderr, "%s: %v\n", prefix, err)
+	os.Exit(1)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		command string
+		rest    []string
+		wantErr bool
+	}{
+		{"no arguments", nil, commandRun, nil, false},
+		{"flags only", []string{"-runs", "3"}, commandRun, []string{"-runs", "3"}, false},
+		{"run", []string{"run", "-runs", "3"}, commandRun, []string{"-runs", "3"}, false},
+		{"verify", []string{"verify", "-nombre_archivo", "m.csv"}, commandVerify, []string{"-nombre_archivo", "m.csv"}, false},
+		{"sweep", []string{"sweep"}, commandSweep, []string{}, false},
+		{"compare", []string{"compare", "a.csv", "b.csv"}, commandCompare, []string{"a.csv", "b.csv"}, false},
+		{"unknown", []string{"bench", "-runs", "3"}, "", nil, true},
+	}
+	for _, tt := range tests {
+		command, rest, err := splitCommand(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if command != tt.command || strings.Join(rest, " ") != strings.Join(tt.rest, " ") {
+			t.Errorf("%s: got %q %q, want %q %q", tt.name, command, rest, tt.command, tt.rest)
+		}
+	}
+}
+
+func TestSweepCommandImpliesSweep(t *testing.T) {
+	cfg, err := parseFlags(commandSweep, []string{"-sizes", "10,20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Sweep || cfg.SweepSizes != "10,20" {
+		t.Errorf("sweep=%v sizes=%q, want true and 10,20", cfg.Sweep, cfg.SweepSizes)
+	}
+	cfg, err = parseFlags(commandRun, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Sweep {
+		t.Error("run set sweep without -sweep")
+	}
+}
+
+// TestSubcommandArgErrors ejecuta main en un subproceso para comprobar el código de salida y el
+// mensaje de cada subcomando ante argumentos inválidos, y que -h termina sin error.
+func TestSubcommandArgErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{"unknown subcommand", []string{"bench"}, 1, `subcomando desconocido "bench"`},
+		{"run unknown flag", []string{"-bogus"}, 1, "config error: flag provided but not defined: -bogus"},
+		{"run bad value", []string{"run", "-runs", "x"}, 1, "config error: invalid value"},
+		{"sweep unknown flag", []string{"sweep", "-bogus"}, 1, "config error: flag provided but not defined: -bogus"},
+		{"verify unknown flag", []string{"verify", "-bogus"}, 1, "verify error: flag provided but not defined: -bogus"},
+		{"compare unknown flag", []string{"compare", "-bogus"}, 1, "compare error: flag provided but not defined: -bogus"},
+		{"run help", []string{"-h"}, 0, "uso: "},
+		{"verify help", []string{"verify", "-h"}, 0, "uso: "},
+	}
+	for _, tt := range tests {
+		_, stderr, code := runMain(t, t.TempDir(), tt.args...)
+		if code != tt.code || !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%s: exit code %d, stderr %q; want %d and %q", tt.name, code, stderr, tt.code, tt.stderr)
+		}
+	}
+}
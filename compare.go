@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"tarea02/speculative"
+	"time"
+)
+
+// metricsAverages resume un CSV de métricas para compare: la duración total media de cada
+// estrategia y el speedup, tomados de la fila de resumen o recalculados a partir de sus filas.
+type metricsAverages struct {
+	Speculative time.Duration
+	Sequential  time.Duration
+	// HasSequential indica que el archivo tiene la estrategia secuencial (no usa -reference-ms).
+	HasSequential bool
+	Speedup       float64
+}
+
+// runCompare implementa el subcomando compare: lee dos CSV de métricas (la línea base y el nuevo)
+// e informa en w la variación de la duración media de cada estrategia y del speedup. Devuelve un
+// error si alguna duración media crece, o el speedup cae, más de -tolerance por ciento.
+func runCompare(args []string, w io.Writer) error {
+	fs := newCommandFlagSet(commandCompare)
+	tolerance := fs.Float64("tolerance", 5, "variación, en por ciento, a partir de la cual un aumento de una duración media o una caída del speedup se considera una regresión")
+	delimiter := fs.String("delimiter", ",", "separador de columnas de ambos CSV (por defecto, el del encabezado de cada uno)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case fs.NArg() != 2:
+		return errors.New("compare recibe dos archivos: la línea base y el nuevo")
+	case *tolerance < 0:
+		return errors.New("tolerance no puede ser negativo")
+	}
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "delimiter" })
+	base, err := readMetricsAverages(fs.Arg(0), *delimiter, explicit)
+	if err != nil {
+		return err
+	}
+	current, err := readMetricsAverages(fs.Arg(1), *delimiter, explicit)
+	if err != nil {
+		return err
+	}
+
+	regressions := 0
+	// compareValue informa la variación de un valor; lowerIsBetter indica si una subida empeora.
+	compareValue := func(label string, before, after float64, lowerIsBetter bool) {
+		if math.IsNaN(before) || math.IsNaN(after) {
+			fmt.Fprintf(w, "%s: %s -> %s\n", label, speculative.FormatSpeedup(before), speculative.FormatSpeedup(after))
+			return
+		}
+		change := (after - before) / before * 100
+		worse := change > *tolerance
+		if !lowerIsBetter {
+			worse = -change > *tolerance
+		}
+		mark := ""
+		if worse {
+			regressions++
+			mark = " (regresión)"
+		}
+		fmt.Fprintf(w, "%s: %.3f -> %.3f (%+.1f %%)%s\n", label, before, after, change, mark)
+	}
+	compareValue("Duración media especulativa (ms)", speculative.Milliseconds(base.Speculative), speculative.Milliseconds(current.Speculative), true)
+	if base.HasSequential && current.HasSequential {
+		compareValue("Duración media secuencial (ms)", speculative.Milliseconds(base.Sequential), speculative.Milliseconds(current.Sequential), true)
+	}
+	compareValue("Speedup", base.Speedup, current.Speedup, false)
+
+	if regressions > 0 {
+		return fmt.Errorf("%d valores empeoraron más de %g %%", regressions, *tolerance)
+	}
+	return nil
+}
+
+// readMetricsAverages lee el CSV de métricas path y toma las duraciones medias y el speedup de su
+// fila de resumen, que cubre todas las corridas aunque el archivo tenga solo algunas (-sample-rows)
+// o ninguna (-summary-only). Si no hay exactamente una fila de resumen (el archivo se truncó con
+// -max-output-bytes o acumula varias invocaciones con -append), promedia en cambio la duración total
+// de sus corridas de cada estrategia (cada corrida cuenta una vez, aunque tenga una fila por rama);
+// sin corridas secuenciales (-reference-ms) el speedup se calcula entonces con el reference_ms de
+// la fila de resumen. Salvo que explicit indique que delimiter se pasó en la línea de comandos, el
+// separador es el del encabezado "# {...}".
+func readMetricsAverages(path, delimiter string, explicit bool) (metricsAverages, error) {
+	content, err := speculative.ReadMetricsFile(path)
+	if err != nil {
+		return metricsAverages{}, err
+	}
+	if !explicit {
+		config, err := headerConfig(content)
+		if err != nil {
+			return metricsAverages{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if raw, ok := config["delimiter"]; ok {
+			if delimiter, err = configFlagValue(raw); err != nil {
+				return metricsAverages{}, fmt.Errorf("%s: encabezado: clave \"delimiter\": %w", path, err)
+			}
+		}
+	}
+	if speculative.CSVDelimiters[delimiter] == 0 {
+		return metricsAverages{}, fmt.Errorf(`%s: delimiter debe ser ",", ";", "|" o "tab"`, path)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comma = speculative.CSVDelimiters[delimiter]
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return metricsAverages{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return metricsAverages{}, fmt.Errorf("%s: el archivo está vacío", path)
+	}
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{"mode", "run", "total_duration_ms"} {
+		if _, ok := columns[name]; !ok {
+			return metricsAverages{}, fmt.Errorf("%s: falta la columna %s", path, name)
+		}
+	}
+
+	var averages metricsAverages
+	var specRuns, seqRuns []speculative.ExecutionRun
+	var summaries []string
+	reference := math.NaN()
+	// Con -append varias invocaciones repiten los números de corrida; la columna config las distingue.
+	seen := make(map[string]bool)
+	for _, record := range records[1:] {
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		mode := cell("mode")
+		if mode == "resumen" {
+			summaries = append(summaries, cell("total_duration_ms"))
+			if value, ok := summaryField(cell("total_duration_ms"), "reference_ms"); ok {
+				reference = value
+			}
+			continue
+		}
+		if mode != speculative.ModeSpeculative && mode != speculative.ModeSequential {
+			continue
+		}
+		key := mode + "/" + cell("run") + "/" + cell("config")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ms, err := strconv.ParseFloat(cell("total_duration_ms"), 64)
+		if err != nil {
+			return metricsAverages{}, fmt.Errorf("%s: %s %s: total_duration_ms inválido %q", path, mode, cell("run"), cell("total_duration_ms"))
+		}
+		run := speculative.ExecutionRun{Mode: mode, TotalDuration: time.Duration(ms * float64(time.Millisecond))}
+		if mode == speculative.ModeSpeculative {
+			specRuns = append(specRuns, run)
+		} else {
+			seqRuns = append(seqRuns, run)
+		}
+	}
+	if len(summaries) == 1 {
+		if summary, ok := summaryAverages(summaries[0]); ok {
+			return summary, nil
+		}
+	}
+	if len(specRuns) == 0 {
+		return metricsAverages{}, fmt.Errorf("%s: no tiene corridas especulativas ni una fila de resumen", path)
+	}
+
+	averages.Speculative = speculative.AverageDuration(specRuns)
+	averages.HasSequential = len(seqRuns) > 0
+	switch {
+	case len(seqRuns) > 0:
+		averages.Sequential = speculative.AverageDuration(seqRuns)
+		averages.Speedup = speculative.ComputeSpeedup(averages.Sequential, averages.Speculative)
+	case !math.IsNaN(reference):
+		averages.Speedup = speculative.ComputeSpeedup(time.Duration(reference*float64(time.Millisecond)), averages.Speculative)
+	default:
+		averages.Speedup = math.NaN()
+	}
+	return averages, nil
+}
+
+// summaryAverages lee las duraciones medias y el speedup de la celda total_duration_ms de la fila
+// de resumen. Un speedup n/a queda como NaN; ok es false si falta avg_speculative_ms o la duración
+// de referencia (avg_sequential_ms o reference_ms).
+func summaryAverages(cell string) (averages metricsAverages, ok bool) {
+	milliseconds := func(ms float64) time.Duration { return time.Duration(ms * float64(time.Millisecond)) }
+	spec, ok := summaryField(cell, "avg_speculative_ms")
+	if !ok {
+		return metricsAverages{}, false
+	}
+	averages.Speculative = milliseconds(spec)
+	if seq, ok := summaryField(cell, "avg_sequential_ms"); ok {
+		averages.Sequential = milliseconds(seq)
+		averages.HasSequential = true
+	} else if _, ok := summaryField(cell, "reference_ms"); !ok {
+		return metricsAverages{}, false
+	}
+	averages.Speedup = math.NaN()
+	if speedup, ok := summaryField(cell, "speedup"); ok {
+		averages.Speedup = speedup
+	}
+	return averages, true
+}
+
+// summaryField devuelve el valor numérico de la clave key en una celda "clave=valor;..." de la
+// fila de resumen.
+func summaryField(cell, key string) (float64, bool) {
+	for _, field := range strings.Split(cell, ";") {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok || name != key {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
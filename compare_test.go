@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const compareHeader = "mode,run,branch,total_duration_ms\n"
+
+// writeMetricsFixture escribe en un directorio temporal un CSV de métricas con content después del
+// encabezado de columnas que lee compare.
+func writeMetricsFixture(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(compareHeader+content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCompareSummaryOnly(t *testing.T) {
+	base := writeMetricsFixture(t, "base.csv",
+		"\nresumen,,version=dev,avg_speculative_ms=100.000;avg_sequential_ms=200.000;speedup=2.000;p50_speculative_ms=100.000\n")
+	averages, err := readMetricsAverages(base, ",", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if averages.Speculative != 100*time.Millisecond || averages.Sequential != 200*time.Millisecond ||
+		!averages.HasSequential || averages.Speedup != 2 {
+		t.Errorf("averages = %+v, want 100 ms, 200 ms and speedup 2", averages)
+	}
+
+	tests := []struct {
+		name    string
+		summary string
+		wantErr bool
+	}{
+		{"same", "avg_speculative_ms=101.000;avg_sequential_ms=200.000;speedup=1.980", false},
+		{"slower", "avg_speculative_ms=130.000;avg_sequential_ms=200.000;speedup=1.538", true},
+	}
+	for _, tt := range tests {
+		current := writeMetricsFixture(t, "current.csv", "\nresumen,,version=dev,"+tt.summary+"\n")
+		if err := runCompare([]string{base, current}, io.Discard); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCompareSummaryOverSampledRows(t *testing.T) {
+	// Con -sample-rows las filas son solo una parte de las corridas; el resumen cubre todas.
+	path := writeMetricsFixture(t, "sampled.csv", strings.Join([]string{
+		"especulativo,1,A,500.000",
+		"secuencial,1,A,500.000",
+		"",
+		"resumen,,version=dev,avg_speculative_ms=100.000;reference_ms=150.000;speedup=n/a",
+	}, "\n")+"\n")
+	averages, err := readMetricsAverages(path, ",", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if averages.Speculative != 100*time.Millisecond || averages.HasSequential || !math.IsNaN(averages.Speedup) {
+		t.Errorf("averages = %+v, want the summary's 100 ms, no sequential and an undefined speedup", averages)
+	}
+}
+
+func TestCompareRowsWithoutSummary(t *testing.T) {
+	path := writeMetricsFixture(t, "rows.csv", "especulativo,1,A,100.000\nespeculativo,2,A,300.000\nsecuencial,1,A,400.000\n")
+	averages, err := readMetricsAverages(path, ",", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if averages.Speculative != 200*time.Millisecond || averages.Sequential != 400*time.Millisecond || averages.Speedup != 2 {
+		t.Errorf("averages = %+v, want 200 ms, 400 ms and speedup 2", averages)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// applyConfigFile lee el archivo JSON de -config, cuyas claves son los nombres de las flags (las
+// mismas etiquetas json de Config), y asigna cada valor a su flag salvo que esa flag se haya
+// indicado explícitamente en la línea de comandos, que siempre tiene prioridad. Los valores pasan
+// por flag.Value.Set, de modo que se interpretan igual que en la línea de comandos.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	// Se recorren las claves en orden para que el primer error informado sea estable.
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// La clave config se ignora para poder reutilizar la salida de -validate como archivo.
+		if name == "config" {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: clave desconocida %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		value, err := configFlagValue(values[name])
+		if err != nil {
+			return fmt.Errorf("%s: clave %q: %w", path, name, err)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: clave %q: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+// configFlagValue convierte un valor JSON escalar en el texto que se pasaría a la flag: las cadenas
+// sin comillas y los números y booleanos tal como aparecen en el archivo.
+func configFlagValue(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "", errors.New("valor vacío")
+	}
+	switch raw[0] {
+	case '"':
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return "", err
+		}
+		return text, nil
+	case '{', '[', 'n':
+		return "", errors.New("se esperaba una cadena, un número o un booleano")
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"tarea02/speculative"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	file := writeConfigFile(t, `{"runs": 7, "n": 40, "format": "json", "quiet": true}`)
+	tests := []struct {
+		name   string
+		args   []string
+		runs   int
+		n      int
+		format string
+		quiet  bool
+	}{
+		{"file only", []string{"-config", file}, 7, 40, "json", true},
+		{"flags only", []string{"-runs", "3", "-n", "60"}, 3, 60, "csv", false},
+		{"flags override file", []string{"-runs", "2", "-config", file, "-format", "ndjson", "-quiet=false"}, 2, 40, "ndjson", false},
+	}
+	for _, tt := range tests {
+		cfg, err := parseFlags(commandRun, tt.args)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if cfg.Runs != tt.runs || cfg.MatrixSize != tt.n || cfg.Format != tt.format || cfg.Quiet != tt.quiet {
+			t.Errorf("%s: runs=%d n=%d format=%s quiet=%v, want runs=%d n=%d format=%s quiet=%v",
+				tt.name, cfg.Runs, cfg.MatrixSize, cfg.Format, cfg.Quiet, tt.runs, tt.n, tt.format, tt.quiet)
+		}
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"unknown key", `{"no-such-flag": 1}`},
+		{"nested value", `{"runs": [1]}`},
+		{"bad value", `{"runs": "many"}`},
+		{"not JSON", `runs = 3`},
+	}
+	for _, tt := range tests {
+		if _, err := parseFlags(commandRun, []string{"-config", writeConfigFile(t, tt.content)}); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestConfigFileFromValidateOutput(t *testing.T) {
+	cfg, err := validateArgs(t, "-validate", "-runs", "4", "-seed", "11", "-n", "40")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := speculative.PrintResolvedConfig(&out, cfg); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := parseFlags(commandRun, []string{"-config", writeConfigFile(t, out.String())})
+	if err != nil {
+		t.Fatalf("-validate output is not a valid -config file: %v\n%s", err, out.String())
+	}
+	if reloaded.Runs != 4 || reloaded.Seed != 11 || reloaded.MatrixSize != 40 || !reloaded.Validate {
+		t.Errorf("reloaded runs=%d seed=%d n=%d validate=%v, want 4, 11, 40 and true",
+			reloaded.Runs, reloaded.Seed, reloaded.MatrixSize, reloaded.Validate)
+	}
+}
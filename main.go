@@ -1,608 +1,503 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/csv"
-	"encoding/hex"
+	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"math"
 	"math/rand"
 	"os"
-	"strconv"
-	"strings"
+	"tarea02/speculative"
 	"time"
 )
 
-const (
-	branchA = "A"
-	branchB = "B"
-)
-
-var (
-	// ErrCancelled indica que la operación fue cancelada por el controlador principal.
-	ErrCancelled = errors.New("branch cancelled")
-)
-
-// Config reúne los parámetros controlables desde la línea de comandos.
-type Config struct {
-	MatrixSize    int
-	Threshold     int64
-	OutputFile    string
-	Runs          int
-	PowDifficulty int
-	PowData       string
-	PrimesLimit   int
-}
-
-// BranchOutput encapsula la información relevante producida por un trabajo.
-type BranchOutput struct {
-	Numeric int64
-	Detail  string
-}
-
-// BranchWork representa una carga de trabajo que puede reaccionar ante cancelaciones.
-type BranchWork func(cancel <-chan struct{}) (BranchOutput, error)
-
-// BranchResult almacena las métricas capturadas durante la ejecución de una rama.
-type BranchResult struct {
-	Name      string
-	Numeric   int64
-	Detail    string
-	Start     time.Time
-	End       time.Time
-	Duration  time.Duration
-	Cancelled bool
-	Err       error
-}
-
-// ExecutionRun agrega la información relevante de una simulación completa (una corrida).
-type ExecutionRun struct {
-	Mode              string
-	RunIndex          int
-	ConditionValue    int64
-	ConditionDuration time.Duration
-	Winner            string
-	TotalDuration     time.Duration
-	RunStart          time.Time
-	Branches          []BranchResult
-}
+// throttleMinT es el estadístico t a partir del cual una pendiente positiva se considera
+// significativa (aprox. 95 % de confianza para lotes medianos).
+const throttleMinT = 2.0
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
-
-	cfg := parseFlags()
-	if err := validateConfig(cfg); err != nil {
+	worker, args := speculative.ExtractBranchWorker(os.Args[1:])
+	command, args, err := splitCommand(args)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
 		os.Exit(1)
 	}
-
-	branchWorks := buildBranchWorkload(cfg)
-
-	specRuns := make([]ExecutionRun, 0, cfg.Runs)
-	for i := 1; i <= cfg.Runs; i++ {
-		run, err := runSpeculative(cfg, i, branchWorks)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "speculative run %d failed: %v\n", i, err)
-			os.Exit(1)
+	if command == commandVerify {
+		if err := runVerify(args, os.Stdout); err != nil {
+			exitCommandError("verify error", err)
 		}
-		specRuns = append(specRuns, run)
+		return
 	}
-
-	seqRuns := make([]ExecutionRun, 0, cfg.Runs)
-	for i := 1; i <= cfg.Runs; i++ {
-		run, err := runSequential(cfg, i, branchWorks)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "sequential run %d failed: %v\n", i, err)
-			os.Exit(1)
+	if command == commandCompare {
+		if err := runCompare(args, os.Stdout); err != nil {
+			exitCommandError("compare error", err)
 		}
-		seqRuns = append(seqRuns, run)
+		return
 	}
 
-	if err := writeMetrics(cfg.OutputFile, specRuns, seqRuns); err != nil {
-		fmt.Fprintf(os.Stderr, "failed writing metrics: %v\n", err)
-		os.Exit(1)
+	cfg, err := parseFlags(command, args)
+	if err != nil {
+		exitCommandError("config error", err)
 	}
-
-	avgSpec := averageDuration(specRuns)
-	avgSeq := averageDuration(seqRuns)
-	speedup := computeSpeedup(avgSeq, avgSpec)
-
-	fmt.Printf("Simulaciones completadas: %d (especulativo) + %d (secuencial)\n", len(specRuns), len(seqRuns))
-	fmt.Printf("Promedio especulativo: %s\n", formatDuration(avgSpec))
-	fmt.Printf("Promedio secuencial: %s\n", formatDuration(avgSeq))
-	fmt.Printf("Speedup estimado: %.3f\n", speedup)
-	fmt.Printf("Métricas almacenadas en: %s\n", cfg.OutputFile)
-}
-
-func parseFlags() Config {
-	matrixSize := flag.Int("n", 125, "dimensión de las matrices cuadradas para la traza del producto")
-	threshold := flag.Int64("umbral", 500000, "umbral para seleccionar la rama ganadora")
-	output := flag.String("nombre_archivo", "metricas.csv", "archivo de salida para registrar las métricas")
-	runs := flag.Int("runs", 30, "número de ejecuciones por estrategia")
-	difficulty := flag.Int("difficulty", 5, "dificultad utilizada en la simulación de Proof-of-Work")
-	data := flag.String("pow-data", "speculative", "dato base para el Proof-of-Work")
-	primesLimit := flag.Int("primes-limit", 500000, "valor máximo para la búsqueda de números primos")
-	flag.Parse()
-
-	return Config{
-		MatrixSize:    *matrixSize,
-		Threshold:     *threshold,
-		OutputFile:    *output,
-		Runs:          *runs,
-		PowDifficulty: *difficulty,
-		PowData:       *data,
-		PrimesLimit:   *primesLimit,
+	if cfg.ShowVersion {
+		fmt.Println(speculative.VersionLine())
+		return
 	}
-}
-
-func validateConfig(cfg Config) error {
-	switch {
-	case cfg.MatrixSize <= 0:
-		return errors.New("n debe ser mayor que cero")
-	case cfg.Runs <= 0:
-		return errors.New("runs debe ser mayor que cero")
-	case cfg.PowDifficulty <= 0:
-		return errors.New("difficulty debe ser mayor que cero")
-	case cfg.PrimesLimit <= 0:
-		return errors.New("primes-limit debe ser mayor que cero")
-	case strings.TrimSpace(cfg.OutputFile) == "":
-		return errors.New("nombre_archivo no puede estar vacío")
-	default:
-		return nil
+	if cfg.ListBranches {
+		if err := speculative.WriteBranchList(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-}
-
-func buildBranchWorkload(cfg Config) map[string]BranchWork {
-	return map[string]BranchWork{
-		branchA: func(cancel <-chan struct{}) (BranchOutput, error) {
-			hash, nonce, err := SimularProofOfWorkWithCancel(cancel, cfg.PowData, cfg.PowDifficulty)
-			if err != nil && !errors.Is(err, ErrCancelled) {
-				return BranchOutput{}, err
-			}
-			detail := fmt.Sprintf("hash=%s", hash)
-			return BranchOutput{
-				Numeric: int64(nonce),
-				Detail:  detail,
-			}, err
-		},
-		branchB: func(cancel <-chan struct{}) (BranchOutput, error) {
-			primes, err := EncontrarPrimosWithCancel(cancel, cfg.PrimesLimit)
-			if err != nil && !errors.Is(err, ErrCancelled) {
-				return BranchOutput{}, err
-			}
-			var detail string
-			if len(primes) > 0 {
-				detail = fmt.Sprintf("count=%d,last=%d", len(primes), primes[len(primes)-1])
-			} else {
-				detail = "count=0"
-			}
-			return BranchOutput{
-				Numeric: int64(len(primes)),
-				Detail:  detail,
-			}, err
-		},
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
 	}
-}
+	rand.Seed(cfg.Seed)
 
-func runSpeculative(cfg Config, runIndex int, works map[string]BranchWork) (ExecutionRun, error) {
-	workA, okA := works[branchA]
-	workB, okB := works[branchB]
-	if !okA || !okB {
-		return ExecutionRun{}, errors.New("las dos ramas A y B deben estar definidas")
+	if err := speculative.ValidateConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		os.Exit(1)
 	}
 
-	runStart := time.Now()
-	resultsCh := make(chan BranchResult, 2)
-
-	cancelA := make(chan struct{})
-	cancelB := make(chan struct{})
-
-	go executeBranchAsync(branchA, workA, cancelA, resultsCh)
-	go executeBranchAsync(branchB, workB, cancelB, resultsCh)
-
-	conditionStart := time.Now()
-	trace := int64(CalcularTrazaDeProductoDeMatrices(cfg.MatrixSize))
-	conditionDuration := time.Since(conditionStart)
-
-	winner := chooseBranch(trace, cfg.Threshold)
-	if winner == branchA {
-		close(cancelB)
-	} else {
-		close(cancelA)
+	if cfg.Validate {
+		if _, err := speculative.BuildBranchWorkload(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "workload error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := speculative.PrintResolvedConfig(os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	var branches []BranchResult
-	for len(branches) < 2 {
-		result := <-resultsCh
-		if result.Err != nil {
-			return ExecutionRun{}, fmt.Errorf("branch %s failed: %w", result.Name, result.Err)
+	if worker != "" {
+		if err := speculative.RunBranchWorker(cfg, worker, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "branch worker %s failed: %v\n", worker, err)
+			os.Exit(1)
 		}
-		branches = append(branches, result)
+		return
 	}
 
-	totalDuration := time.Since(runStart)
-
-	return ExecutionRun{
-		Mode:              "especulativo",
-		RunIndex:          runIndex,
-		ConditionValue:    trace,
-		ConditionDuration: conditionDuration,
-		Winner:            winner,
-		TotalDuration:     totalDuration,
-		RunStart:          runStart,
-		Branches:          branches,
-	}, nil
-}
-
-func runSequential(cfg Config, runIndex int, works map[string]BranchWork) (ExecutionRun, error) {
-	runStart := time.Now()
-
-	conditionStart := time.Now()
-	trace := int64(CalcularTrazaDeProductoDeMatrices(cfg.MatrixSize))
-	conditionDuration := time.Since(conditionStart)
-
-	winner := chooseBranch(trace, cfg.Threshold)
-	work, ok := works[winner]
-	if !ok {
-		return ExecutionRun{}, fmt.Errorf("no existe la rama %s", winner)
+	if err := startProfiling(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+		os.Exit(1)
 	}
+	defer stopProfiling()
 
-	result := executeBranchSync(winner, work)
-	if result.Err != nil {
-		return ExecutionRun{}, fmt.Errorf("branch %s failed: %w", result.Name, result.Err)
+	branches, err := speculative.BuildBranchWorkload(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "workload error: %v\n", err)
+		exit(1)
 	}
 
-	totalDuration := time.Since(runStart)
-
-	return ExecutionRun{
-		Mode:              "secuencial",
-		RunIndex:          runIndex,
-		ConditionValue:    trace,
-		ConditionDuration: conditionDuration,
-		Winner:            winner,
-		TotalDuration:     totalDuration,
-		RunStart:          runStart,
-		Branches:          []BranchResult{result},
-	}, nil
-}
-
-func executeBranchAsync(name string, work BranchWork, cancel <-chan struct{}, out chan<- BranchResult) {
-	start := time.Now()
-	output, err := work(cancel)
-	end := time.Now()
+	signals, _ := speculative.ParseStopSignals(cfg.StopSignals)
+	ctx, releaseSignals := watchStopSignals(context.Background(), signals)
+	defer releaseSignals()
 
-	result := BranchResult{
-		Name:     name,
-		Numeric:  output.Numeric,
-		Detail:   output.Detail,
-		Start:    start,
-		End:      end,
-		Duration: end.Sub(start),
-	}
-
-	switch {
-	case errors.Is(err, ErrCancelled):
-		result.Cancelled = true
-	case err != nil:
-		result.Err = err
+	if cfg.SelfCheck {
+		diffs, err := speculative.RunSelfCheck(ctx, cfg, branches)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "selfcheck error: %v\n", err)
+			exit(1)
+		}
+		for _, diff := range diffs {
+			fmt.Fprintf(os.Stderr, "selfcheck: %s\n", diff)
+		}
+		if len(diffs) > 0 {
+			fmt.Fprintf(os.Stderr, "selfcheck failed: %d differences between two passes with seed %d\n", len(diffs), cfg.Seed)
+			exit(1)
+		}
+		fmt.Fprintf(speculative.ConsoleOutput(cfg), "Autocomprobación superada: %d corridas calculadas dos veces con seed=%d dieron la misma condición, ganadora y resultados\n", cfg.Runs, cfg.Seed)
+		return
+	}
+
+	// Con las métricas en la salida estándar no hay directorio de resultados que describir.
+	output, toFile := speculative.FirstOutputFile(cfg)
+	if cfg.Manifest && (toFile || cfg.Sweep || cfg.DifficultySweep != "") {
+		switch {
+		case cfg.Sweep:
+			output = cfg.SweepFile
+		case cfg.DifficultySweep != "":
+			output = cfg.DifficultyFile
+		}
+		if err := writeManifest(output, cfg, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+			exit(1)
+		}
 	}
 
-	out <- result
-}
-
-func executeBranchSync(name string, work BranchWork) BranchResult {
-	start := time.Now()
-	output, err := work(nil)
-	end := time.Now()
-
-	result := BranchResult{
-		Name:     name,
-		Numeric:  output.Numeric,
-		Detail:   output.Detail,
-		Start:    start,
-		End:      end,
-		Duration: end.Sub(start),
+	if cfg.DumpMatrix != "" {
+		if err := speculative.WriteProductDump(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "failed writing product matrix: %v\n", err)
+			exit(1)
+		}
 	}
 
-	switch {
-	case errors.Is(err, ErrCancelled):
-		result.Cancelled = true
-	case err != nil:
-		result.Err = err
+	if cfg.Sweep {
+		if err := speculative.RunSweep(ctx, cfg, branches); errors.Is(err, speculative.ErrInterrupted) {
+			fmt.Fprintf(os.Stderr, "interrupted: completed sizes written to %s\n", cfg.SweepFile)
+			exit(1)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			exit(1)
+		}
+		return
 	}
 
-	return result
-}
-
-func writeMetrics(path string, specRuns, seqRuns []ExecutionRun) error {
-	if err := os.MkdirAll(directory(path), 0o755); err != nil {
-		return err
+	if cfg.DifficultySweep != "" {
+		if err := speculative.RunDifficultySweep(ctx, cfg); errors.Is(err, speculative.ErrInterrupted) {
+			fmt.Fprintf(os.Stderr, "interrupted: completed difficulties written to %s\n", cfg.DifficultyFile)
+			exit(1)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			exit(1)
+		}
+		return
 	}
 
-	file, err := os.Create(path)
+	// El CSV y el NDJSON se escriben a medida que terminan las corridas; el JSON, completo al
+	// final. Con SampleRows > 1 solo se escriben las corridas 1, 1+N, 1+2N, ..., pero el resumen se
+	// calcula siempre sobre la población completa.
+	engine := speculative.Engine{Branches: branches}
+	rows, err := speculative.NewMetricsWriter(cfg)
 	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	header := []string{
-		"mode",
-		"run",
-		"branch",
-		"was_winner",
-		"cancelled",
-		"result_numeric",
-		"result_detail",
-		"condition_value",
-		"condition_duration_ms",
-		"branch_start_ms",
-		"branch_end_ms",
-		"branch_duration_ms",
-		"total_duration_ms",
-		"error",
-	}
-	if err := writer.Write(header); err != nil {
-		return err
+		fmt.Fprintf(os.Stderr, "output error: %v\n", err)
+		exit(1)
+	}
+	defer rows.Close()
+	// El registro de decisiones también recibe cada corrida especulativa en cuanto termina.
+	var decisions *speculative.DecisionLog
+	if cfg.DecisionLog != "" {
+		if decisions, err = speculative.NewDecisionLog(cfg.DecisionLog); err != nil {
+			fmt.Fprintf(os.Stderr, "failed writing decision log: %v\n", err)
+			exit(1)
+		}
+		defer decisions.Close()
 	}
-
-	writeRun := func(run ExecutionRun) error {
-		for _, branch := range run.Branches {
-			startOffset := branch.Start.Sub(run.RunStart).Seconds() * 1000
-			endOffset := branch.End.Sub(run.RunStart).Seconds() * 1000
-			record := []string{
-				run.Mode,
-				strconv.Itoa(run.RunIndex),
-				branch.Name,
-				boolToString(branch.Name == run.Winner),
-				boolToString(branch.Cancelled),
-				strconv.FormatInt(branch.Numeric, 10),
-				branch.Detail,
-				strconv.FormatInt(run.ConditionValue, 10),
-				floatToString(run.ConditionDuration.Seconds() * 1000),
-				floatToString(startOffset),
-				floatToString(endOffset),
-				floatToString(branch.Duration.Seconds() * 1000),
-				floatToString(run.TotalDuration.Seconds() * 1000),
-				errorString(branch.Err),
-			}
-			if err := writer.Write(record); err != nil {
-				return err
+	engine.OnRun = func(run speculative.ExecutionRun) error {
+		if err := rows.WriteRun(run); err != nil {
+			return fmt.Errorf("failed writing metrics: %w", err)
+		}
+		if decisions != nil && run.Mode == speculative.ModeSpeculative {
+			if err := decisions.WriteRun(run); err != nil {
+				return fmt.Errorf("failed writing decision log: %w", err)
 			}
 		}
+		if cfg.Verbose {
+			speculative.PrintRunDetail(speculative.ConsoleOutput(cfg), run)
+		}
 		return nil
 	}
 
-	for _, run := range specRuns {
-		if err := writeRun(run); err != nil {
-			return err
-		}
+	// Al alcanzar -max-output-bytes sin -rotate el lote se detiene: las corridas completadas se
+	// resumen por consola y la advertencia de truncamiento indica cuántas se escribieron.
+	report, err := engine.RunContext(ctx, cfg)
+	if err != nil && !errors.Is(err, speculative.ErrInterrupted) && !errors.Is(err, speculative.ErrOutputLimit) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		exit(1)
+	}
+	specRuns, seqRuns, summary := report.Speculative, report.Sequential, report.Summary
+	err = rows.Finish(summary)
+	if errors.Is(err, speculative.ErrOutputLimit) {
+		warn(cfg, warnOutputTruncated,
+			fmt.Sprintf("metrics truncated at %d bytes; the batch stopped after %d speculative and %d sequential runs (see -rotate)",
+				cfg.MaxOutputBytes, len(specRuns), len(seqRuns)),
+			map[string]any{"max_output_bytes": cfg.MaxOutputBytes, "file": cfg.OutputFile, "speculative_runs": len(specRuns), "sequential_runs": len(seqRuns)})
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "failed writing metrics: %v\n", err)
+		exit(1)
 	}
-	for _, run := range seqRuns {
-		if err := writeRun(run); err != nil {
-			return err
+	if decisions != nil {
+		if err := decisions.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed writing decision log: %v\n", err)
+			exit(1)
 		}
 	}
-
-	writer.Flush()
-	if err := writer.Error(); err != nil {
-		return err
+	if cfg.SpeedupTrend > 0 {
+		if err := speculative.WriteSpeedupTrend(cfg, specRuns, seqRuns); err != nil {
+			fmt.Fprintf(os.Stderr, "failed writing speedup trend: %v\n", err)
+			exit(1)
+		}
 	}
 
-	avgSpec := averageDuration(specRuns)
-	avgSeq := averageDuration(seqRuns)
-	speedup := computeSpeedup(avgSeq, avgSpec)
-
-	if err := writer.Write([]string{}); err != nil {
-		return err
-	}
-	summary := []string{
-		"resumen",
-		"",
-		"",
-		"",
-		"",
-		fmt.Sprintf("avg_numeric_speculative=%.3f", averageNumeric(specRuns)),
-		fmt.Sprintf("avg_numeric_sequential=%.3f", averageNumeric(seqRuns)),
-		"",
-		"",
-		"",
-		"",
-		"",
-		fmt.Sprintf("avg_speculative_ms=%.3f;avg_sequential_ms=%.3f;speedup=%.3f",
-			avgSpec.Seconds()*1000,
-			avgSeq.Seconds()*1000,
-			speedup),
-		"",
-	}
-	if err := writer.Write(summary); err != nil {
-		return err
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "interrupted: %d speculative and %d sequential runs written to %s\n",
+			len(specRuns), len(seqRuns), cfg.OutputFile)
+		exit(1)
 	}
 
-	writer.Flush()
-	return writer.Error()
-}
-
-func chooseBranch(trace, threshold int64) string {
-	if trace >= threshold {
-		return branchA
+	stdout := speculative.ConsoleOutput(cfg)
+	fmt.Fprintf(stdout, "Simulaciones completadas: %d (especulativo) + %d (secuencial)\n", len(specRuns), len(seqRuns))
+	fmt.Fprintf(stdout, "Promedio especulativo: %s\n", speculative.FormatDuration(summary.AvgSpeculative))
+	if summary.BaselineIsReference {
+		fmt.Fprintf(stdout, "Referencia externa: %s\n", speculative.FormatDuration(summary.Baseline))
+	} else {
+		fmt.Fprintf(stdout, "Promedio secuencial: %s\n", speculative.FormatDuration(summary.Baseline))
 	}
-	return branchB
-}
-
-// SimularProofOfWork simula la búsqueda de un hash con prefijo de ceros, tal como se entrega en el anexo.
-func SimularProofOfWork(blockData string, dificultad int) (string, int) {
-	hash, nonce, _ := SimularProofOfWorkWithCancel(nil, blockData, dificultad)
-	return hash, nonce
-}
-
-// SimularProofOfWorkWithCancel es una variante que permite cancelación cooperativa.
-func SimularProofOfWorkWithCancel(cancel <-chan struct{}, blockData string, dificultad int) (string, int, error) {
-	targetPrefix := strings.Repeat("0", dificultad)
-	nonce := 0
-
-	for {
-		if cancel != nil {
-			select {
-			case <-cancel:
-				return "", 0, ErrCancelled
-			default:
-			}
-		}
-
-		data := fmt.Sprintf("%s%d", blockData, nonce)
-		hashBytes := sha256.Sum256([]byte(data))
-		hashString := hex.EncodeToString(hashBytes[:])
-
-		if strings.HasPrefix(hashString, targetPrefix) {
-			return hashString, nonce, nil
-		}
-		nonce++
-
-		if cancel != nil && nonce%1_000 == 0 {
-			select {
-			case <-cancel:
-				return "", 0, ErrCancelled
-			default:
-			}
-		}
+	fmt.Fprintf(stdout, "Percentiles especulativo: %s\n", speculative.FormatPercentiles(summary.PercentilesSpeculative))
+	if len(seqRuns) > 0 {
+		fmt.Fprintf(stdout, "Percentiles secuencial: %s\n", speculative.FormatPercentiles(summary.PercentilesSequential))
 	}
-}
-
-// EncontrarPrimos devuelve la lista de números primos hasta max, siguiendo el anexo.
-func EncontrarPrimos(max int) []int {
-	primes, _ := EncontrarPrimosWithCancel(nil, max)
-	return primes
-}
-
-// EncontrarPrimosWithCancel es una variante que añade soporte para cancelación cooperativa.
-func EncontrarPrimosWithCancel(cancel <-chan struct{}, max int) ([]int, error) {
-	if max < 2 {
-		return []int{}, nil
+	fmt.Fprintf(stdout, "Dispersión especulativo: %s\n", speculative.FormatDispersion(summary.DispersionSpeculative))
+	if len(seqRuns) > 0 {
+		fmt.Fprintf(stdout, "Dispersión secuencial: %s\n", speculative.FormatDispersion(summary.DispersionSequential))
 	}
-
-	primes := make([]int, 0, max/10)
-	for i := 2; i < max; i++ {
-		if cancel != nil {
-			select {
-			case <-cancel:
-				return nil, ErrCancelled
-			default:
-			}
-		}
-
-		isPrime := true
-		upper := int(math.Sqrt(float64(i)))
-		for j := 2; j <= upper; j++ {
-			if cancel != nil && j%1024 == 0 {
-				select {
-				case <-cancel:
-					return nil, ErrCancelled
-				default:
-				}
-			}
-			if i%j == 0 {
-				isPrime = false
-				break
-			}
-		}
-		if isPrime {
-			primes = append(primes, i)
-		}
+	if summary.HasCorrelation {
+		fmt.Fprintf(stdout, "Correlación de duraciones pareadas: r=%.3f (%d pares)\n", summary.DurationCorrelation, summary.CorrelationPairs)
 	}
-	return primes, nil
-}
-
-// CalcularTrazaDeProductoDeMatrices multiplica dos matrices NxN con valores aleatorios y devuelve la traza.
-func CalcularTrazaDeProductoDeMatrices(n int) int {
-	m1 := make([][]int, n)
-	m2 := make([][]int, n)
-	for i := 0; i < n; i++ {
-		m1[i] = make([]int, n)
-		m2[i] = make([]int, n)
-		for j := 0; j < n; j++ {
-			m1[i][j] = rand.Intn(10)
-			m2[i][j] = rand.Intn(10)
-		}
+	if summary.WinRatePairs > 0 {
+		fmt.Fprintf(stdout, "Corridas en que la especulativa fue más rápida: %.1f %% (%d pares)\n", summary.SpecWinRate*100, summary.WinRatePairs)
 	}
-
-	trace := 0
-	for i := 0; i < n; i++ {
-		sum := 0
-		for k := 0; k < n; k++ {
-			sum += m1[i][k] * m2[k][i]
-		}
-		trace += sum
+	fmt.Fprintf(stdout, "Ganadoras especulativo: %s\n", speculative.WinsFields(summary.WinsSpeculative, ""))
+	if len(seqRuns) > 0 {
+		fmt.Fprintf(stdout, "Ganadoras secuencial: %s\n", speculative.WinsFields(summary.WinsSequential, ""))
 	}
-	return trace
-}
-
-func averageDuration(runs []ExecutionRun) time.Duration {
-	if len(runs) == 0 {
-		return 0
+	fmt.Fprintf(stdout, "Speedup estimado: %s\n", speculative.FormatSpeedup(summary.Speedup))
+	fmt.Fprintf(stdout, "Trabajo descartado: %s (ahorro total frente a la línea base: %s)\n",
+		speculative.FormatDuration(summary.WastedWork), speculative.FormatDuration(summary.SpeculationBenefit))
+	if cfg.OutputFile == speculative.StdoutPath {
+		fmt.Fprintln(stdout, "Métricas escritas en la salida estándar")
+	} else {
+		fmt.Fprintf(stdout, "Métricas almacenadas en: %s\n", cfg.OutputFile)
 	}
-	var total time.Duration
-	for _, run := range runs {
-		total += run.TotalDuration
+	if cfg.DumpMatrix != "" {
+		fmt.Fprintf(stdout, "Matriz producto de la corrida 1 almacenada en: %s\n", cfg.DumpMatrix)
 	}
-	return total / time.Duration(len(runs))
-}
 
-func averageNumeric(runs []ExecutionRun) float64 {
-	if len(runs) == 0 {
-		return 0
-	}
-	var total float64
-	var count int
-	for _, run := range runs {
-		for _, branch := range run.Branches {
-			total += float64(branch.Numeric)
-			count++
+	if summary.WastedWork > 0 && summary.WastedWork > summary.SpeculationBenefit {
+		message := fmt.Sprintf("cancelled branches used %s, more than the %s saved by speculation",
+			speculative.FormatDuration(summary.WastedWork), speculative.FormatDuration(summary.SpeculationBenefit))
+		if summary.SpeculationBenefit <= 0 {
+			message = fmt.Sprintf("speculation was slower than the baseline by %s; cancelled branches used %s",
+				speculative.FormatDuration(-summary.SpeculationBenefit), speculative.FormatDuration(summary.WastedWork))
 		}
+		warn(cfg, warnWastedWork, message,
+			map[string]any{"wasted_work_ms": speculative.Milliseconds(summary.WastedWork), "benefit_ms": speculative.Milliseconds(summary.SpeculationBenefit)})
 	}
-	if count == 0 {
-		return 0
+	if timedOut := speculative.TimedOutWinners(specRuns) + speculative.TimedOutWinners(seqRuns); timedOut > 0 {
+		warn(cfg, warnBranchTimeout,
+			fmt.Sprintf("%d winning branches hit -branch-timeout %s; their durations are capped and their results incomplete", timedOut, cfg.BranchTimeout),
+			map[string]any{"runs": timedOut, "branch_timeout_ms": speculative.Milliseconds(cfg.BranchTimeout)})
 	}
-	return total / float64(count)
-}
-
-func computeSpeedup(sequential, speculative time.Duration) float64 {
-	if speculative <= 0 {
-		return 0
+	if cfg.DetectThrottle {
+		reportThrottle(cfg, speculative.ModeSpeculative, specRuns)
+		reportThrottle(cfg, speculative.ModeSequential, seqRuns)
+	}
+	if err := checkMinSpeedup(cfg, summary); err != nil {
+		fmt.Fprintf(os.Stderr, "speedup check failed: %v\n", err)
+		exit(1)
 	}
-	return sequential.Seconds() / speculative.Seconds()
-}
-
-func formatDuration(d time.Duration) string {
-	return fmt.Sprintf("%.3f ms", d.Seconds()*1000)
 }
 
-func directory(path string) string {
-	lastSep := strings.LastIndex(path, string(os.PathSeparator))
-	if lastSep == -1 {
-		return "."
+// checkMinSpeedup implementa -min-speedup: devuelve un error si el speedup de summary es menor que
+// cfg.MinSpeedup o no está definido (ver ComputeSpeedup). Con MinSpeedup 0 no controla nada.
+func checkMinSpeedup(cfg speculative.Config, summary speculative.Summary) error {
+	switch {
+	case cfg.MinSpeedup <= 0:
+		return nil
+	case math.IsNaN(summary.Speedup):
+		return fmt.Errorf("speedup is undefined, so -min-speedup %g cannot be satisfied", cfg.MinSpeedup)
+	case summary.Speedup < cfg.MinSpeedup:
+		return fmt.Errorf("speedup %s is below -min-speedup %g", speculative.FormatSpeedup(summary.Speedup), cfg.MinSpeedup)
 	}
-	return path[:lastSep]
+	return nil
 }
 
-func boolToString(value bool) string {
-	if value {
-		return "true"
+// reportThrottle imprime la pendiente de las duraciones de un modo y emite una advertencia cuando
+// es positiva y significativa (estadístico t >= throttleMinT), lo que sugiere que las corridas se
+// fueron haciendo más lentas durante el lote.
+func reportThrottle(cfg speculative.Config, mode string, runs []speculative.ExecutionRun) {
+	slope, t, ok := speculative.DurationTrend(runs)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(speculative.ConsoleOutput(cfg), "Tendencia %s: %.4f ms/corrida (t=%.2f)\n", mode, slope, t)
+	if slope > 0 && t >= throttleMinT {
+		warn(cfg, warnThermalThrottling,
+			fmt.Sprintf("%s runs slow down by %.4f ms per run (t=%.2f); possible thermal throttling", mode, slope, t),
+			map[string]any{"mode": mode, "slope_ms_per_run": slope, "t": t})
 	}
-	return "false"
 }
 
-func floatToString(value float64) string {
-	return fmt.Sprintf("%.3f", value)
-}
+// parseFlags interpreta las flags del subcomando command (run o sweep, que comparten las flags y
+// solo difieren en que sweep implica -sweep). Con -config, los valores del archivo se aplican a
+// las flags que no se indicaron explícitamente, antes de armar la configuración.
+func parseFlags(command string, args []string) (speculative.Config, error) {
+	fs := newCommandFlagSet(command)
+	defaults := speculative.DefaultConfig()
+	matrixSize := fs.Int("n", defaults.MatrixSize, "dimensión de las matrices cuadradas para la traza del producto")
+	threshold := fs.Int64("umbral", defaults.Threshold, "umbral para seleccionar la rama ganadora")
+	output := fs.String("nombre_archivo", defaults.OutputFile, "archivo de salida para registrar las métricas")
+	runs := fs.Int("runs", defaults.Runs, "número de ejecuciones por estrategia")
+	difficulty := fs.Int("difficulty", defaults.PowDifficulty, "dificultad utilizada en la simulación de Proof-of-Work")
+	data := fs.String("pow-data", defaults.PowData, "dato base para el Proof-of-Work")
+	primesLimit := fs.Int("primes-limit", defaults.PrimesLimit, "valor máximo para la búsqueda de números primos")
+	stopSignals := fs.String("stop-signals", defaults.StopSignals, "señales (separadas por comas) que detienen la ejecución guardando las métricas parciales")
+	sampleRows := fs.Int("sample-rows", defaults.SampleRows, "escribe solo una de cada N corridas en el archivo (el resumen usa todas)")
+	workloadSpec := fs.String("workload-spec", "", "archivo JSON que define las ramas y sus parámetros (reemplaza las ramas por defecto)")
+	referenceMs := fs.Float64("reference-ms", 0, "duración de referencia externa (ms) para el speedup; si es mayor que cero se omite la estrategia secuencial")
+	format := fs.String("format", defaults.Format, "formato del archivo de métricas: csv, json o ndjson (un objeto JSON por corrida y por línea, escrito al terminar cada una)")
+	jsonFlat := fs.Bool("json-flat", false, "con -format json, escribe las corridas en un único arreglo runs en lugar de agruparlas por modo; config y summary no cambian")
+	primesBits := fs.Int("primes-bits", 0, "si es mayor que cero, la rama B busca los primos de exactamente esa cantidad de bits en lugar de usar primes-limit")
+	decisionLog := fs.String("decision-log", "", "archivo al que se agrega timestamp,run,winner,condition_value por cada corrida especulativa")
+	primesAlgo := fs.String("primes-algo", defaults.PrimesAlgo, "algoritmo de la rama B: trial (división sucesiva), sieve (criba de Eratóstenes), sieve-parallel (criba concurrente) o segmented (criba segmentada con memoria acotada)")
+	primesSegment := fs.Int("primes-segment", defaults.PrimesSegment, "tamaño de cada bloque de la criba con primes-algo segmented")
+	primesWorkers := fs.Int("primes-workers", defaults.PrimesWorkers, "goroutines que marcan la criba con primes-algo sieve-parallel")
+	detectThrottle := fs.Bool("detect-throttle", false, "ajusta una tendencia lineal a las duraciones por corrida y advierte si crecen (posible throttling térmico)")
+	isolation := fs.String("branch-isolation", defaults.BranchIsolation, "cómo se aíslan las ramas: goroutine o process (un subproceso por rama)")
+	seed := fs.Int64("seed", 0, "semilla para generar las matrices; 0 usa una semilla basada en la hora")
+	primesStats := fs.Bool("primes-stats", false, "la rama B informa además los pares de primos gemelos y la mayor brecha entre primos consecutivos (twins=...;maxgap=... en result_detail); no admite primes-algo segmented")
+	allocPerPrime := fs.Bool("alloc-per-prime", false, "mide los bytes asignados por primo encontrado en la rama B (columna alloc_per_prime)")
+	shadowLosers := fs.Bool("shadow-losers", false, "tras cada corrida especulativa ejecuta hasta el final las ramas canceladas (columnas shadow_numeric y shadow_detail)")
+	maxOutputBytes := fs.Int64("max-output-bytes", 0, "tamaño máximo (bytes) del archivo CSV; 0 no lo limita")
+	rotate := fs.Bool("rotate", false, "con max-output-bytes, continúa en archivos numerados en lugar de truncar")
+	powMode := fs.String("pow-mode", defaults.PowMode, "cómo se interpreta difficulty: hex (ceros hexadecimales iniciales), bits (bits en cero iniciales) o target (hash menor que (2^bits - 1) / difficulty)")
+	maxNonce := fs.Int("max-nonce", 0, "último nonce que prueba el Proof-of-Work antes de rendirse; 0 no lo limita")
+	speedupTrend := fs.Int("speedup-trend", 0, "si es mayor que cero, escribe el speedup acumulado cada K corridas en speedup-trend-file")
+	trendFile := fs.String("speedup-trend-file", defaults.TrendFile, "archivo CSV runs_so_far,speedup generado con speedup-trend")
+	policy := fs.String("policy", defaults.Policy, "regla para elegir la rama ganadora: threshold (traza >= umbral), multi (votación entre traza, signo del determinante y suma de elementos) o parity (A si la traza es par)")
+	warningsJSON := fs.Bool("warnings-json", false, "emite las advertencias en stderr como objetos JSON (uno por línea) con code, message y fields")
+	powHash := fs.String("pow-hash", defaults.PowHash, "función de hash del Proof-of-Work: sha1, sha256 o sha512")
+	powWorkers := fs.Int("pow-workers", defaults.PowWorkers, "goroutines que reparten la búsqueda de nonces del Proof-of-Work (1 = secuencial)")
+	warmup := fs.Int("warmup", 0, "corridas de calentamiento por estrategia que se ejecutan antes de las medidas y se descartan")
+	appendOutput := fs.Bool("append", false, "agrega las corridas al final del CSV existente (con una columna config inicial) en lugar de sobrescribirlo")
+	listBranches := fs.Bool("list-branches", false, "imprime el nombre y la descripción de cada rama registrada (las que acepta -branches) y termina")
+	showVersion := fs.Bool("version", false, "imprime la versión, la versión de Go y la revisión del binario y termina")
+	validate := fs.Bool("validate", false, "solo valida la configuración, imprime la configuración resuelta y termina sin ejecutar corridas")
+	progress := fs.Bool("progress", false, "imprime en stderr \"run i/N (modo)\" y el tiempo restante estimado al terminar cada corrida")
+	sweep := fs.Bool("sweep", false, "ejecuta la comparación completa para cada tamaño de sizes y escribe una fila por tamaño en sweep-file, en lugar del archivo de métricas")
+	sweepSizes := fs.String("sizes", defaults.SweepSizes, "dimensiones de matriz (separadas por comas) que recorre -sweep")
+	sweepFile := fs.String("sweep-file", defaults.SweepFile, "archivo CSV n,avg_spec_ms,avg_seq_ms,speedup generado con -sweep")
+	difficultySweep := fs.String("difficulty-sweep", "", "dificultades (separadas por comas) para las que se ejecuta la comparación completa, escribiendo una fila por dificultad en difficulty-sweep-file en lugar del archivo de métricas")
+	difficultySweepFile := fs.String("difficulty-sweep-file", defaults.DifficultyFile, "archivo CSV difficulty,avg_spec_ms,avg_seq_ms,speedup,avg_nonce generado con -difficulty-sweep")
+	selfCheck := fs.Bool("selfcheck", false, "en lugar de medir, calcula dos veces la condición y el resultado de todas las ramas de cada corrida y termina con error si difieren (comprueba que la semilla hace reproducible la ejecución)")
+	interleave := fs.Bool("interleave", false, "alterna las corridas especulativas y secuenciales (especulativa 1, secuencial 1, ...) en lugar de ejecutar todas las de una estrategia primero")
+	quiet := fs.Bool("quiet", false, "no imprime el resumen en stdout (el archivo de métricas se escribe igual)")
+	verbose := fs.Bool("verbose", false, "imprime en stdout el modo, la ganadora, la condición y las duraciones de cada corrida al terminar")
+	primesLow := fs.Int("primes-low", defaults.PrimesLow, "cota inferior de la búsqueda de la rama B, que recorre [primes-low, primes-limit) (solo con primes-algo trial)")
+	cpuProfile := fs.String("cpuprofile", "", "escribe en este archivo el perfil de CPU (runtime/pprof) de toda la ejecución")
+	memProfile := fs.String("memprofile", "", "escribe en este archivo el perfil del heap (runtime/pprof) al terminar")
+	branchNames := fs.String("branches", defaults.Branches, "ramas registradas que participan, separadas por comas y en el orden en que se lanzan; deben incluir A y B (se ignora con workload-spec)")
+	parallelRuns := fs.Int("parallel-runs", defaults.ParallelRuns, "corridas medidas que se ejecutan a la vez; compiten por la CPU y distorsionan los tiempos de cada corrida, así que solo sirve para reunir estadísticas rápido")
+	symmetric := fs.Bool("symmetric", false, "la condición es la traza de A·Aᵀ, la suma de los cuadrados de una única matriz aleatoria, en lugar de la de dos matrices distintas (solo con policy threshold o parity)")
+	minSpeedup := fs.Float64("min-speedup", 0, "si es mayor que 0, el programa termina con código de salida 1 cuando el speedup estimado es menor que este valor o no está definido; sirve como control de regresión en CI")
+	race := fs.Bool("race", false, "la corrida especulativa la gana la primera rama que termina, que cancela a las demás; la condición se sigue calculando y registrando, pero no elige la ganadora (la secuencial no cambia: ejecuta la rama que elige la condición)")
+	conditionReps := fs.Int("condition-reps", defaults.ConditionReps, "cantidad de trazas, cada una con matrices aleatorias nuevas, que se promedian (con redondeo) para obtener la condición de cada corrida; condition_duration_ms incluye todas (solo con policy threshold o parity y matrices aleatorias)")
+	summaryOnly := fs.Bool("summary-only", false, "escribe en el archivo de métricas solo el registro de reproducibilidad, el encabezado y el resumen, sin las filas de cada corrida (en JSON, solo config y summary); la salida por consola no cambia")
+	earlyCancel := fs.Bool("early-cancel", false, "experimental: calcula la traza fila por fila y la detiene en cuanto la ganadora queda decidida respecto del umbral, de modo que las perdedoras se cancelan antes; condition_value es entonces la suma parcial y condition_fraction la fracción de filas calculadas (solo con policy threshold y matrices aleatorias)")
+	cancelMode := fs.String("cancel-mode", defaults.CancelMode, "qué hace la corrida especulativa con las ramas canceladas: drain (espera su resultado antes de cerrar la corrida) o abandon (la corrida termina con el resultado de la ganadora y las perdedoras se recogen en segundo plano)")
+	branchTimeout := fs.Duration("branch-timeout", 0, "plazo máximo de cada rama (por ejemplo 30s); al vencer la rama se cancela y queda marcada timed_out, también en la estrategia secuencial; 0 no lo limita")
+	cooldownFlag := fs.Duration("cooldown", 0, "pausa entre corridas medidas consecutivas y entre la fase especulativa y la secuencial (por ejemplo 500ms), para reducir el sesgo térmico")
+	dumpMatrix := fs.String("dump-matrix", "", "escribe en este archivo la matriz producto completa de la corrida 1 y su traza, para verificar el cálculo")
+	force := fs.Bool("force", false, "permite dump-matrix con n mayor que 1000")
+	tie := fs.String("tie", defaults.Tie, "rama que gana cuando la traza es exactamente igual al umbral: a (la regla >= del enunciado), b (regla >) o random (volado reproducible por corrida)")
+	delimiter := fs.String("delimiter", defaults.Delimiter, "separador de columnas del CSV de métricas: \",\", \";\", \"|\" o \"tab\"")
+	csvSafe := fs.Bool("csv-safe", defaults.CSVSafe, "antepone una comilla simple a las celdas de texto del CSV (result_detail, shadow_detail, error) que empiezan con =, +, - o @, para que una planilla no las interprete como fórmulas")
+	manifest := fs.Bool("manifest", defaults.Manifest, "escribe junto al archivo de resultados un <archivo>.manifest.json con la configuración, la semilla, la versión de Go, el sistema y la cantidad de CPU")
+	retries := fs.Int("retries", 0, "reintentos de una rama que falla con un error distinto de la cancelación antes de abortar la corrida")
+	matrixMax := fs.Int("matrix-max", defaults.MatrixMax, "cota superior (exclusiva) de los elementos de las matrices aleatorias, que quedan entre 0 y matrix-max-1; la traza esperada crece con su cuadrado")
+	matrixFile := fs.String("matrix-file", "", "archivo con las dos matrices NxN de la condición (enteros separados por espacios, matrices separadas por una línea en blanco); reemplaza las matrices aleatorias y la flag n")
+	configFile := fs.String("config", "", "archivo JSON con valores para las flags (mismas claves); las flags indicadas en la línea de comandos tienen prioridad")
+	if err := fs.Parse(args); err != nil {
+		return speculative.Config{}, err
+	}
+
+	if *configFile != "" {
+		if err := applyConfigFile(fs, *configFile); err != nil {
+			return speculative.Config{}, err
+		}
+	}
 
-func errorString(err error) string {
-	if err == nil {
-		return ""
+	var progressFunc speculative.ProgressFunc
+	if *progress {
+		progressFunc = speculative.PrintProgress
+	}
+
+	cfg := speculative.Config{
+		MatrixSize:      *matrixSize,
+		Threshold:       *threshold,
+		OutputFile:      *output,
+		Runs:            *runs,
+		Warmup:          *warmup,
+		PowDifficulty:   *difficulty,
+		PowData:         *data,
+		PowMode:         *powMode,
+		MaxNonce:        *maxNonce,
+		PowHash:         *powHash,
+		PowWorkers:      *powWorkers,
+		PrimesLimit:     *primesLimit,
+		StopSignals:     *stopSignals,
+		SampleRows:      *sampleRows,
+		WorkloadSpec:    *workloadSpec,
+		ReferenceMs:     *referenceMs,
+		Format:          *format,
+		JSONFlat:        *jsonFlat,
+		PrimesBits:      *primesBits,
+		DecisionLog:     *decisionLog,
+		PrimesAlgo:      *primesAlgo,
+		PrimesWorkers:   *primesWorkers,
+		PrimesSegment:   *primesSegment,
+		DetectThrottle:  *detectThrottle,
+		BranchIsolation: *isolation,
+		AllocPerPrime:   *allocPerPrime,
+		PrimesStats:     *primesStats,
+		ShadowLosers:    *shadowLosers,
+		MaxOutputBytes:  *maxOutputBytes,
+		Append:          *appendOutput,
+		WarningsJSON:    *warningsJSON,
+		SpeedupTrend:    *speedupTrend,
+		TrendFile:       *trendFile,
+		Rotate:          *rotate,
+		Validate:        *validate,
+		ShowVersion:     *showVersion,
+		ListBranches:    *listBranches,
+		ConfigFile:      *configFile,
+		Sweep:           *sweep || command == commandSweep,
+		Quiet:           *quiet,
+		Interleave:      *interleave,
+		MatrixFile:      *matrixFile,
+		MatrixMax:       *matrixMax,
+		Retries:         *retries,
+		Manifest:        *manifest,
+		CSVSafe:         *csvSafe,
+		CPUProfile:      *cpuProfile,
+		MemProfile:      *memProfile,
+		Delimiter:       *delimiter,
+		PrimesLow:       *primesLow,
+		Verbose:         *verbose,
+		SweepSizes:      *sweepSizes,
+		SelfCheck:       *selfCheck,
+		SweepFile:       *sweepFile,
+		CancelMode:      *cancelMode,
+		EarlyCancel:     *earlyCancel,
+		SummaryOnly:     *summaryOnly,
+		ConditionReps:   *conditionReps,
+		Race:            *race,
+		MinSpeedup:      *minSpeedup,
+		Symmetric:       *symmetric,
+		DifficultySweep: *difficultySweep,
+		DifficultyFile:  *difficultySweepFile,
+		Seed:            *seed,
+		WorkerArgs:      args,
+		Policy:          *policy,
+		Tie:             *tie,
+		DumpMatrix:      *dumpMatrix,
+		Force:           *force,
+		Cooldown:        *cooldownFlag,
+		BranchTimeout:   *branchTimeout,
+		ParallelRuns:    *parallelRuns,
+		Branches:        *branchNames,
+		Progress:        progressFunc,
+	}
+	// Con -matrix-file las matrices (y la dimensión) salen del archivo; se leen aquí, una sola vez,
+	// para que un archivo inválido se informe antes de ejecutar y para que el selector multi use el
+	// n real.
+	cfg, err := speculative.LoadMatrixFile(cfg)
+	if err != nil {
+		return speculative.Config{}, err
 	}
-	return err.Error()
+	cfg.Selector = speculative.NewSelector(cfg)
+	return cfg, nil
 }
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"tarea02/speculative"
+	"testing"
+)
+
+// validateArgs reproduce el camino de -validate de main: interpreta args, valida la configuración
+// y arma las ramas, y devuelve la configuración resuelta que se imprimiría.
+func validateArgs(t *testing.T, args ...string) (speculative.Config, error) {
+	t.Helper()
+	cfg, err := parseFlags(commandRun, args)
+	if err != nil {
+		return cfg, err
+	}
+	if err := speculative.ValidateConfig(cfg); err != nil {
+		return cfg, err
+	}
+	_, err = speculative.BuildBranchWorkload(cfg)
+	return cfg, err
+}
+
+func TestValidateFlagPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"explicit values", []string{"-validate", "-n", "50", "-runs", "3", "-seed", "9", "-format", "json"}, ""},
+		{"zero runs", []string{"-validate", "-runs", "0"}, "runs debe ser mayor que cero"},
+		{"negative n", []string{"-validate", "-n", "-1"}, "n debe ser mayor que cero"},
+		{"unknown format", []string{"-validate", "-format", "xml"}, "format debe ser"},
+		{"unknown branch", []string{"-validate", "-branches", "A,B,Z"}, "branches inválido"},
+	}
+	for _, tt := range tests {
+		_, err := validateArgs(t, tt.args...)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want it to contain %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePrintsResolvedConfig(t *testing.T) {
+	cfg, err := validateArgs(t, "-validate", "-runs", "4", "-seed", "11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Validate {
+		t.Fatal("-validate was not recorded in the config")
+	}
+	var out bytes.Buffer
+	if err := speculative.PrintResolvedConfig(&out, cfg); err != nil {
+		t.Fatal(err)
+	}
+	var printed map[string]any
+	if err := json.Unmarshal(out.Bytes(), &printed); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if printed["runs"] != 4.0 || printed["seed"] != 11.0 || printed["n"] != 125.0 {
+		t.Errorf("resolved config runs=%v seed=%v n=%v, want 4, 11 and the default 125", printed["runs"], printed["seed"], printed["n"])
+	}
+}
+
+// mainArgsEnv lleva a TestMainProcess, en un subproceso, los argumentos con que ejecutar main.
+const mainArgsEnv = "TAREA02_MAIN_ARGS"
+
+// TestMainProcess no es una prueba: runMain vuelve a ejecutar el binario de pruebas con solo esta
+// función y mainArgsEnv, y aquí se llama a main con esos argumentos para observar su salida y su
+// código de salida sin terminar el proceso de las pruebas.
+func TestMainProcess(t *testing.T) {
+	raw := os.Getenv(mainArgsEnv)
+	if raw == "" {
+		return
+	}
+	var args []string
+	if err := json.Unmarshal([]byte(raw), &args); err != nil {
+		os.Exit(2)
+	}
+	os.Args = append([]string{"tarea02"}, args...)
+	main()
+	os.Exit(0)
+}
+
+// runMain ejecuta main con args en un subproceso cuyo directorio de trabajo es dir y devuelve su
+// salida estándar, su salida de errores y su código de salida.
+func runMain(t *testing.T, dir string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(test, "-test.run=^TestMainProcess$")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), mainArgsEnv+"="+string(encoded))
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+func TestConsoleOutputModes(t *testing.T) {
+	base := []string{"-runs", "2", "-n", "10", "-primes-limit", "1000", "-seed", "3"}
+	tests := []struct {
+		name     string
+		flags    []string
+		code     int
+		contains []string // cadenas que deben aparecer en stdout; nil exige stdout vacío
+		excludes []string
+		stderr   string
+	}{
+		{"default", nil, 0, []string{"Simulaciones completadas: 2 (especulativo) + 2 (secuencial)"}, []string{"ganadora="}, ""},
+		{"quiet", []string{"-quiet"}, 0, nil, nil, ""},
+		{"verbose", []string{"-verbose"}, 0, []string{"especulativo 1: ganadora=", "secuencial 2: ganadora=", "Simulaciones completadas"}, nil, ""},
+		{"both", []string{"-quiet", "-verbose"}, 1, nil, nil, "quiet y verbose no pueden usarse juntas"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		stdout, stderr, code := runMain(t, dir, append(base, tt.flags...)...)
+		if code != tt.code {
+			t.Fatalf("%s: exit code %d, want %d; stderr:\n%s", tt.name, code, tt.code, stderr)
+		}
+		if tt.contains == nil && stdout != "" {
+			t.Errorf("%s: stdout = %q, want nothing", tt.name, stdout)
+		}
+		for _, want := range tt.contains {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("%s: stdout lacks %q:\n%s", tt.name, want, stdout)
+			}
+		}
+		for _, unwanted := range tt.excludes {
+			if strings.Contains(stdout, unwanted) {
+				t.Errorf("%s: stdout contains %q:\n%s", tt.name, unwanted, stdout)
+			}
+		}
+		if tt.code != 0 {
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("%s: stderr = %q, want it to contain %q", tt.name, stderr, tt.stderr)
+			}
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, "metricas.csv")); err != nil {
+			t.Errorf("%s: metrics file not written: %v", tt.name, err)
+		}
+	}
+}
+
+func TestMetricsToStdout(t *testing.T) {
+	dir := t.TempDir()
+	stdout, stderr, code := runMain(t, dir, "-runs", "2", "-n", "10", "-primes-limit", "1000", "-nombre_archivo", "-")
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr)
+	}
+	reader := csv.NewReader(strings.NewReader(stdout))
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("stdout is not clean CSV: %v\n%s", err, stdout)
+	}
+	if len(records) < 2 || records[0][0] != "mode" {
+		t.Fatalf("stdout records = %v", records)
+	}
+	runs := 0
+	for _, record := range records[1:] {
+		if record[0] == "resumen" {
+			continue
+		}
+		runs++
+	}
+	if runs == 0 {
+		t.Error("no run rows on stdout")
+	}
+	if !strings.Contains(stderr, "Simulaciones completadas") {
+		t.Errorf("console summary missing from stderr:\n%s", stderr)
+	}
+	// Ni un archivo llamado - ni su manifiesto: la salida estándar es el único destino.
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("working directory holds %v (%v), want it empty", entries, err)
+	}
+}
+
+func TestCheckMinSpeedup(t *testing.T) {
+	tests := []struct {
+		min, speedup float64
+		wantErr      bool
+	}{
+		{0, 0.5, false},
+		{0, math.NaN(), false},
+		{1.2, 1.5, false},
+		{1.2, 1.2, false},
+		{1.2, 1.1, true},
+		{1.2, math.NaN(), true},
+	}
+	for _, tt := range tests {
+		err := checkMinSpeedup(speculative.Config{MinSpeedup: tt.min}, speculative.Summary{Speedup: tt.speedup})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("min %v speedup %v: err = %v, want error %v", tt.min, tt.speedup, err, tt.wantErr)
+		}
+	}
+
+	// Un speedup de 1000 es inalcanzable, así que el programa debe terminar con código 1 después de
+	// escribir las métricas.
+	dir := t.TempDir()
+	_, stderr, code := runMain(t, dir, "-runs", "2", "-n", "10", "-primes-limit", "1000", "-min-speedup", "1000")
+	if code != 1 || !strings.Contains(stderr, "speedup check failed") {
+		t.Errorf("exit code %d, stderr %q; want 1 and a speedup check failure", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "metricas.csv")); err != nil {
+		t.Errorf("metrics file not written before failing: %v", err)
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	dir := t.TempDir()
+	stdout, stderr, code := runMain(t, dir, "-version")
+	if code != 0 || stderr != "" {
+		t.Fatalf("exit code %d, stderr %q; want 0 and no errors", code, stderr)
+	}
+	if line := strings.TrimSpace(stdout); line != speculative.VersionLine() || !strings.HasPrefix(line, "tarea02 ") {
+		t.Errorf("stdout %q, want the version line %q", stdout, speculative.VersionLine())
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("-version wrote %v (%v), want no files", entries, err)
+	}
+}
+
+func TestListBranchesFlag(t *testing.T) {
+	stdout, stderr, code := runMain(t, t.TempDir(), "-list-branches")
+	if code != 0 || stderr != "" {
+		t.Fatalf("exit code %d, stderr %q; want 0 and no errors", code, stderr)
+	}
+	for _, want := range []string{"A  Proof-of-Work", "B  búsqueda de primos"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("-list-branches output %q lacks %q", stdout, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"tarea02/speculative"
+	"time"
+)
+
+// runManifest describe la ejecución que produjo un archivo de resultados, para que un directorio
+// de resultados se explique por sí solo al compartirlo.
+type runManifest struct {
+	Tool      string `json:"tool"`
+	Version   string `json:"version"`
+	Revision  string `json:"vcs_revision,omitempty"`
+	GoVersion string `json:"go_version"`
+	GOOS      string `json:"goos"`
+	GOARCH    string `json:"goarch"`
+	NumCPU    int    `json:"num_cpu"`
+	// Seed es la semilla efectiva, ya resuelta cuando -seed vale 0.
+	Seed      int64              `json:"seed"`
+	Timestamp string             `json:"timestamp"`
+	Config    speculative.Config `json:"config"`
+}
+
+// manifestPath devuelve el archivo de manifiesto que acompaña a output.
+func manifestPath(output string) string {
+	return output + ".manifest.json"
+}
+
+// writeManifest escribe junto a output (en manifestPath) la configuración completa, la versión de
+// Go, el sistema, la cantidad de CPU y la semilla de la ejecución que inició en start.
+func writeManifest(output string, cfg speculative.Config, start time.Time) error {
+	manifest := runManifest{
+		Tool:      "tarea02",
+		Version:   speculative.Version,
+		Revision:  speculative.VCSRevision(),
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		NumCPU:    runtime.NumCPU(),
+		Seed:      cfg.Seed,
+		Timestamp: start.Format(time.RFC3339),
+		Config:    cfg,
+	}
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(speculative.Directory(output), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(manifestPath(output), append(encoded, '\n'), 0o644)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestManifestRoundTripsConfig(t *testing.T) {
+	cfg, err := parseFlags(commandRun, []string{"-n", "30", "-runs", "4", "-seed", "77", "-policy", "parity", "-pow-mode", "bits", "-difficulty", "6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "resultados", "metricas.csv")
+	start := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	if err := writeManifest(output, cfg, start); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(manifestPath(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest runManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Seed != 77 || manifest.Timestamp != "2024-05-01T12:30:00Z" || manifest.GoVersion != runtime.Version() || manifest.NumCPU != runtime.NumCPU() {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	want, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(manifest.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("config does not round-trip:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestManifestFlag(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		dir := t.TempDir()
+		args := []string{"-runs", "1", "-n", "10", "-primes-limit", "1000", "-quiet"}
+		if !enabled {
+			args = append(args, "-manifest=false")
+		}
+		if _, stderr, code := runMain(t, dir, args...); code != 0 {
+			t.Fatalf("manifest=%v: exit code %d\n%s", enabled, code, stderr)
+		}
+		_, err := os.Stat(filepath.Join(dir, manifestPath("metricas.csv")))
+		if exists := err == nil; exists != enabled {
+			t.Errorf("manifest=%v: manifest file exists = %v (%v)", enabled, exists, err)
+		}
+	}
+}
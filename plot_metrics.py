@@ -14,7 +14,7 @@ import csv
 import statistics
 from collections import defaultdict
 from pathlib import Path
-from typing import Dict, List, Tuple
+from typing import Dict, List, Optional, Tuple
 
 import matplotlib
 
@@ -24,13 +24,30 @@ import matplotlib.pyplot as plt
 
 VALID_MODES = {"especulativo", "secuencial"}
 
+# Separadores que acepta la flag -delimiter del programa.
+DELIMITERS = {",": ",", ";": ";", "|": "|", "tab": "\t"}
 
-def load_totals(path: Path) -> Tuple[Dict[str, List[float]], Dict[str, List[int]]]:
-    """Carga los tiempos totales por modo desde el CSV."""
+
+def load_totals(
+    path: Path, delimiter: Optional[str] = None
+) -> Tuple[Dict[str, List[float]], Dict[str, List[int]]]:
+    """Carga los tiempos totales por modo desde el CSV.
+
+    Sin delimiter, el separador se deduce de la fila de encabezado.
+    """
     per_mode: Dict[str, Dict[int, float]] = defaultdict(dict)
 
     with path.open(newline="", encoding="utf-8") as handle:
-        reader = csv.DictReader(handle)
+        # Las líneas "#" contienen el registro de reproducibilidad, no datos.
+        lines = [line for line in handle if not line.startswith("#")]
+        if delimiter is None:
+            header = lines[0] if lines else ""
+            try:
+                dialect = csv.Sniffer().sniff(header, delimiters="".join(DELIMITERS.values()))
+                delimiter = dialect.delimiter
+            except csv.Error:
+                delimiter = ","
+        reader = csv.DictReader(lines, delimiter=delimiter)
         for row in reader:
             mode = row.get("mode", "").strip().lower()
             if mode not in VALID_MODES:
@@ -137,12 +154,21 @@ def parse_args() -> argparse.Namespace:
         default="Comparación ejecución especulativa vs secuencial",
         help="Título principal de la figura.",
     )
+    parser.add_argument(
+        "--delimiter",
+        choices=sorted(DELIMITERS),
+        help=(
+            "Separador de columnas del CSV, como la flag -delimiter "
+            "(por defecto se deduce del encabezado)."
+        ),
+    )
     return parser.parse_args()
 
 
 def main() -> None:
     args = parse_args()
-    durations, run_indices = load_totals(args.csv_path)
+    delimiter = DELIMITERS[args.delimiter] if args.delimiter else None
+    durations, run_indices = load_totals(args.csv_path, delimiter)
     if not durations:
         raise SystemExit("No se encontraron datos válidos en el CSV.")
 
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"tarea02/speculative"
+)
+
+// stopProfiling detiene el perfil de CPU de -cpuprofile y escribe el de memoria de -memprofile;
+// startProfiling la reemplaza y exit la invoca antes de terminar el proceso.
+var stopProfiling = func() {}
+
+// startProfiling abre el archivo de -cpuprofile e inicia el perfil de CPU. Los archivos se cierran
+// con stopProfiling, que main difiere y exit invoca, de modo que los perfiles quedan completos
+// también cuando el programa termina antes de tiempo.
+func startProfiling(cfg speculative.Config) error {
+	if cfg.CPUProfile == "" && cfg.MemProfile == "" {
+		return nil
+	}
+
+	var cpuFile *os.File
+	if cfg.CPUProfile != "" {
+		file, err := os.Create(cfg.CPUProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return err
+		}
+		cpuFile = file
+	}
+
+	var once sync.Once
+	stopProfiling = func() {
+		once.Do(func() {
+			if cpuFile != nil {
+				pprof.StopCPUProfile()
+				if err := cpuFile.Close(); err != nil {
+					fmt.Fprintf(os.Stderr, "failed writing CPU profile: %v\n", err)
+				}
+			}
+			if cfg.MemProfile != "" {
+				if err := writeHeapProfile(cfg.MemProfile); err != nil {
+					fmt.Fprintf(os.Stderr, "failed writing memory profile: %v\n", err)
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// writeHeapProfile escribe en path el perfil del heap, tras un GC para que refleje las
+// asignaciones vivas al terminar.
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// exit termina el proceso con code después de cerrar los perfiles en curso.
+func exit(code int) {
+	stopProfiling()
+	os.Exit(code)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestProfileFlagsWriteProfiles ejecuta main con -cpuprofile y -memprofile en una ejecución normal
+// y en una que termina con exit(1) por -min-speedup, y comprueba que ambos perfiles quedan escritos.
+func TestProfileFlagsWriteProfiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		code  int
+	}{
+		{"normal exit", nil, 0},
+		{"early exit", []string{"-min-speedup", "1000"}, 1},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		args := append([]string{"-runs", "2", "-n", "40", "-quiet", "-cpuprofile", "cpu.pprof", "-memprofile", "mem.pprof"}, tt.flags...)
+		if _, stderr, code := runMain(t, dir, args...); code != tt.code {
+			t.Fatalf("%s: exit code %d, want %d\n%s", tt.name, code, tt.code, stderr)
+		}
+		for _, name := range []string{"cpu.pprof", "mem.pprof"} {
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			if info.Size() == 0 {
+				t.Errorf("%s: %s is empty", tt.name, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// watchStopSignals instala el manejador de señales y devuelve un contexto derivado de parent que
+// se cancela al recibir la primera de ellas, para que el programa guarde las corridas completadas.
+// Una segunda señal termina el proceso de inmediato, sin esperar a que se escriban las métricas,
+// por si la limpieza se queda bloqueada. La función devuelta desinstala el manejador.
+func watchStopSignals(parent context.Context, signals []os.Signal) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	if len(signals) == 0 {
+		return ctx, cancel
+	}
+
+	notify := make(chan os.Signal, 1)
+	signal.Notify(notify, signals...)
+	stopped := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-notify:
+			fmt.Fprintf(os.Stderr, "received %s, cancelling in-flight branches and flushing metrics (send it again to exit immediately)\n", sig)
+			cancel()
+		case <-stopped:
+			return
+		}
+		select {
+		case sig := <-notify:
+			fmt.Fprintf(os.Stderr, "received %s again, exiting without flushing metrics\n", sig)
+			exit(1)
+		case <-stopped:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(notify)
+		close(stopped)
+		cancel()
+	}
+}
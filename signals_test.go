@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"tarea02/speculative"
+	"testing"
+)
+
+// TestStopSignalKeepsCompletedRuns envía una señal de detención al propio proceso durante la
+// tercera corrida y comprueba que, como en main, las dos corridas completadas quedan en el archivo.
+func TestStopSignalKeepsCompletedRuns(t *testing.T) {
+	ctx, stop := watchStopSignals(context.Background(), []os.Signal{syscall.SIGHUP})
+	defer stop()
+
+	var calls atomic.Int32
+	signalled := make(chan error, 1)
+	// La rama A gana siempre (umbral 0); en su tercera ejecución se envía la señal y espera a que
+	// la cancelación llegue por el contexto, como una rama larga interrumpida.
+	branchA := func(runCtx context.Context) (speculative.BranchOutput, error) {
+		if calls.Add(1) == 3 {
+			process, err := os.FindProcess(os.Getpid())
+			if err == nil {
+				err = process.Signal(syscall.SIGHUP)
+			}
+			signalled <- err
+			<-runCtx.Done()
+			return speculative.BranchOutput{}, speculative.ErrCancelled
+		}
+		return speculative.BranchOutput{Numeric: 1, Detail: "a"}, nil
+	}
+	branchB := func(context.Context) (speculative.BranchOutput, error) {
+		return speculative.BranchOutput{Numeric: 2, Detail: "b"}, nil
+	}
+
+	cfg, err := parseFlags(commandRun, []string{"-runs", "5", "-n", "10", "-umbral", "0", "-seed", "1", "-quiet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	rows, err := speculative.NewMetricsWriter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	engine := speculative.Engine{
+		Branches: []speculative.NamedBranch{{Name: "A", Work: branchA}, {Name: "B", Work: branchB}},
+		OnRun:    rows.WriteRun,
+	}
+	report, err := engine.RunContext(ctx, cfg)
+	if err := <-signalled; err != nil {
+		t.Skipf("cannot signal the test process: %v", err)
+	}
+	if !errors.Is(err, speculative.ErrInterrupted) {
+		t.Fatalf("RunContext: err = %v, want ErrInterrupted", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("the stop signal did not cancel the context")
+	}
+	if len(report.Speculative) != 2 || len(report.Sequential) != 0 {
+		t.Fatalf("report has %d speculative and %d sequential runs, want 2 and 0", len(report.Speculative), len(report.Sequential))
+	}
+	if err := rows.Finish(report.Summary); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := make(map[string]bool)
+	for _, record := range records[1:] {
+		if record[0] == speculative.ModeSpeculative {
+			runs[record[1]] = true
+		}
+	}
+	if len(runs) != 2 || !runs["1"] || !runs["2"] {
+		t.Errorf("file holds speculative runs %v, want 1 and 2", runs)
+	}
+}
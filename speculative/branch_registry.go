@@ -0,0 +1,101 @@
+package speculative
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// registeredBranch es una entrada del registro de ramas.
+type registeredBranch struct {
+	// Description es la descripción breve que muestra -list-branches.
+	Description string
+	// Factory arma el trabajo de la rama a partir de la configuración.
+	Factory func(cfg Config) BranchWork
+}
+
+// branchRegistry asocia el nombre de cada rama registrada con su descripción y la función que
+// arma su trabajo; -branches elige cuáles participan.
+var branchRegistry = map[string]registeredBranch{}
+
+func init() {
+	RegisterBranch(branchA, "Proof-of-Work: busca el primer nonce cuyo hash cumple -difficulty (ver -pow-mode)", powBranchWork)
+	RegisterBranch(branchB, "búsqueda de primos hasta -primes-limit con el algoritmo de -primes-algo", primesBranchWork)
+}
+
+// RegisterBranch agrega la rama name al registro, de modo que -branches pueda seleccionarla sin
+// modificar buildLocalBranches; description la presenta en -list-branches y factory arma su
+// trabajo para cada configuración. Como los registros se hacen normalmente en init, un nombre
+// vacío o repetido, una descripción vacía o un factory nil provocan un panic.
+func RegisterBranch(name, description string, factory func(cfg Config) BranchWork) {
+	switch {
+	case strings.TrimSpace(name) == "" || strings.Contains(name, ","):
+		panic(fmt.Sprintf("RegisterBranch: nombre de rama inválido %q", name))
+	case strings.TrimSpace(description) == "":
+		panic("RegisterBranch: falta la descripción de la rama " + name)
+	case factory == nil:
+		panic("RegisterBranch: factory nil para la rama " + name)
+	}
+	if _, dup := branchRegistry[name]; dup {
+		panic("RegisterBranch: la rama " + name + " ya está registrada")
+	}
+	branchRegistry[name] = registeredBranch{Description: description, Factory: factory}
+}
+
+// WriteBranchList escribe en w una línea por rama registrada, en orden alfabético, con su nombre y
+// su descripción.
+func WriteBranchList(w io.Writer) error {
+	names := registeredBranchNames()
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%-*s  %s\n", width, name, branchRegistry[name].Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registeredBranchNames devuelve los nombres del registro en orden alfabético.
+func registeredBranchNames() []string {
+	names := make([]string, 0, len(branchRegistry))
+	for name := range branchRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseBranchNames interpreta la lista de -branches: nombres registrados, separados por comas y
+// sin repetir, en el orden en que se lanzan. Deben incluir A y B, entre las que elige el selector.
+func parseBranchNames(spec string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, raw := range strings.Split(spec, ",") {
+		name := strings.TrimSpace(raw)
+		if name == "" {
+			continue
+		}
+		if _, ok := branchRegistry[name]; !ok {
+			return nil, fmt.Errorf("la rama %q no está registrada (disponibles: %s)", name, strings.Join(registeredBranchNames(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("la rama %s aparece más de una vez", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("la lista está vacía")
+	}
+	for _, required := range []string{branchA, branchB} {
+		if !seen[required] {
+			return nil, fmt.Errorf("debe incluir la rama %s", required)
+		}
+	}
+	return names, nil
+}
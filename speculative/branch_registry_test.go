@@ -0,0 +1,62 @@
+package speculative
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// registerTestBranch registra una rama de prueba y la quita del registro al terminar el test.
+func registerTestBranch(t *testing.T, name string, factory func(cfg Config) BranchWork) {
+	t.Helper()
+	RegisterBranch(name, "rama de prueba", factory)
+	t.Cleanup(func() { delete(branchRegistry, name) })
+}
+
+func TestRegisteredBranchRuns(t *testing.T) {
+	registerTestBranch(t, "STUB", func(Config) BranchWork { return fixedWork(99, "stub") })
+
+	cfg := testConfig()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	cfg.Branches = "A,B,STUB"
+	cfg.Selector = func(ConditionMetrics) string { return "STUB" }
+	report := writeMetrics(t, cfg, nil)
+
+	for _, runs := range [][]ExecutionRun{report.Speculative, report.Sequential} {
+		for _, run := range runs {
+			if run.Winner != "STUB" {
+				t.Errorf("%s run %d: winner %q, want STUB", run.Mode, run.RunIndex, run.Winner)
+			}
+		}
+	}
+	stubRows := 0
+	for _, row := range readMetricsRows(t, cfg.OutputFile) {
+		if row["branch"] != "STUB" {
+			continue
+		}
+		stubRows++
+		if row["was_winner"] != "true" || row["result_numeric"] != "99" || row["result_detail"] != "stub" {
+			t.Errorf("%s run %s: STUB row was_winner=%s numeric=%s detail=%s",
+				row["mode"], row["run"], row["was_winner"], row["result_numeric"], row["result_detail"])
+		}
+	}
+	if stubRows != 2*cfg.Runs {
+		t.Errorf("%d STUB rows, want %d (one per run of each strategy)", stubRows, 2*cfg.Runs)
+	}
+}
+
+func TestWriteBranchListIncludesBuiltinBranches(t *testing.T) {
+	var out strings.Builder
+	if err := WriteBranchList(&out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := []string{
+		"A  " + branchRegistry[branchA].Description,
+		"B  " + branchRegistry[branchB].Description,
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("branch list:\n%s\nwant:\n%s", out.String(), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,58 @@
+package speculative
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// VCSRevision devuelve la revisión de control de versiones con que se compiló el binario, con el
+// sufijo "-dirty" si el árbol tenía cambios sin confirmar, o "" si la compilación no la registró
+// (por ejemplo, con go run).
+func VCSRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// buildVersion identifica el binario en la fila resumen del CSV: Version más, si se conoce, los
+// primeros 12 caracteres de la revisión (dev+0123456789ab-dirty).
+func buildVersion() string {
+	revision := VCSRevision()
+	if revision == "" {
+		return Version
+	}
+	short, dirty := strings.CutSuffix(revision, "-dirty")
+	if len(short) > 12 {
+		short = short[:12]
+	}
+	if dirty {
+		short += "-dirty"
+	}
+	return Version + "+" + short
+}
+
+// VersionLine es la salida de -version: la versión, la de Go y la revisión del binario.
+func VersionLine() string {
+	revision := VCSRevision()
+	if revision == "" {
+		revision = "desconocida"
+	}
+	return fmt.Sprintf("tarea02 %s (%s, %s/%s, revisión %s)", Version, runtime.Version(), runtime.GOOS, runtime.GOARCH, revision)
+}
@@ -0,0 +1,23 @@
+package speculative
+
+import "time"
+
+// Clock es la fuente de la hora con que se miden las corridas (inicio, condición, ramas y total).
+// Permite sustituir el reloj del sistema por uno controlado y obtener duraciones exactas; como las
+// ramas especulativas la consultan desde sus goroutines, debe admitir llamadas concurrentes.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock es el Clock por defecto: la hora del sistema, con su lectura monotónica.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// runClock devuelve cfg.Clock o, si es nil, realClock.
+func runClock(cfg Config) Clock {
+	if cfg.Clock != nil {
+		return cfg.Clock
+	}
+	return realClock{}
+}
@@ -0,0 +1,66 @@
+package speculative
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stepClock es un Clock controlado que avanza step en cada lectura, de modo que la duración de un
+// intervalo es step por la cantidad de lecturas que lo separan.
+type stepClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+// TestFakeClockDurations ejecuta la estrategia secuencial, cuyas lecturas del reloj siguen un
+// orden fijo (inicio, inicio y fin de la condición, inicio y fin de la rama, fin de la corrida),
+// y comprueba las duraciones exactas que llegan al CSV.
+func TestFakeClockDurations(t *testing.T) {
+	cfg := testConfig()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	cfg.Clock = &stepClock{now: time.Unix(0, 0), step: time.Millisecond}
+	cfg.Selector = NewSelector(cfg)
+	branches := []NamedBranch{{Name: branchA, Work: fixedWork(1, "a")}, {Name: branchB, Work: fixedWork(2, "b")}}
+
+	writer, err := NewMetricsWriter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := collectRuns(context.Background(), cfg, ModeSequential, branches, writer.WriteRun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Finish(buildSummary(cfg, runs, runs)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"condition_duration_ms": "1.000",
+		"branch_start_ms":       "3.000",
+		"branch_end_ms":         "4.000",
+		"branch_duration_ms":    "1.000",
+		"total_duration_ms":     "5.000",
+	}
+	rows := readMetricsRows(t, cfg.OutputFile)
+	if len(rows) != cfg.Runs {
+		t.Fatalf("%d rows, want %d", len(rows), cfg.Runs)
+	}
+	for _, row := range rows {
+		for column, value := range want {
+			if row[column] != value {
+				t.Errorf("run %s: %s = %s, want %s", row["run"], column, row[column], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package speculative
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// runOutcome es lo que una corrida reproduce sin importar cuántas se ejecuten a la vez: la
+// condición, la ganadora y el resultado de la ganadora. Las duraciones y lo que alcanzan a
+// calcular las perdedoras antes de atender la cancelación cambian de una ejecución a otra.
+type runOutcome struct {
+	RunIndex  int
+	Condition int64
+	Winner    string
+	Numeric   int64
+	Detail    string
+}
+
+func outcomes(runs []ExecutionRun) ([]runOutcome, error) {
+	result := make([]runOutcome, 0, len(runs))
+	for _, run := range runs {
+		outcome := runOutcome{RunIndex: run.RunIndex, Condition: run.ConditionValue, Winner: run.Winner}
+		found := false
+		for _, branch := range run.Branches {
+			if branch.Name == run.Winner {
+				if branch.Cancelled || branch.Err != nil {
+					return nil, fmt.Errorf("run %d: winner %s cancelled=%v err=%v", run.RunIndex, run.Winner, branch.Cancelled, branch.Err)
+				}
+				outcome.Numeric, outcome.Detail, found = branch.Numeric, branch.Detail, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("run %d: no result for winner %s", run.RunIndex, run.Winner)
+		}
+		result = append(result, outcome)
+	}
+	return result, nil
+}
+
+// TestCollectRunsConcurrent lanza varias llamadas a CollectRuns a la vez, de ambas estrategias y
+// con distintos -parallel-runs, sobre las mismas ramas, y comprueba que cada una reproduce las
+// corridas de una ejecución secuencial. Cada corrida usa el generador de runRNG, así que los
+// resultados solo coinciden si las corridas no comparten estado; ejecutarla con go test -race
+// detecta además los accesos concurrentes sin sincronizar.
+func TestCollectRunsConcurrent(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 8
+	// Cerca de la traza esperada (n² · 4,5²), para que ganen ambas ramas.
+	cfg.Threshold = 8100
+	cfg.Selector = NewSelector(cfg)
+	branches, err := BuildBranchWorkload(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	want := make(map[string][]runOutcome)
+	for _, mode := range []string{ModeSpeculative, ModeSequential} {
+		runs, err := CollectRuns(ctx, cfg, mode, branches)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if want[mode], err = outcomes(runs); err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+	}
+	winners := make(map[string]bool)
+	for _, outcome := range want[ModeSequential] {
+		winners[outcome.Winner] = true
+	}
+	if len(winners) < 2 {
+		t.Fatalf("only %v won with threshold %d; both branches should win some runs", winners, cfg.Threshold)
+	}
+
+	const callers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		mode := ModeSpeculative
+		if i%2 == 1 {
+			mode = ModeSequential
+		}
+		callerCfg := cfg
+		callerCfg.ParallelRuns = 1 + i%4
+		wg.Add(1)
+		go func(caller int, mode string, cfg Config) {
+			defer wg.Done()
+			runs, err := CollectRuns(ctx, cfg, mode, branches)
+			if err != nil {
+				t.Errorf("caller %d (%s): %v", caller, mode, err)
+				return
+			}
+			got, err := outcomes(runs)
+			if err != nil {
+				t.Errorf("caller %d (%s): %v", caller, mode, err)
+				return
+			}
+			if len(got) != len(want[mode]) {
+				t.Errorf("caller %d (%s): %d runs, want %d", caller, mode, len(got), len(want[mode]))
+				return
+			}
+			for j := range got {
+				if got[j] != want[mode][j] {
+					t.Errorf("caller %d (%s, parallel-runs %d): run %d = %+v, want %+v", caller, mode, cfg.ParallelRuns, j+1, got[j], want[mode][j])
+				}
+			}
+		}(i, mode, callerCfg)
+	}
+	wg.Wait()
+}
+
+func TestParallelRunsCollectEveryRun(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 8
+	cfg.ParallelRuns = 4
+	seen := make(map[string]map[int]int)
+	engine := Engine{OnRun: func(run ExecutionRun) error {
+		if seen[run.Mode] == nil {
+			seen[run.Mode] = make(map[int]int)
+		}
+		seen[run.Mode][run.RunIndex]++
+		return nil
+	}}
+	report, err := engine.Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for mode, runs := range map[string][]ExecutionRun{ModeSpeculative: report.Speculative, ModeSequential: report.Sequential} {
+		if len(runs) != cfg.Runs {
+			t.Fatalf("%s: %d runs collected, want %d", mode, len(runs), cfg.Runs)
+		}
+		for i, run := range runs {
+			if run.RunIndex != i+1 {
+				t.Errorf("%s: position %d holds run %d; results must be in run order", mode, i+1, run.RunIndex)
+			}
+		}
+		if len(seen[mode]) != cfg.Runs {
+			t.Errorf("%s: OnRun saw %d distinct runs, want %d", mode, len(seen[mode]), cfg.Runs)
+		}
+		for index, calls := range seen[mode] {
+			if calls != 1 || index < 1 || index > cfg.Runs {
+				t.Errorf("%s: OnRun called %d times for run %d", mode, calls, index)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package speculative
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+const (
+	PolicyThreshold = "threshold"
+	PolicyMulti     = "multi"
+	PolicyParity    = "parity"
+)
+
+// Valores de -tie: qué rama gana cuando la traza es exactamente igual al umbral.
+const (
+	TieA      = "a"
+	TieB      = "b"
+	TieRandom = "random"
+)
+
+// ConditionMetrics reúne los valores de la condición costosa con que se elige la rama ganadora.
+// Trace siempre se calcula; DetSign y ElementSum solo cuando la política los usa.
+type ConditionMetrics struct {
+	// Trace es la traza de m1·m2, el valor escalar del enunciado.
+	Trace int64
+	// DetSign es el signo de det(m1·m2): -1, 0 o 1.
+	DetSign int
+	// ElementSum es la suma de todos los elementos de m1 y m2.
+	ElementSum int64
+	// Rows es la cantidad de filas de m1 con que se acumuló Trace: n, salvo que -early-cancel
+	// detuviera el cálculo al quedar decidida la ganadora (ver earlyTrace).
+	Rows int
+	// TieCoin es un volado de la corrida con que -tie random resuelve un empate con el umbral. Sale
+	// del mismo generador que las matrices, así que es reproducible y coincide en ambas estrategias.
+	TieCoin bool
+}
+
+// evaluateCondition genera las matrices de la corrida runIndex (o las lee de cfg.MatrixFile, que
+// son las mismas en todas las corridas) y calcula sus métricas. Con la política por defecto se
+// limita a la traza, de modo que el costo de la condición no cambia. Un
+// panic durante el cálculo se devuelve como error, para que la corrida pueda detener sus ramas en
+// lugar de abandonarlas.
+func evaluateCondition(cfg Config, runIndex int) (metrics ConditionMetrics, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("la evaluación de la condición falló: %v", r)
+		}
+	}()
+
+	rng := runRNG(cfg.Seed, runIndex)
+	if cfg.Symmetric {
+		metrics = ConditionMetrics{Trace: squareSum(randomMatrix(cfg.MatrixSize, cfg.MatrixMax, rng)), Rows: cfg.MatrixSize}
+		metrics.TieCoin = rng.Intn(2) == 0
+		return metrics, nil
+	}
+	m1, m2, err := conditionMatrices(cfg, rng)
+	if err != nil {
+		return ConditionMetrics{}, err
+	}
+
+	switch {
+	case cfg.Policy == PolicyMulti:
+		metrics = matrixMetrics(m1, m2)
+	case cfg.EarlyCancel:
+		trace, rows := earlyTrace(m1, m2, cfg.Threshold, cfg.MatrixMax)
+		metrics = ConditionMetrics{Trace: trace, Rows: rows}
+	case cfg.ConditionReps > 1:
+		metrics = ConditionMetrics{Trace: meanTrace(m1, m2, cfg.ConditionReps, cfg.MatrixMax, rng)}
+	default:
+		metrics = ConditionMetrics{Trace: productTrace(m1, m2)}
+	}
+	if metrics.Rows == 0 {
+		metrics.Rows = len(m1)
+	}
+	// El volado se sortea después de las matrices, que no cambian según -tie.
+	metrics.TieCoin = rng.Intn(2) == 0
+	return metrics, nil
+}
+
+// meanTrace devuelve el promedio, redondeado, de la traza de m1·m2 y de las de otros reps-1 pares
+// de matrices aleatorias generados a continuación con rng, para -condition-reps. Promediar
+// reduce la dispersión de la condición en un factor √reps, a cambio de multiplicar su costo.
+func meanTrace(m1, m2 [][]int, reps, matrixMax int, rng *rand.Rand) int64 {
+	total := productTrace(m1, m2)
+	for i := 1; i < reps; i++ {
+		a, b := randomMatrices(len(m1), matrixMax, rng)
+		total += productTrace(a, b)
+	}
+	return int64(math.Round(float64(total) / float64(reps)))
+}
+
+// conditionMatrices devuelve las matrices de la condición: las de cfg.MatrixFile, que
+// LoadMatrixFile ya cargó en cfg.FileMatrices, o, si no se indicó, unas aleatorias generadas con
+// rng.
+func conditionMatrices(cfg Config, rng *rand.Rand) ([][]int, [][]int, error) {
+	if cfg.MatrixFile != "" {
+		if cfg.FileMatrices[0] == nil {
+			return nil, nil, fmt.Errorf("las matrices de matrix-file %s no se cargaron (ver LoadMatrixFile)", cfg.MatrixFile)
+		}
+		return cfg.FileMatrices[0], cfg.FileMatrices[1], nil
+	}
+	m1, m2 := randomMatrices(cfg.MatrixSize, cfg.MatrixMax, rng)
+	return m1, m2, nil
+}
+
+// earlyTrace acumula la traza de m1·m2 fila por fila y se detiene en cuanto su comparación con
+// threshold ya no puede cambiar: cuando la suma parcial supera el umbral o cuando ni siquiera
+// sumando el máximo posible de las filas restantes (n·(maxValue-1)² cada una, porque los
+// elementos están entre 0 y maxValue-1) lo alcanzaría. Devuelve la suma parcial, que decide la
+// ganadora igual que la traza completa, y la cantidad de filas calculadas.
+func earlyTrace(m1, m2 [][]int, threshold int64, maxValue int) (int64, int) {
+	n := len(m1)
+	rowMax := int64(n) * int64(maxValue-1) * int64(maxValue-1)
+	var trace int64
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			trace += int64(m1[i][k]) * int64(m2[k][i])
+		}
+		if trace > threshold || trace+int64(n-i-1)*rowMax < threshold {
+			return trace, i + 1
+		}
+	}
+	return trace, n
+}
+
+// CalcularMetricasCondicion genera las mismas matrices que CalcularTrazaConRNG (para una misma
+// semilla la traza coincide) y calcula, además de la traza, el signo del determinante del producto
+// y la suma de los elementos. Con rng nil se usa la fuente global de math/rand.
+func CalcularMetricasCondicion(n int, rng *rand.Rand) ConditionMetrics {
+	m1, m2 := randomMatrices(n, DefaultMatrixMax, rng)
+	return matrixMetrics(m1, m2)
+}
+
+// matrixMetrics calcula las métricas de la condición para las matrices NxN m1 y m2.
+func matrixMetrics(m1, m2 [][]int) ConditionMetrics {
+	n := len(m1)
+	var sum int64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			sum += int64(m1[i][j]) + int64(m2[i][j])
+		}
+	}
+
+	return ConditionMetrics{
+		Trace: productTrace(m1, m2),
+		// det(m1·m2) = det(m1)·det(m2), así que basta con el signo de cada factor.
+		DetSign:    determinantSign(m1) * determinantSign(m2),
+		ElementSum: sum,
+	}
+}
+
+// determinantSign devuelve el signo del determinante de m mediante eliminación gaussiana con
+// pivoteo parcial. Solo se acumula el signo de los pivotes y de los intercambios de filas, por lo
+// que el resultado no desborda aunque |det| supere el rango de float64.
+func determinantSign(m [][]int) int {
+	n := len(m)
+	a := make([][]float64, n)
+	for i := range m {
+		a[i] = make([]float64, n)
+		for j, value := range m[i] {
+			a[i][j] = float64(value)
+		}
+	}
+
+	sign := 1
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-9 {
+			return 0
+		}
+		if pivot != col {
+			a[pivot], a[col] = a[col], a[pivot]
+			sign = -sign
+		}
+		if a[col][col] < 0 {
+			sign = -sign
+		}
+		for row := col + 1; row < n; row++ {
+			factor := a[row][col] / a[col][col]
+			for k := col; k < n; k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+		}
+	}
+	return sign
+}
+
+// favorsA informa si la traza de metrics favorece a la rama A frente a threshold: siempre si lo
+// supera, nunca si queda por debajo y, si lo iguala, según tie (a, b o el volado de random).
+func favorsA(metrics ConditionMetrics, threshold int64, tie string) bool {
+	switch {
+	case metrics.Trace > threshold:
+		return true
+	case metrics.Trace < threshold:
+		return false
+	case tie == TieB:
+		return false
+	case tie == TieRandom:
+		return metrics.TieCoin
+	}
+	return true
+}
+
+// multiMetricSelector elige por mayoría entre tres votos a favor de la rama A: la traza alcanza el
+// umbral (un empate se resuelve con tie), el determinante del producto es positivo y la suma de
+// elementos alcanza su valor esperado (2·n²·(matrixMax-1)/2, ya que cada elemento es uniforme entre
+// 0 y matrixMax-1). Con dos votos o más gana A.
+func multiMetricSelector(threshold int64, tie string, n, matrixMax int) WinnerSelector {
+	expectedSum := int64(n) * int64(n) * int64(matrixMax-1)
+	return func(metrics ConditionMetrics) string {
+		votes := 0
+		if favorsA(metrics, threshold, tie) {
+			votes++
+		}
+		if metrics.DetSign > 0 {
+			votes++
+		}
+		if metrics.ElementSum >= expectedSum {
+			votes++
+		}
+		if votes >= 2 {
+			return branchA
+		}
+		return branchB
+	}
+}
+
+// paritySelector elige la rama A cuando la traza es par y la B cuando es impar, sin depender del
+// umbral; como la paridad de la traza es prácticamente aleatoria, reparte las corridas entre ambas
+// ramas sea cual sea la escala de las matrices.
+func paritySelector() WinnerSelector {
+	return func(metrics ConditionMetrics) string {
+		if metrics.Trace%2 == 0 {
+			return branchA
+		}
+		return branchB
+	}
+}
+
+// NewSelector devuelve el selector de la política indicada; una política desconocida usa el
+// umbral y es rechazada después por ValidateConfig.
+func NewSelector(cfg Config) WinnerSelector {
+	switch cfg.Policy {
+	case PolicyMulti:
+		return multiMetricSelector(cfg.Threshold, cfg.Tie, cfg.MatrixSize, cfg.MatrixMax)
+	case PolicyParity:
+		return paritySelector()
+	}
+	return thresholdSelector(cfg.Threshold, cfg.Tie)
+}
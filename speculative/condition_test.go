@@ -0,0 +1,335 @@
+package speculative
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMatrixMetrics(t *testing.T) {
+	m1 := [][]int{{1, 2}, {3, 4}}
+	m2 := [][]int{{5, 6}, {7, 8}}
+	// m1·m2 = [[19 22] [43 50]]; det(m1)·det(m2) = (-2)·(-2) = 4.
+	want := ConditionMetrics{Trace: 69, DetSign: 1, ElementSum: 36}
+	if got := matrixMetrics(m1, m2); got != want {
+		t.Errorf("matrixMetrics = %+v, want %+v", got, want)
+	}
+}
+
+func TestDeterminantSign(t *testing.T) {
+	tests := []struct {
+		name string
+		m    [][]int
+		want int
+	}{
+		{"identity", [][]int{{1, 0}, {0, 1}}, 1},
+		{"swapped rows", [][]int{{0, 1}, {1, 0}}, -1},
+		{"singular", [][]int{{1, 2}, {2, 4}}, 0},
+		{"negative", [][]int{{1, 2}, {3, 4}}, -1},
+		{"3x3 positive", [][]int{{2, 0, 1}, {1, 3, 2}, {1, 1, 2}}, 1},
+		{"3x3 zero column", [][]int{{0, 1, 2}, {0, 3, 4}, {0, 5, 6}}, 0},
+	}
+	for _, tt := range tests {
+		if got := determinantSign(tt.m); got != tt.want {
+			t.Errorf("%s: determinantSign = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestMultiMetricSelector recorre las combinaciones de votos con umbral 100 y matrices 2×2 de
+// elementos entre 0 y 9, cuya suma esperada es 2·2²·9/2 = 36.
+func TestMultiMetricSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		tie     string
+		metrics ConditionMetrics
+		want    string
+	}{
+		{"three votes", TieA, ConditionMetrics{Trace: 200, DetSign: 1, ElementSum: 40}, branchA},
+		{"trace only", TieA, ConditionMetrics{Trace: 200, DetSign: -1, ElementSum: 0}, branchB},
+		{"determinant and sum", TieA, ConditionMetrics{Trace: 50, DetSign: 1, ElementSum: 36}, branchA},
+		{"sum only", TieA, ConditionMetrics{Trace: 50, DetSign: 0, ElementSum: 100}, branchB},
+		{"no votes", TieA, ConditionMetrics{Trace: 0, DetSign: -1, ElementSum: 0}, branchB},
+		{"tie a counts for A", TieA, ConditionMetrics{Trace: 100, DetSign: 1, ElementSum: 0}, branchA},
+		{"tie b counts for B", TieB, ConditionMetrics{Trace: 100, DetSign: 1, ElementSum: 0}, branchB},
+	}
+	for _, tt := range tests {
+		selector := multiMetricSelector(100, tt.tie, 2, DefaultMatrixMax)
+		if got := selector(tt.metrics); got != tt.want {
+			t.Errorf("%s: winner %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewSelectorDefaultsToThreshold(t *testing.T) {
+	cfg := testConfig()
+	cfg.Threshold = 100
+	cfg.Policy = ""
+	selector := NewSelector(cfg)
+	if got := selector(ConditionMetrics{Trace: 101, DetSign: -1}); got != branchA {
+		t.Errorf("trace above threshold: winner %s, want A", got)
+	}
+	if got := selector(ConditionMetrics{Trace: 99, DetSign: 1, ElementSum: 1000}); got != branchB {
+		t.Errorf("trace below threshold: winner %s, want B", got)
+	}
+}
+
+// TestConditionErrorStopsBranches hace fallar la condición de una corrida especulativa, con un
+// error y con un pánico, mientras las ramas esperan su cancelación, y comprueba que la corrida
+// devuelve el error solo después de que todas las ramas terminaron, sin dejar goroutines en curso.
+func TestConditionErrorStopsBranches(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"error", func(c *Config) { c.MatrixFile = filepath.Join(t.TempDir(), "no-existe.txt") }, "no-existe.txt"},
+		// Con n negativo la generación de las matrices entra en pánico.
+		{"panic", func(c *Config) { c.MatrixSize = -1 }, "la evaluación de la condición falló"},
+	}
+	var returned atomic.Int32
+	work := func(ctx context.Context) (BranchOutput, error) {
+		defer returned.Add(1)
+		return blockingWork(ctx)
+	}
+	branches := []NamedBranch{{Name: branchA, Work: work}, {Name: branchB, Work: work}}
+	for _, tt := range tests {
+		returned.Store(0)
+		cfg := testConfig()
+		cfg.Selector = NewSelector(cfg)
+		tt.modify(&cfg)
+
+		before := runtime.NumGoroutine()
+		_, err := runSpeculative(context.Background(), cfg, 1, branches)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want it to contain %q", tt.name, err, tt.wantErr)
+		}
+		if n := returned.Load(); n != int32(len(branches)) {
+			t.Errorf("%s: %d of %d branches had returned when the run failed", tt.name, n, len(branches))
+		}
+		// Las ramas ya entregaron su resultado; se les da un momento para terminar de retornar.
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("%s: %d goroutines before the run, %d after", tt.name, before, after)
+		}
+	}
+}
+
+func TestPoliciesOverTraces(t *testing.T) {
+	policies := []struct {
+		policy string
+		want   func(trace int64) string
+	}{
+		{PolicyThreshold, func(trace int64) string {
+			if trace >= 100 {
+				return branchA
+			}
+			return branchB
+		}},
+		{PolicyParity, func(trace int64) string {
+			if trace%2 == 0 {
+				return branchA
+			}
+			return branchB
+		}},
+	}
+	traces := []int64{math.MinInt64, -101, -3, -2, -1, 0, 1, 2, 98, 99, 100, 101, 102, 1 << 40, math.MaxInt64}
+	for _, p := range policies {
+		cfg := testConfig()
+		cfg.Threshold = 100
+		cfg.Policy = p.policy
+		selector := NewSelector(cfg)
+		for _, trace := range traces {
+			if got, want := selector(ConditionMetrics{Trace: trace}), p.want(trace); got != want {
+				t.Errorf("policy %s, trace %d: winner %s, want %s", p.policy, trace, got, want)
+			}
+		}
+	}
+}
+
+// TestMeanTraceScalesWithMatrixMax compara la traza media con la esperada: cada uno de los n²
+// productos m1[i][k]·m2[k][i] tiene media ((matrixMax-1)/2)², que para rangos grandes crece como
+// matrixMax².
+func TestMeanTraceScalesWithMatrixMax(t *testing.T) {
+	const n, trials = 20, 200
+	rng := rand.New(rand.NewSource(1))
+	for _, matrixMax := range []int{1, 2, 10, 100, 1000} {
+		var sum float64
+		for i := 0; i < trials; i++ {
+			sum += float64(productTrace(randomMatrices(n, matrixMax, rng)))
+		}
+		mean := sum / trials
+		half := float64(matrixMax-1) / 2
+		want := n * n * half * half
+		if math.Abs(mean-want) > 0.05*want {
+			t.Errorf("matrix-max %d: mean trace %.1f, want about %.1f", matrixMax, mean, want)
+		}
+	}
+
+	cfg := testConfig()
+	cfg.MatrixMax = 0
+	if err := ValidateConfig(cfg); err == nil {
+		t.Error("matrix-max 0 was accepted")
+	}
+}
+
+func TestThresholdTie(t *testing.T) {
+	const threshold = 100
+	tests := []struct {
+		tie   string
+		trace int64
+		coin  bool
+		want  string
+	}{
+		{TieA, 99, false, branchB},
+		{TieA, 100, false, branchA},
+		{TieA, 101, false, branchA},
+		{TieB, 99, false, branchB},
+		{TieB, 100, false, branchB},
+		{TieB, 101, false, branchA},
+		{TieRandom, 99, true, branchB},
+		{TieRandom, 100, true, branchA},
+		{TieRandom, 100, false, branchB},
+		{TieRandom, 101, false, branchA},
+	}
+	for _, tt := range tests {
+		selector := thresholdSelector(threshold, tt.tie)
+		if got := selector(ConditionMetrics{Trace: tt.trace, TieCoin: tt.coin}); got != tt.want {
+			t.Errorf("tie %s, trace %d, coin %v: winner %s, want %s", tt.tie, tt.trace, tt.coin, got, tt.want)
+		}
+	}
+}
+
+// TestTieCoinIsReproducible comprueba que el volado de -tie random depende solo de la semilla y de
+// la corrida, y que a lo largo de varias corridas sale de ambos lados.
+func TestTieCoinIsReproducible(t *testing.T) {
+	cfg := testConfig()
+	cfg.Tie = TieRandom
+	sides := make(map[bool]int)
+	for run := 1; run <= 64; run++ {
+		first, err := evaluateCondition(cfg, run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := evaluateCondition(cfg, run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first != again {
+			t.Fatalf("run %d: %+v then %+v for the same seed", run, first, again)
+		}
+		sides[first.TieCoin]++
+	}
+	if sides[true] == 0 || sides[false] == 0 {
+		t.Errorf("coin sides over 64 runs = %v, want both", sides)
+	}
+}
+
+func TestEarlyTraceStopsOnceDecided(t *testing.T) {
+	const n = 50
+	m1, m2 := randomMatrices(n, DefaultMatrixMax, rand.New(rand.NewSource(3)))
+	full := productTrace(m1, m2)
+
+	trace, rows := earlyTrace(m1, m2, 100, DefaultMatrixMax)
+	if rows >= n || trace <= 100 {
+		t.Errorf("threshold 100: stopped after %d of %d rows with trace %d, want fewer rows and a trace above 100", rows, n, trace)
+	}
+	if want := partialTrace(m1, m2, rows); trace != want {
+		t.Errorf("threshold 100: partial trace %d, sum of the first %d rows %d", trace, rows, want)
+	}
+
+	// Un umbral inalcanzable también queda decidido sin recorrer todas las filas.
+	if _, rows := earlyTrace(m1, m2, full*100, DefaultMatrixMax); rows >= n {
+		t.Errorf("unreachable threshold: computed %d of %d rows", rows, n)
+	}
+	// Con el umbral igual a la traza completa hace falta la última fila.
+	if trace, rows := earlyTrace(m1, m2, full, DefaultMatrixMax); rows != n || trace != full {
+		t.Errorf("threshold = trace: %d rows, trace %d, want %d and %d", rows, trace, n, full)
+	}
+
+	cfg := testConfig()
+	cfg.MatrixSize = n
+	cfg.EarlyCancel = true
+	cfg.Threshold = 100
+	metrics, err := evaluateCondition(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Rows >= n {
+		t.Errorf("evaluateCondition with -early-cancel computed %d of %d rows", metrics.Rows, n)
+	}
+}
+
+// partialTrace suma los términos de la traza de m1·m2 de las primeras rows filas de m1.
+func partialTrace(m1, m2 [][]int, rows int) int64 {
+	var trace int64
+	for i := 0; i < rows; i++ {
+		for k := range m1[i] {
+			trace += int64(m1[i][k]) * int64(m2[k][i])
+		}
+	}
+	return trace
+}
+
+// TestConditionReps comprueba que -condition-reps promedia, con redondeo, la traza de la corrida y
+// las de los reps-1 pares siguientes del mismo generador, que condition_duration_ms abarca todas
+// (medida con un reloj controlado, es un único intervalo de la corrida) y que su costo crece
+// aproximadamente con reps.
+func TestConditionReps(t *testing.T) {
+	const reps = 4
+	cfg := testConfig()
+	cfg.MatrixSize = 150
+	cfg.ConditionReps = reps
+
+	rng := runRNG(cfg.Seed, 1)
+	var total int64
+	for i := 0; i < reps; i++ {
+		total += productTrace(randomMatrices(cfg.MatrixSize, cfg.MatrixMax, rng))
+	}
+	metrics, err := evaluateCondition(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(math.Round(float64(total) / reps)); metrics.Trace != want {
+		t.Errorf("averaged trace %d, want %d", metrics.Trace, want)
+	}
+
+	clocked := cfg
+	clocked.Clock = &stepClock{now: time.Unix(0, 0), step: time.Millisecond}
+	clocked.Selector = NewSelector(clocked)
+	branches := []NamedBranch{{Name: branchA, Work: fixedWork(1, "a")}, {Name: branchB, Work: fixedWork(2, "b")}}
+	run, err := runSequential(context.Background(), clocked, 1, branches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ConditionValue != metrics.Trace || run.ConditionDuration != time.Millisecond {
+		t.Errorf("condition %d in %v, want %d in the single 1ms interval", run.ConditionValue, run.ConditionDuration, metrics.Trace)
+	}
+
+	// El mínimo de varias mediciones descarta las interrupciones del planificador.
+	fastest := func(cfg Config) time.Duration {
+		best := time.Duration(math.MaxInt64)
+		for i := 0; i < 5; i++ {
+			start := time.Now()
+			if _, err := evaluateCondition(cfg, 1); err != nil {
+				t.Fatal(err)
+			}
+			best = min(best, time.Since(start))
+		}
+		return best
+	}
+	single := cfg
+	single.ConditionReps = 1
+	if one, many := fastest(single), fastest(cfg); many < 2*one {
+		t.Errorf("%d reps took %v and one %v; want roughly %d times as long", reps, many, one, reps)
+	}
+}
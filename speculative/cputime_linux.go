@@ -0,0 +1,19 @@
+//go:build linux
+
+package speculative
+
+import (
+	"syscall"
+	"time"
+)
+
+// threadCPUTime devuelve el tiempo de CPU (usuario más sistema) consumido por el hilo del sistema
+// operativo que ejecuta la goroutine actual. Solo es el de la goroutine si esta está fijada a su
+// hilo con runtime.LockOSThread; el segundo valor es false si la medición falló.
+func threadCPUTime() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_THREAD, &usage); err != nil {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
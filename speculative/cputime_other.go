@@ -0,0 +1,11 @@
+//go:build !linux
+
+package speculative
+
+import "time"
+
+// threadCPUTime no está disponible fuera de Linux, donde no hay una medición por hilo; quien la
+// usa recurre a la duración de reloj y lo indica en cpu_time_source.
+func threadCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,136 @@
+package speculative
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// parseDifficultySweep interpreta la lista de -difficulty-sweep: dificultades positivas separadas
+// por comas, en el orden en que se ejecutan.
+func parseDifficultySweep(spec string) ([]int, error) {
+	return parsePositiveList(spec, "dificultad inválida")
+}
+
+// RunDifficultySweep ejecuta la comparación completa (cfg.Runs corridas por estrategia) para cada
+// dificultad de -difficulty-sweep, reconstruyendo las ramas con esa dificultad, y escribe en
+// cfg.DifficultyFile una fila difficulty,avg_spec_ms,avg_seq_ms,speedup,avg_nonce,a_finished por
+// dificultad, en cuanto termina. avg_nonce es el nonce promedio de las a_finished ramas A que
+// terminaron, que crece con la dificultad, o n/a si ninguna terminó. Por consola muestra las mismas
+// filas y la primera dificultad cuyo speedup supera 1; al final agrega las medias de los speedups
+// como RunSweep. Si ctx termina, el archivo conserva las dificultades completadas y se devuelve
+// ErrInterrupted.
+func RunDifficultySweep(ctx context.Context, cfg Config) error {
+	difficulties, err := parseDifficultySweep(cfg.DifficultySweep)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(Directory(cfg.DifficultyFile), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(cfg.DifficultyFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writeRow := func(record []string) error {
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	if err := writeRow([]string{"difficulty", "avg_spec_ms", "avg_seq_ms", "speedup", "avg_nonce", "a_finished"}); err != nil {
+		return err
+	}
+
+	stdout := ConsoleOutput(cfg)
+	var engine Engine
+	if cfg.Verbose {
+		engine.OnRun = func(run ExecutionRun) error {
+			PrintRunDetail(os.Stdout, run)
+			return nil
+		}
+	}
+	crossover := 0
+	speedups := make([]float64, 0, len(difficulties))
+	for _, difficulty := range difficulties {
+		difficultyCfg := cfg
+		difficultyCfg.PowDifficulty = difficulty
+		// Los subprocesos de rama reciben los argumentos originales; la última -difficulty prevalece.
+		difficultyCfg.WorkerArgs = append(slices.Clone(cfg.WorkerArgs), fmt.Sprintf("-difficulty=%d", difficulty))
+
+		// Con Branches nil, Engine arma las ramas de la nueva dificultad.
+		report, err := engine.RunContext(ctx, difficultyCfg)
+		if errors.Is(err, ErrInterrupted) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("difficulty=%d: %w", difficulty, err)
+		}
+
+		summary := report.Summary
+		avgNonce, finished := averageNonce(report.Speculative, report.Sequential)
+		nonceCell := "n/a"
+		if finished > 0 {
+			nonceCell = floatToString(avgNonce)
+		}
+		if err := writeRow([]string{
+			strconv.Itoa(difficulty),
+			floatToString(Milliseconds(summary.AvgSpeculative)),
+			floatToString(Milliseconds(summary.Baseline)),
+			FormatSpeedup(summary.Speedup),
+			nonceCell,
+			strconv.Itoa(finished),
+		}); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "difficulty=%d: especulativo %s, secuencial %s, speedup %s, nonce promedio %s (%d ramas A terminadas)\n",
+			difficulty, FormatDuration(summary.AvgSpeculative), FormatDuration(summary.Baseline), FormatSpeedup(summary.Speedup), nonceCell, finished)
+		if crossover == 0 && summary.Speedup > 1 {
+			crossover = difficulty
+		}
+		speedups = append(speedups, summary.Speedup)
+	}
+	if err := reportSpeedupMeans(file, stdout, speedups); err != nil {
+		return err
+	}
+
+	if crossover > 0 {
+		fmt.Fprintf(stdout, "Punto de cruce: primera dificultad con speedup > 1 en difficulty=%d\n", crossover)
+	} else {
+		fmt.Fprintln(stdout, "Punto de cruce: la estrategia especulativa no superó a la secuencial en ninguna dificultad")
+	}
+	fmt.Fprintf(stdout, "Barrido almacenado en: %s\n", cfg.DifficultyFile)
+	return file.Close()
+}
+
+// averageNonce promedia result_numeric (el nonce encontrado) de las ramas A no canceladas ni
+// fallidas de todas las corridas y devuelve también cuántas son; si ninguna terminó, el promedio
+// es 0 y no tiene sentido informarlo.
+func averageNonce(runSets ...[]ExecutionRun) (float64, int) {
+	var sum float64
+	count := 0
+	for _, runs := range runSets {
+		for _, run := range runs {
+			for _, branch := range run.Branches {
+				if branch.Name != branchA || branch.Cancelled || branch.Err != nil {
+					continue
+				}
+				sum += float64(branch.Numeric)
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return sum / float64(count), count
+}
@@ -0,0 +1,89 @@
+package speculative
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseDifficultySweep(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr string
+	}{
+		{"1,3", []int{1, 3}, ""},
+		{" 2 , ,4 ", []int{2, 4}, ""},
+		{"", nil, "la lista está vacía"},
+		{",", nil, "la lista está vacía"},
+		{"1,0", nil, `dificultad inválida "0"`},
+		{"-2", nil, `dificultad inválida "-2"`},
+		{"1,dos", nil, `dificultad inválida "dos"`},
+	}
+	for _, tt := range tests {
+		got, err := parseDifficultySweep(tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: err = %v, want it to contain %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, %v; want %v", tt.spec, got, err, tt.want)
+		}
+	}
+}
+
+func TestRunDifficultySweepNonceGrows(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 2
+	cfg.Threshold = 0 // gana siempre A, así que todas las corridas informan un nonce
+	cfg.DifficultySweep = "1,3"
+	cfg.DifficultyFile = filepath.Join(t.TempDir(), "sweep", "dificultad.csv")
+	cfg.Quiet = true
+	if err := RunDifficultySweep(context.Background(), cfg); err != nil {
+		t.Fatalf("RunDifficultySweep: %v", err)
+	}
+
+	file, err := os.Open(cfg.DifficultyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("%d records, want a header and 2 rows: %v", len(records), records)
+	}
+	if got := strings.Join(records[0], ","); got != "difficulty,avg_spec_ms,avg_seq_ms,speedup,avg_nonce,a_finished" {
+		t.Errorf("header = %s", got)
+	}
+	var nonces []float64
+	for i, difficulty := range []string{"1", "3"} {
+		row := records[i+1]
+		if row[0] != difficulty {
+			t.Errorf("row %d: difficulty = %s, want %s", i+1, row[0], difficulty)
+		}
+		nonce, err := strconv.ParseFloat(row[4], 64)
+		if err != nil {
+			t.Fatalf("row %d: avg_nonce %q is not a number", i+1, row[4])
+		}
+		// Las dos corridas especulativas y las dos secuenciales ejecutan A hasta el final.
+		if row[5] != "4" {
+			t.Errorf("row %d: a_finished = %s, want 4", i+1, row[5])
+		}
+		nonces = append(nonces, nonce)
+	}
+	if nonces[1] <= nonces[0] {
+		t.Errorf("avg_nonce %v at difficulty 3 is not larger than %v at difficulty 1", nonces[1], nonces[0])
+	}
+}
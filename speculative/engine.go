@@ -0,0 +1,80 @@
+package speculative
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// SpeculativeReport es el resultado de Engine.Run: las corridas medidas de cada estrategia y los
+// agregados calculados sobre ellas.
+type SpeculativeReport struct {
+	// Config es la configuración efectiva, con la semilla y el selector ya resueltos.
+	Config      Config
+	Speculative []ExecutionRun
+	// Sequential queda vacío cuando la línea base es externa (ReferenceMs > 0).
+	Sequential []ExecutionRun
+	Summary    Summary
+}
+
+// Engine ejecuta la simulación completa (ambas estrategias y el resumen) sin imprimir nada ni
+// escribir archivos; la salida queda a cargo de quien lo invoca.
+type Engine struct {
+	// Branches son las ramas a ejecutar; si es nil se arman a partir de la configuración, igual
+	// que en la línea de comandos.
+	Branches []NamedBranch
+	// OnRun, si no es nil, recibe cada corrida medida en cuanto termina (primero las especulativas,
+	// luego las secuenciales, o alternadas con Interleave); si devuelve un error, la ejecución se
+	// detiene con ese error.
+	OnRun func(run ExecutionRun) error
+}
+
+// Run ejecuta la simulación con cfg hasta completarla.
+func (e Engine) Run(cfg Config) (SpeculativeReport, error) {
+	return e.RunContext(context.Background(), cfg)
+}
+
+// RunContext es Run con un contexto: si ctx termina, devuelve el reporte con las corridas
+// completadas hasta ese momento junto con ErrInterrupted. Lo mismo ocurre, con ErrOutputLimit,
+// cuando OnRun lo devuelve porque las métricas alcanzaron -max-output-bytes. Una Seed 0 se
+// reemplaza por una basada en la hora y un Selector nil por el de cfg.Policy.
+func (e Engine) RunContext(ctx context.Context, cfg Config) (SpeculativeReport, error) {
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	cfg, err := LoadMatrixFile(cfg)
+	if err != nil {
+		return SpeculativeReport{}, err
+	}
+	if cfg.Selector == nil {
+		cfg.Selector = NewSelector(cfg)
+	}
+	if err := ValidateConfig(cfg); err != nil {
+		return SpeculativeReport{}, err
+	}
+
+	branches := e.Branches
+	if branches == nil {
+		if branches, err = BuildBranchWorkload(cfg); err != nil {
+			return SpeculativeReport{}, err
+		}
+	}
+
+	report := SpeculativeReport{Config: cfg}
+	if cfg.Interleave {
+		report.Speculative, report.Sequential, err = collectInterleaved(ctx, cfg, branches, e.OnRun)
+	} else {
+		report.Speculative, err = collectRuns(ctx, cfg, ModeSpeculative, branches, e.OnRun)
+		// Con -reference-ms la línea base es externa y no hace falta medir la estrategia secuencial.
+		if err == nil && cfg.ReferenceMs <= 0 {
+			if err = cooldown(ctx, cfg); err == nil {
+				report.Sequential, err = collectRuns(ctx, cfg, ModeSequential, branches, e.OnRun)
+			}
+		}
+	}
+	if err != nil && !errors.Is(err, ErrInterrupted) && !errors.Is(err, ErrOutputLimit) {
+		return report, err
+	}
+	report.Summary = buildSummary(cfg, report.Speculative, report.Sequential)
+	return report, err
+}
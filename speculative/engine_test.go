@@ -0,0 +1,154 @@
+package speculative
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testConfig devuelve una configuración válida y rápida: la de DefaultConfig con tamaños pequeños y
+// una semilla fija. Deja Selector en nil, que Engine reemplaza por el de Policy; quien llame
+// directamente a CollectRuns debe asignarlo.
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.MatrixSize = 20
+	cfg.Runs = 3
+	cfg.PowDifficulty = 2
+	cfg.PrimesLimit = 5000
+	cfg.PrimesWorkers = 1
+	cfg.Seed = 42
+	return cfg
+}
+
+func TestEngineRun(t *testing.T) {
+	cfg := testConfig()
+	cfg.Threshold = 0 // toda traza es >= 0, así que gana siempre la rama A
+
+	var observed []ExecutionRun
+	engine := Engine{OnRun: func(run ExecutionRun) error {
+		observed = append(observed, run)
+		return nil
+	}}
+	report, err := engine.Run(cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if report.Config.Seed != cfg.Seed {
+		t.Errorf("Config.Seed = %d, want %d", report.Config.Seed, cfg.Seed)
+	}
+	if len(observed) != 2*cfg.Runs {
+		t.Errorf("OnRun called %d times, want %d", len(observed), 2*cfg.Runs)
+	}
+	for mode, runs := range map[string][]ExecutionRun{ModeSpeculative: report.Speculative, ModeSequential: report.Sequential} {
+		if len(runs) != cfg.Runs {
+			t.Fatalf("%s: %d runs, want %d", mode, len(runs), cfg.Runs)
+		}
+		for i, run := range runs {
+			if run.Mode != mode || run.RunIndex != i+1 {
+				t.Errorf("%s run %d: mode %q index %d", mode, i+1, run.Mode, run.RunIndex)
+			}
+			if run.Winner != branchA {
+				t.Errorf("%s run %d: winner %q, want %q", mode, run.RunIndex, run.Winner, branchA)
+			}
+			if run.TotalDuration <= 0 {
+				t.Errorf("%s run %d: total duration %v", mode, run.RunIndex, run.TotalDuration)
+			}
+		}
+	}
+
+	summary := report.Summary
+	if summary.WinsSpeculative[branchA] != cfg.Runs || summary.WinsSequential[branchA] != cfg.Runs {
+		t.Errorf("wins = %v / %v, want %d for A", summary.WinsSpeculative, summary.WinsSequential, cfg.Runs)
+	}
+	if math.IsNaN(summary.Speedup) || summary.Speedup <= 0 {
+		t.Errorf("Speedup = %v, want a positive value", summary.Speedup)
+	}
+	if summary.AvgSpeculative != AverageDuration(report.Speculative) {
+		t.Errorf("AvgSpeculative = %v, want %v", summary.AvgSpeculative, AverageDuration(report.Speculative))
+	}
+}
+
+func TestEngineRunRejectsInvalidConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 0
+	if _, err := (Engine{}).Run(cfg); err == nil {
+		t.Fatal("Run with runs=0: expected an error")
+	}
+}
+
+func TestEngineRunReportsProgress(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 4
+	cfg.Warmup = 2 // las corridas de calentamiento no se informan
+	type call struct {
+		mode        string
+		done, total int
+		eta         time.Duration
+	}
+	var calls []call
+	cfg.Progress = func(mode string, done, total int, eta time.Duration) {
+		calls = append(calls, call{mode, done, total, eta})
+	}
+	if _, err := (Engine{}).Run(cfg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(calls) != 2*cfg.Runs {
+		t.Fatalf("Progress called %d times, want %d", len(calls), 2*cfg.Runs)
+	}
+	done := make(map[string]int)
+	for _, c := range calls {
+		done[c.mode]++
+		if c.done != done[c.mode] || c.total != cfg.Runs || c.eta < 0 {
+			t.Errorf("Progress(%s, %d, %d, %v): want done=%d total=%d and a non-negative ETA", c.mode, c.done, c.total, c.eta, done[c.mode], cfg.Runs)
+		}
+	}
+	if done[ModeSpeculative] != cfg.Runs || done[ModeSequential] != cfg.Runs {
+		t.Errorf("calls per mode = %v, want %d each", done, cfg.Runs)
+	}
+	if last := calls[len(calls)-1]; last.eta != 0 {
+		t.Errorf("last ETA = %v, want 0", last.eta)
+	}
+}
+
+func TestCooldownWallTime(t *testing.T) {
+	const cooldown = 30 * time.Millisecond
+	batch := func(cfg Config) (total, afterLast time.Duration) {
+		t.Helper()
+		var lastRun time.Time
+		engine := Engine{OnRun: func(ExecutionRun) error {
+			lastRun = time.Now()
+			return nil
+		}}
+		start := time.Now()
+		if _, err := engine.Run(cfg); err != nil {
+			t.Fatal(err)
+		}
+		end := time.Now()
+		return end.Sub(start), end.Sub(lastRun)
+	}
+
+	cfg := testConfig()
+	base, _ := batch(cfg)
+	cfg.Cooldown = cooldown
+	withCooldown, afterLast := batch(cfg)
+
+	// Una pausa entre corridas de cada estrategia y otra entre ambas fases, ninguna tras la última.
+	pauses := time.Duration(2*(cfg.Runs-1) + 1)
+	if withCooldown < pauses*cooldown {
+		t.Errorf("batch with cooldown took %v, want at least %d × %v", withCooldown, pauses, cooldown)
+	}
+	if extra := withCooldown - base; extra < time.Duration(cfg.Runs-1)*cooldown {
+		t.Errorf("cooldown added %v to the batch, want at least (runs-1) × %v", extra, cooldown)
+	}
+	if afterLast >= cooldown {
+		t.Errorf("batch returned %v after its last run, want no cooldown after it", afterLast)
+	}
+}
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	if err := ValidateConfig(DefaultConfig()); err != nil {
+		t.Errorf("ValidateConfig(DefaultConfig()) = %v", err)
+	}
+}
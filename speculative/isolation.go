@@ -0,0 +1,163 @@
+package speculative
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const (
+	IsolationGoroutine = "goroutine"
+	IsolationProcess   = "process"
+
+	// branchWorkerFlag es la flag oculta con que el proceso principal lanza un subproceso de rama.
+	branchWorkerFlag = "-branch-worker"
+
+	// workerCancelGrace es cuánto espera el proceso principal a que un subproceso cancelado informe
+	// su resultado parcial antes de matarlo.
+	workerCancelGrace = time.Second
+)
+
+// workerResult es el mensaje JSON que un subproceso de rama escribe en su salida estándar.
+type workerResult struct {
+	Numeric int64  `json:"numeric"`
+	Detail  string `json:"detail"`
+	// AllocPerPrime se mide dentro del subproceso, sin interferencia de las demás ramas.
+	AllocPerPrime float64 `json:"alloc_per_prime,omitempty"`
+	AllocBytes    uint64  `json:"alloc_bytes,omitempty"`
+	Hashes        int64   `json:"hashes,omitempty"`
+	Error         string  `json:"error,omitempty"`
+	// Exhausted conserva la identidad de ErrExhausted, que no aborta el lote.
+	Exhausted bool `json:"exhausted,omitempty"`
+	// Cancelled indica que la rama se detuvo porque el proceso principal la canceló.
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// ExtractBranchWorker separa la flag oculta -branch-worker del resto de los argumentos, para que
+// no aparezca en la ayuda de la línea de comandos. Devuelve el nombre de la rama a ejecutar (vacío
+// si el proceso no es un subproceso de rama) y los argumentos restantes.
+func ExtractBranchWorker(args []string) (string, []string) {
+	var worker string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == branchWorkerFlag && i+1 < len(args):
+			worker = args[i+1]
+			i++
+		case strings.HasPrefix(arg, branchWorkerFlag+"="):
+			worker = strings.TrimPrefix(arg, branchWorkerFlag+"=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return worker, rest
+}
+
+// processBranchWork ejecuta la rama name en un subproceso de este mismo binario, que recibe los
+// mismos argumentos que el proceso principal más la semilla efectiva, para que las ramas
+// aleatorias coincidan con el modo goroutine. Para cancelarla se cierra la entrada estándar del
+// subproceso, que detiene la rama e informa su resultado parcial (el mismo detalle que daría en
+// modo goroutine); si no termina en workerCancelGrace, se lo mata. El tiempo de CPU es siempre el
+// del rusage del subproceso, también cuando se lo canceló.
+func processBranchWork(name string, args []string, seed int64) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		executable, err := os.Executable()
+		if err != nil {
+			return BranchOutput{}, err
+		}
+
+		cmdArgs := append([]string{branchWorkerFlag + "=" + name}, args...)
+		cmdArgs = append(cmdArgs, fmt.Sprintf("-seed=%d", seed))
+		cmd := exec.CommandContext(ctx, executable, cmdArgs...)
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return BranchOutput{}, err
+		}
+		cmd.Cancel = stdin.Close
+		cmd.WaitDelay = workerCancelGrace
+		out, err := cmd.Output()
+		output := BranchOutput{CPUTimeSource: cpuTimeUnavailable}
+		if cmd.ProcessState != nil {
+			output.CPUTime = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+			output.CPUTimeSource = cpuTimeProcess
+		}
+
+		var result workerResult
+		decodeErr := json.Unmarshal(out, &result)
+		if decodeErr == nil {
+			output.Numeric, output.Detail, output.AllocPerPrime, output.AllocBytes, output.Hashes =
+				result.Numeric, result.Detail, result.AllocPerPrime, result.AllocBytes, result.Hashes
+		}
+		if ctx.Err() != nil {
+			// Si el subproceso no alcanzó a responder (o se lo mató) el resultado queda vacío.
+			return output, cancelledError(ctx)
+		}
+		if err != nil {
+			return BranchOutput{}, fmt.Errorf("subproceso de la rama %s: %w", name, err)
+		}
+		if decodeErr != nil {
+			return BranchOutput{}, fmt.Errorf("respuesta inválida del subproceso de la rama %s: %w", name, decodeErr)
+		}
+		switch {
+		case result.Exhausted:
+			return output, ErrExhausted
+		case result.Error != "":
+			return output, errors.New(result.Error)
+		}
+		return output, nil
+	}
+}
+
+// RunBranchWorker es el punto de entrada de un subproceso de rama: ejecuta la rama indicada hasta
+// terminar, o hasta que in llegue al final (el proceso principal cierra la entrada estándar para
+// cancelarla), y escribe su resultado como JSON en out.
+func RunBranchWorker(cfg Config, name string, in io.Reader, out io.Writer) error {
+	// El proceso principal decide cuándo detener la rama (matando el subproceso); se ignoran las
+	// señales de detención que la terminal envía a todo el grupo de procesos.
+	if signals, err := ParseStopSignals(cfg.StopSignals); err == nil && len(signals) > 0 {
+		signal.Ignore(signals...)
+	}
+
+	branches, err := buildLocalBranches(cfg)
+	if err != nil {
+		return err
+	}
+	work, ok := findBranch(branches, name)
+	if !ok {
+		return fmt.Errorf("no existe la rama %s", name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		io.Copy(io.Discard, in)
+		cancel()
+	}()
+
+	before := heapAllocated()
+	output, err := work(ctx)
+	result := workerResult{
+		Numeric:       output.Numeric,
+		Detail:        output.Detail,
+		AllocPerPrime: output.AllocPerPrime,
+		AllocBytes:    heapAllocated() - before,
+		Hashes:        output.Hashes,
+	}
+	switch {
+	case errors.Is(err, ErrCancelled):
+		result.Cancelled = true
+	case err != nil:
+		result.Error = err.Error()
+		result.Exhausted = errors.Is(err, ErrExhausted)
+	}
+	return json.NewEncoder(out).Encode(result)
+}
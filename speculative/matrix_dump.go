@@ -0,0 +1,93 @@
+package speculative
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// maxDumpMatrixSize es el mayor n que -dump-matrix acepta sin -force: el archivo tiene n² valores.
+const maxDumpMatrixSize = 1000
+
+// CalcularProductoDeMatrices genera las mismas matrices que CalcularTrazaConRNG y devuelve el
+// producto completo junto con su traza, para verificar el cálculo. Es O(n³) en tiempo y O(n²) en
+// memoria, por lo que la condición de las corridas sigue usando solo la traza.
+func CalcularProductoDeMatrices(n int, rng *rand.Rand) ([][]int64, int64) {
+	m1, m2 := randomMatrices(n, DefaultMatrixMax, rng)
+	return productMatrix(m1, m2)
+}
+
+// productMatrix devuelve m1·m2 y su traza.
+func productMatrix(m1, m2 [][]int) ([][]int64, int64) {
+	n := len(m1)
+	product := make([][]int64, n)
+	var trace int64
+	for i := 0; i < n; i++ {
+		product[i] = make([]int64, n)
+		for k := 0; k < n; k++ {
+			a := int64(m1[i][k])
+			for j := 0; j < n; j++ {
+				product[i][j] += a * int64(m2[k][j])
+			}
+		}
+		trace += product[i][i]
+	}
+	return product, trace
+}
+
+// WriteProductDump escribe en cfg.DumpMatrix el producto de las matrices de la corrida 1 (las
+// mismas que evalúa esa corrida en ambas estrategias), una fila por línea, y al final un comentario
+// con la traza, que debe coincidir con su condition_value. Con -symmetric el producto es A·Aᵀ. Se
+// ejecuta antes de las corridas para no alterar sus tiempos.
+func WriteProductDump(cfg Config) error {
+	var m1, m2 [][]int
+	if cfg.Symmetric {
+		m1 = randomMatrix(cfg.MatrixSize, cfg.MatrixMax, runRNG(cfg.Seed, 1))
+		m2 = transpose(m1)
+	} else {
+		var err error
+		if m1, m2, err = conditionMatrices(cfg, runRNG(cfg.Seed, 1)); err != nil {
+			return err
+		}
+	}
+	product, trace := productMatrix(m1, m2)
+
+	if err := os.MkdirAll(Directory(cfg.DumpMatrix), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(cfg.DumpMatrix)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, row := range product {
+		for j, value := range row {
+			if j > 0 {
+				writer.WriteByte(' ')
+			}
+			writer.WriteString(strconv.FormatInt(value, 10))
+		}
+		writer.WriteByte('\n')
+	}
+	fmt.Fprintf(writer, "# traza=%d\n", trace)
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// transpose devuelve la traspuesta de la matriz cuadrada m.
+func transpose(m [][]int) [][]int {
+	out := make([][]int, len(m))
+	for i := range out {
+		out[i] = make([]int, len(m))
+		for j := range out[i] {
+			out[i][j] = m[j][i]
+		}
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package speculative
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProductTraceIsDiagonalSum(t *testing.T) {
+	for _, n := range []int{1, 2, 5, 30} {
+		product, trace := CalcularProductoDeMatrices(n, rand.New(rand.NewSource(int64(n))))
+		var diagonal int64
+		for i := range product {
+			diagonal += product[i][i]
+		}
+		if trace != diagonal {
+			t.Errorf("n=%d: trace %d, diagonal sum %d", n, trace, diagonal)
+		}
+		// Con la misma semilla la traza coincide con la del camino que solo calcula la traza.
+		if want := productTrace(randomMatrices(n, DefaultMatrixMax, rand.New(rand.NewSource(int64(n))))); trace != want {
+			t.Errorf("n=%d: trace %d, trace-only path %d", n, trace, want)
+		}
+	}
+}
+
+func TestWriteProductDump(t *testing.T) {
+	cfg := testConfig()
+	cfg.MatrixSize = 12
+	cfg.DumpMatrix = filepath.Join(t.TempDir(), "dump", "producto.txt")
+	if err := WriteProductDump(cfg); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(cfg.DumpMatrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != cfg.MatrixSize+1 {
+		t.Fatalf("%d lines, want %d rows and the trace comment", len(lines), cfg.MatrixSize)
+	}
+	var diagonal int64
+	for i, line := range lines[:cfg.MatrixSize] {
+		fields := strings.Fields(line)
+		if len(fields) != cfg.MatrixSize {
+			t.Fatalf("row %d has %d values", i+1, len(fields))
+		}
+		value, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diagonal += value
+	}
+	metrics, err := evaluateCondition(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("# traza=%d", metrics.Trace); lines[cfg.MatrixSize] != want || diagonal != metrics.Trace {
+		t.Errorf("dump ends with %q and its diagonal sums to %d; run 1 condition is %d", lines[cfg.MatrixSize], diagonal, metrics.Trace)
+	}
+
+	cfg.MatrixSize = maxDumpMatrixSize + 1
+	if err := ValidateConfig(cfg); err == nil {
+		t.Error("dump-matrix with n above the limit was accepted without -force")
+	}
+	cfg.Force = true
+	if err := ValidateConfig(cfg); err != nil {
+		t.Errorf("dump-matrix with -force: %v", err)
+	}
+}
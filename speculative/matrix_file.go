@@ -0,0 +1,91 @@
+package speculative
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CalcularTrazaDesdeArchivo lee las dos matrices NxN de path (ver ReadMatrixFile) y devuelve la
+// traza de su producto, para comparar las estrategias sobre un conjunto de datos fijo.
+func CalcularTrazaDesdeArchivo(path string) (int64, error) {
+	m1, m2, err := ReadMatrixFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return productTrace(m1, m2), nil
+}
+
+// LoadMatrixFile lee una sola vez las matrices de cfg.MatrixFile, las guarda en cfg.FileMatrices y
+// ajusta cfg.MatrixSize a su dimensión, para que todas las corridas usen las mismas matrices sin
+// volver a leer el archivo dentro del tiempo medido de la condición. Sin MatrixFile, o si ya se
+// cargaron, devuelve cfg sin cambios.
+func LoadMatrixFile(cfg Config) (Config, error) {
+	if cfg.MatrixFile == "" || cfg.FileMatrices[0] != nil {
+		return cfg, nil
+	}
+	m1, m2, err := ReadMatrixFile(cfg.MatrixFile)
+	if err != nil {
+		return cfg, fmt.Errorf("matrix-file: %w", err)
+	}
+	cfg.FileMatrices = [2][][]int{m1, m2}
+	cfg.MatrixSize = len(m1)
+	return cfg, nil
+}
+
+// ReadMatrixFile lee las matrices de -matrix-file: cada fila es una línea de enteros separados por
+// espacios y las dos matrices se separan con una o más líneas en blanco. Ambas deben ser cuadradas
+// y del mismo tamaño.
+func ReadMatrixFile(path string) ([][]int, [][]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var matrices [][][]int
+	var current [][]int
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			if current != nil {
+				matrices = append(matrices, current)
+				current = nil
+			}
+			continue
+		}
+		row := make([]int, len(fields))
+		for i, field := range fields {
+			value, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s:%d: valor inválido %q", path, line, field)
+			}
+			row[i] = value
+		}
+		current = append(current, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if current != nil {
+		matrices = append(matrices, current)
+	}
+
+	if len(matrices) != 2 {
+		return nil, nil, fmt.Errorf("%s: se esperaban 2 matrices separadas por una línea en blanco, hay %d", path, len(matrices))
+	}
+	for index, matrix := range matrices {
+		for i, row := range matrix {
+			if len(row) != len(matrix) {
+				return nil, nil, fmt.Errorf("%s: la matriz %d no es cuadrada (%d filas, la fila %d tiene %d valores)", path, index+1, len(matrix), i+1, len(row))
+			}
+		}
+	}
+	if len(matrices[0]) != len(matrices[1]) {
+		return nil, nil, fmt.Errorf("%s: las matrices tienen tamaños distintos (%dx%d y %dx%d)", path, len(matrices[0]), len(matrices[0]), len(matrices[1]), len(matrices[1]))
+	}
+	return matrices[0], matrices[1], nil
+}
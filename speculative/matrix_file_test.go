@@ -0,0 +1,100 @@
+package speculative
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMatrixFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "matrices.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// matrix2x2 es el producto de [[1 2] [3 4]] por [[5 6] [7 8]], de traza 19 + 50 = 69.
+const matrix2x2 = "1 2\n3 4\n\n5 6\n7 8\n"
+
+func TestCalcularTrazaDesdeArchivo(t *testing.T) {
+	trace, err := CalcularTrazaDesdeArchivo(writeMatrixFile(t, matrix2x2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace != 69 {
+		t.Errorf("trace = %d, want 69", trace)
+	}
+
+	// Con -matrix-file todas las corridas usan la traza del archivo y no dependen de -n.
+	cfg := testConfig()
+	cfg.MatrixFile = writeMatrixFile(t, "\n"+strings.ReplaceAll(matrix2x2, "\n\n", "\n\n\n")+"\n")
+	cfg.Threshold = 69
+	report, err := Engine{}.Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, run := range append(report.Speculative, report.Sequential...) {
+		if run.ConditionValue != 69 || run.Winner != branchA {
+			t.Errorf("%s run %d: condition %d winner %s, want 69 and A", run.Mode, run.RunIndex, run.ConditionValue, run.Winner)
+		}
+	}
+}
+
+func TestReadMatrixFileRejectsMalformedFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"one matrix", "1 2\n3 4\n", "se esperaban 2 matrices"},
+		{"three matrices", "1\n\n2\n\n3\n", "se esperaban 2 matrices"},
+		{"not a number", "1 2\n3 x\n\n5 6\n7 8\n", `valor inválido "x"`},
+		{"not square", "1 2\n3 4\n\n5 6\n7\n", "la matriz 2 no es cuadrada"},
+		{"different sizes", "1 2\n3 4\n\n5\n", "tamaños distintos (2x2 y 1x1)"},
+		{"empty", "", "hay 0"},
+	}
+	for _, tt := range tests {
+		_, _, err := ReadMatrixFile(writeMatrixFile(t, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want it to contain %q", tt.name, err, tt.wantErr)
+		}
+	}
+	if _, _, err := ReadMatrixFile(filepath.Join(t.TempDir(), "no-existe.txt")); !os.IsNotExist(err) {
+		t.Errorf("missing file: err = %v, want a not-exist error", err)
+	}
+}
+
+// TestMatrixFileIsReadOnce borra el archivo después de cargarlo: las corridas deben usar las
+// matrices ya leídas en lugar de volver a abrirlo en cada condición.
+func TestMatrixFileIsReadOnce(t *testing.T) {
+	cfg := testConfig()
+	cfg.MatrixFile = writeMatrixFile(t, matrix2x2)
+	cfg, err := LoadMatrixFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MatrixSize != 2 {
+		t.Errorf("MatrixSize = %d, want the file's 2", cfg.MatrixSize)
+	}
+	if err := os.Remove(cfg.MatrixFile); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Engine{}.Run(cfg)
+	if err != nil {
+		t.Fatalf("Run after removing the file: %v", err)
+	}
+	for _, run := range append(report.Speculative, report.Sequential...) {
+		if run.ConditionValue != 69 {
+			t.Errorf("%s run %d: condition %d, want 69", run.Mode, run.RunIndex, run.ConditionValue)
+		}
+	}
+
+	// Sin cargar las matrices, la condición informa el error en lugar de leer el archivo.
+	cfg.FileMatrices = [2][][]int{}
+	if _, err := evaluateCondition(cfg, 1); err == nil || !strings.Contains(err.Error(), "no se cargaron") {
+		t.Errorf("unloaded matrices: err = %v, want a not-loaded error", err)
+	}
+}
@@ -0,0 +1,93 @@
+package speculative
+
+import (
+	"math/bits"
+	"math/rand"
+)
+
+// millerRabinDeterministicLimit es la cota bajo la cual los testigos 2, 3, 5 y 7 bastan para que
+// Miller-Rabin no tenga falsos positivos.
+const millerRabinDeterministicLimit = 3215031751
+
+// EsPrimoMillerRabin informa si n es primo con la prueba de Miller-Rabin. Para n menor que
+// 3.215.031.751 usa los testigos 2, 3, 5 y 7, con los que el resultado es exacto; por encima usa
+// rounds testigos aleatorios tomados de rng (con la de runRNG de la corrida, el resultado es
+// reproducible con -seed), y un compuesto pasa la prueba con probabilidad menor que 4^-rounds. Con
+// rng nil se usa la fuente global de math/rand. A diferencia de la división sucesiva, su costo es
+// O(rounds·log³ n).
+func EsPrimoMillerRabin(n int64, rounds int, rng *rand.Rand) bool {
+	if n < 2 {
+		return false
+	}
+	for _, p := range []int64{2, 3, 5, 7} {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+
+	// n-1 = d·2^s con d impar.
+	m := uint64(n)
+	d := m - 1
+	s := bits.TrailingZeros64(d)
+	d >>= uint(s)
+
+	if n < millerRabinDeterministicLimit {
+		for _, a := range []uint64{2, 3, 5, 7} {
+			if !millerRabinWitnessPasses(a, d, s, m) {
+				return false
+			}
+		}
+		return true
+	}
+
+	int63n := rand.Int63n
+	if rng != nil {
+		int63n = rng.Int63n
+	}
+	if rounds < 1 {
+		rounds = 1
+	}
+	for i := 0; i < rounds; i++ {
+		a := 2 + uint64(int63n(n-3))
+		if !millerRabinWitnessPasses(a, d, s, m) {
+			return false
+		}
+	}
+	return true
+}
+
+// millerRabinWitnessPasses informa si n (impar, con n-1 = d·2^s) supera la ronda de Miller-Rabin
+// con el testigo a, es decir, si a no demuestra que n es compuesto.
+func millerRabinWitnessPasses(a, d uint64, s int, n uint64) bool {
+	x := powMod(a%n, d, n)
+	if x == 1 || x == n-1 {
+		return true
+	}
+	for r := 1; r < s; r++ {
+		x = mulMod(x, x, n)
+		if x == n-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// powMod calcula base^exp mod m por exponenciación binaria.
+func powMod(base, exp, m uint64) uint64 {
+	result := uint64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result = mulMod(result, base, m)
+		}
+		base = mulMod(base, base, m)
+		exp >>= 1
+	}
+	return result
+}
+
+// mulMod calcula a·b mod m con el producto completo de 128 bits, sin desbordar.
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, rem := bits.Div64(hi%m, lo, m)
+	return rem
+}
@@ -0,0 +1,76 @@
+package speculative
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMillerRabinMatchesSieve(t *testing.T) {
+	const limit = 100000
+	primes, err := EncontrarPrimosSieve(nil, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isPrime := make(map[int64]bool, len(primes))
+	for _, p := range primes {
+		isPrime[int64(p)] = true
+	}
+	for n := int64(-5); n < limit; n++ {
+		if got := EsPrimoMillerRabin(n, 1, nil); got != isPrime[n] {
+			t.Errorf("EsPrimoMillerRabin(%d) = %v, want %v", n, got, isPrime[n])
+		}
+	}
+}
+
+func TestMillerRabinKnownValues(t *testing.T) {
+	tests := []struct {
+		n     int64
+		prime bool
+	}{
+		// Números de Carmichael: pasan la prueba de Fermat para toda base coprima.
+		{561, false},
+		{1105, false},
+		{1729, false},
+		{2465, false},
+		{8911, false},
+		// Pseudoprimos fuertes en base 2.
+		{2047, false},
+		{3277, false},
+		{1000000007, true},
+		// El menor pseudoprimo fuerte en las bases 2, 3, 5 y 7, justo en la cota determinista.
+		{millerRabinDeterministicLimit, false},
+		{4294967291, true},
+		{4294967291 * 3, false},
+		{1000000007 * 998244353, false},
+		{9223372036854775783, true},  // el mayor primo de int64
+		{3825123056546413051, false}, // pseudoprimo fuerte en las bases hasta 23
+	}
+	for _, tt := range tests {
+		if got := EsPrimoMillerRabin(tt.n, 20, rand.New(rand.NewSource(1))); got != tt.prime {
+			t.Errorf("EsPrimoMillerRabin(%d) = %v, want %v", tt.n, got, tt.prime)
+		}
+	}
+}
+
+// TestMillerRabinDrawsWitnessesFromRNG comprueba que, por encima de la cota determinista, los
+// testigos salen de rng: la prueba consume un valor de rng por ronda.
+func TestMillerRabinDrawsWitnessesFromRNG(t *testing.T) {
+	const rounds = 5
+	rng := rand.New(rand.NewSource(7))
+	if !EsPrimoMillerRabin(4294967291, rounds, rng) {
+		t.Fatal("4294967291 is prime")
+	}
+	want := rand.New(rand.NewSource(7))
+	for i := 0; i < rounds; i++ {
+		want.Int63n(4294967291 - 3)
+	}
+	if got, next := rng.Int63(), want.Int63(); got != next {
+		t.Errorf("rng is at %d after the test, want %d (one draw per round)", got, next)
+	}
+	// Debajo de la cota los testigos son fijos y rng no se usa.
+	untouched := rand.New(rand.NewSource(7))
+	EsPrimoMillerRabin(1000000007, rounds, untouched)
+	if got, fresh := untouched.Int63(), rand.New(rand.NewSource(7)).Int63(); got != fresh {
+		t.Error("deterministic range drew from rng")
+	}
+}
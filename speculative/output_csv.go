@@ -0,0 +1,280 @@
+package speculative
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutputLimit indica que el CSV alcanzó -max-output-bytes y las filas restantes se omitieron.
+var ErrOutputLimit = errors.New("output size limit reached")
+
+// StdoutPath es el nombre de archivo con que -nombre_archivo pide escribir las métricas en la
+// salida estándar, según la convención de Unix.
+const StdoutPath = "-"
+
+// CSVDelimiters son los separadores admitidos por -delimiter; "tab" evita tener que escribir un
+// tabulador literal en la línea de comandos.
+var CSVDelimiters = map[string]rune{
+	",":   ',',
+	";":   ';',
+	"|":   '|',
+	"tab": '\t',
+	"\t":  '\t',
+}
+
+// csvFormulaPrefixes son los caracteres con que una planilla interpreta una celda como fórmula.
+const csvFormulaPrefixes = "=+-@"
+
+// csvSafeCell neutraliza, con -csv-safe, una celda de texto que empieza con un carácter de
+// csvFormulaPrefixes anteponiéndole una comilla simple, para evitar la inyección de fórmulas al
+// abrir el CSV en una planilla. Se aplica solo a las columnas de texto: las numéricas no cambian.
+func csvSafeCell(cfg Config, value string) string {
+	if cfg.CSVSafe && value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvOutput escribe las filas del CSV respetando -max-output-bytes y -append. Cada archivo comienza
+// con el registro de reproducibilidad y el encabezado; al alcanzar el límite se agrega una nota de
+// truncamiento o, con -rotate, se continúa en un archivo numerado (metricas.1.csv, ...). Con
+// -append cada fila lleva delante la columna config y, si el archivo ya existe, se agrega al final
+// solo el registro de reproducibilidad del nuevo bloque, sin repetir el encabezado.
+type csvOutput struct {
+	path     string
+	limit    int64
+	rotate   bool
+	append   bool
+	comma    rune
+	header   []string
+	comment  []byte
+	preamble []byte
+	// prefix son las celdas que se anteponen a cada fila no vacía (la columna config de -append).
+	prefix []string
+
+	file    io.WriteCloser
+	part    int
+	written int64
+	// truncated indica que ya se escribió la nota de truncamiento.
+	truncated bool
+
+	buffer  bytes.Buffer
+	encoder *csv.Writer
+}
+
+func newCSVOutput(cfg Config, header []string) (*csvOutput, error) {
+	out := &csvOutput{path: cfg.OutputFile, limit: cfg.MaxOutputBytes, rotate: cfg.Rotate, append: cfg.Append, comma: CSVDelimiters[cfg.Delimiter]}
+	out.encoder = csv.NewWriter(&out.buffer)
+	out.encoder.Comma = out.comma
+	if cfg.Append {
+		header = appendHeader(header)
+		out.prefix = []string{appendConfigLabel(cfg)}
+	}
+	out.header = header
+
+	var preamble bytes.Buffer
+	if err := writeCSVHeaderComment(&preamble, cfg); err != nil {
+		return nil, err
+	}
+	out.comment = append([]byte(nil), preamble.Bytes()...)
+	if err := out.encode(header); err != nil {
+		return nil, err
+	}
+	preamble.Write(out.buffer.Bytes())
+	out.preamble = preamble.Bytes()
+	if minimum := int64(len(out.preamble) + len(out.truncationNote())); out.limit > 0 && out.limit < minimum {
+		return nil, fmt.Errorf("max-output-bytes=%d no alcanza para el encabezado y la nota de truncamiento (%d bytes)", out.limit, minimum)
+	}
+
+	if err := out.open(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Write agrega record al archivo actual (ver WriteRows).
+func (out *csvOutput) Write(record []string) error {
+	return out.WriteRows([][]string{record})
+}
+
+// WriteRows agrega records al archivo actual como un bloque, para que las filas de una misma
+// corrida no queden truncadas a medias ni repartidas entre dos archivos. Sin -rotate el bloque solo
+// se escribe si después todavía cabe la nota de truncamiento, que cuenta para el límite; si no, el
+// archivo termina con la nota y se devuelve ErrOutputLimit (también en las llamadas siguientes).
+// Con -rotate un archivo que solo contiene el encabezado admite siempre un bloque, para que la
+// rotación avance.
+func (out *csvOutput) WriteRows(records [][]string) error {
+	if out.truncated {
+		return ErrOutputLimit
+	}
+	var block []byte
+	for _, record := range records {
+		if len(out.prefix) > 0 && len(record) > 0 {
+			record = append(append([]string(nil), out.prefix...), record...)
+		}
+		if err := out.encode(record); err != nil {
+			return err
+		}
+		block = append(block, out.buffer.Bytes()...)
+	}
+
+	size := out.written + int64(len(block))
+	switch {
+	case out.limit <= 0:
+	case !out.rotate && size+int64(len(out.truncationNote())) > out.limit:
+		out.truncated = true
+		n, err := io.WriteString(out.file, out.truncationNote())
+		out.written += int64(n)
+		if err != nil {
+			return err
+		}
+		return ErrOutputLimit
+	case out.rotate && size > out.limit && out.written > int64(len(out.preamble)):
+		if err := out.file.Close(); err != nil {
+			return err
+		}
+		out.part++
+		if err := out.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := out.file.Write(block)
+	out.written += int64(n)
+	return err
+}
+
+// truncationNote es la línea con que termina un archivo que alcanzó -max-output-bytes sin -rotate.
+func (out *csvOutput) truncationNote() string {
+	return fmt.Sprintf("# truncado: se alcanzó max-output-bytes=%d; se omitieron las filas restantes\n", out.limit)
+}
+
+// Flush lleva al archivo las filas que el compresor de un archivo .gz tiene pendientes.
+func (out *csvOutput) Flush() error {
+	if out.file == nil {
+		return nil
+	}
+	return flushOutput(out.file)
+}
+
+// Close cierra el archivo actual; puede llamarse más de una vez. La salida estándar no se cierra.
+func (out *csvOutput) Close() error {
+	if out.file == nil {
+		return nil
+	}
+	if out.file == os.Stdout {
+		out.file = nil
+		return nil
+	}
+	err := out.file.Close()
+	out.file = nil
+	return err
+}
+
+func (out *csvOutput) open() error {
+	if out.path == StdoutPath {
+		out.file = os.Stdout
+		n, err := out.file.Write(out.preamble)
+		out.written = int64(n)
+		return err
+	}
+	if out.append {
+		if info, err := os.Stat(out.path); err == nil && info.Size() > 0 {
+			return out.openExisting(info.Size())
+		}
+	}
+	file, err := createOutputFile(rotatedPath(out.path, out.part))
+	if err != nil {
+		return err
+	}
+	out.file = file
+	n, err := file.Write(out.preamble)
+	out.written = int64(n)
+	return err
+}
+
+// openExisting abre el archivo de -append para agregar un bloque, tras comprobar que su
+// encabezado coincide con el de esta invocación.
+func (out *csvOutput) openExisting(size int64) error {
+	if err := checkExistingHeader(out.path, out.header, out.comma); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(out.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	out.file = file
+	n, err := file.Write(out.comment)
+	out.written = size + int64(n)
+	return err
+}
+
+func checkExistingHeader(path string, header []string, comma rune) error {
+	existing, err := readCSVHeader(path, comma)
+	if err != nil {
+		return err
+	}
+	if strings.Join(existing, ",") != strings.Join(header, ",") {
+		return fmt.Errorf("%s tiene otras columnas; no se le pueden agregar corridas con -append", path)
+	}
+	return nil
+}
+
+// appendHeader antepone la columna config de -append.
+func appendHeader(header []string) []string {
+	return append([]string{"config"}, header...)
+}
+
+// readCSVHeader devuelve la primera fila de path que no es un comentario "#", con comma como
+// separador.
+func readCSVHeader(path string, comma rune) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = comma
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	return reader.Read()
+}
+
+// appendConfigLabel resume los parámetros principales de la invocación para la columna config de
+// -append, de modo que las filas de distintos bloques se puedan distinguir.
+func appendConfigLabel(cfg Config) string {
+	return fmt.Sprintf("n=%d;umbral=%d;runs=%d;seed=%d", cfg.MatrixSize, cfg.Threshold, cfg.Runs, cfg.Seed)
+}
+
+// encode deja en buffer la representación CSV de record.
+func (out *csvOutput) encode(record []string) error {
+	out.buffer.Reset()
+	if err := out.encoder.Write(record); err != nil {
+		return err
+	}
+	out.encoder.Flush()
+	return out.encoder.Error()
+}
+
+// rotatedPath devuelve el nombre del archivo número part: el original para 0 y, en otro caso, el
+// número se inserta antes de la extensión (metricas.csv -> metricas.2.csv, metricas.csv.gz ->
+// metricas.2.csv.gz).
+func rotatedPath(path string, part int) string {
+	if part == 0 {
+		return path
+	}
+	base, compressed := strings.CutSuffix(path, gzipSuffix)
+	ext := filepath.Ext(base)
+	rotated := fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, ext), part, ext)
+	if compressed {
+		rotated += gzipSuffix
+	}
+	return rotated
+}
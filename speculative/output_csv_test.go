@@ -0,0 +1,326 @@
+package speculative
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fullMetricsSize escribe cfg sin límite en un archivo aparte y devuelve su tamaño.
+func fullMetricsSize(t *testing.T, cfg Config) int64 {
+	t.Helper()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "completo.csv")
+	cfg.MaxOutputBytes, cfg.Rotate = 0, false
+	writeMetrics(t, cfg, nil)
+	info, err := os.Stat(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestOutputLimitStopsBatch(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 10
+	// El registro de reproducibilidad ocupa buena parte del archivo: con la mitad del total entran
+	// algunas corridas especulativas, pero no todas.
+	cfg.MaxOutputBytes = fullMetricsSize(t, cfg) / 2
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+
+	rows, err := NewMetricsWriter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Engine{OnRun: rows.WriteRun}.Run(cfg)
+	if !errors.Is(err, ErrOutputLimit) {
+		t.Fatalf("Run: err = %v, want ErrOutputLimit", err)
+	}
+	if len(report.Speculative) >= cfg.Runs || len(report.Sequential) > 0 {
+		t.Errorf("batch did not stop: %d speculative and %d sequential runs", len(report.Speculative), len(report.Sequential))
+	}
+	if err := rows.Finish(report.Summary); !errors.Is(err, ErrOutputLimit) {
+		t.Errorf("Finish: err = %v, want ErrOutputLimit", err)
+	}
+
+	content, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(content)) > cfg.MaxOutputBytes {
+		t.Errorf("file has %d bytes, limit %d", len(content), cfg.MaxOutputBytes)
+	}
+	note := fmt.Sprintf("# truncado: se alcanzó max-output-bytes=%d; se omitieron las filas restantes\n", cfg.MaxOutputBytes)
+	if !strings.HasSuffix(string(content), note) {
+		t.Errorf("file does not end with the truncation note:\n%s", content)
+	}
+	// Las corridas se escriben enteras: las dos filas de cada una o ninguna.
+	perRun := make(map[string]int)
+	for _, row := range readMetricsRows(t, cfg.OutputFile) {
+		perRun[row["run"]]++
+	}
+	if len(perRun) == 0 {
+		t.Error("no runs written")
+	}
+	for run, count := range perRun {
+		if count != 2 {
+			t.Errorf("run %s has %d rows, want 2", run, count)
+		}
+	}
+}
+
+func TestOutputLimitRotates(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 10
+	// Con la mitad del total entran en cada archivo varias corridas, y también la fila resumen.
+	cfg.MaxOutputBytes = fullMetricsSize(t, cfg) / 2
+	cfg.Rotate = true
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	writeMetrics(t, cfg, nil)
+
+	total := 0
+	for part := 0; ; part++ {
+		path := rotatedPath(cfg.OutputFile, part)
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			if part < 2 {
+				t.Errorf("only %d files written", part)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > cfg.MaxOutputBytes {
+			t.Errorf("%s has %d bytes, limit %d", path, info.Size(), cfg.MaxOutputBytes)
+		}
+		total += len(readMetricsRows(t, path))
+	}
+	if want := 3 * cfg.Runs; total != want {
+		t.Errorf("%d rows across the rotated files, want %d", total, want)
+	}
+}
+
+func TestOutputLimitTooSmallForHeader(t *testing.T) {
+	cfg := testConfig()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	cfg.MaxOutputBytes = 10
+	if _, err := NewMetricsWriter(cfg); err == nil || !strings.Contains(err.Error(), "no alcanza") {
+		t.Fatalf("err = %v, want the header does not fit", err)
+	}
+}
+
+func TestAppendKeepsASingleHeader(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 2
+	cfg.Append = true
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	writeMetrics(t, cfg, nil)
+	cfg.Seed++
+	writeMetrics(t, cfg, nil)
+
+	content, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := strings.Join(appendHeader(csvMetricsHeader()), ",") + "\n"
+	if count := strings.Count(string(content), header); count != 1 {
+		t.Errorf("header appears %d times, want 1", count)
+	}
+	if count := strings.Count(string(content), "\n# {"); count != 1 || !strings.HasPrefix(string(content), "# {") {
+		t.Errorf("want one reproducibility record per invocation, found %d after the first line", count)
+	}
+
+	rows := readMetricsRows(t, cfg.OutputFile)
+	if want := 2 * 3 * cfg.Runs; len(rows) != want {
+		t.Errorf("%d rows, want %d (two invocations of %d runs with three rows each)", len(rows), want, cfg.Runs)
+	}
+	configs := make(map[string]int)
+	for _, row := range rows {
+		configs[row["config"]]++
+	}
+	if len(configs) != 2 {
+		t.Errorf("config labels %v, want one per invocation", configs)
+	}
+}
+
+// TestRunsAreWrittenAsTheyFinish detiene el lote después de la corrida stopAfter y comprueba que
+// las filas de esas corridas ya están en el archivo: cada corrida se escribe al terminar, no al
+// final del lote.
+func TestRunsAreWrittenAsTheyFinish(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 4
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	rows, err := NewMetricsWriter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	const stopAfter = 3
+	errStop := errors.New("stop")
+	written := 0
+	onRun := func(run ExecutionRun) error {
+		if err := rows.WriteRun(run); err != nil {
+			return err
+		}
+		written++
+		if got := metricsRuns(t, cfg.OutputFile); got != written {
+			t.Errorf("after %d of %d runs the file holds %d runs", written, 2*cfg.Runs, got)
+		}
+		if written == stopAfter {
+			return errStop
+		}
+		return nil
+	}
+	if _, err := (Engine{OnRun: onRun}).Run(cfg); !errors.Is(err, errStop) {
+		t.Fatalf("Run: err = %v, want the OnRun error", err)
+	}
+	if got := metricsRuns(t, cfg.OutputFile); got != stopAfter {
+		t.Errorf("stopped batch left %d runs on disk, want %d", got, stopAfter)
+	}
+}
+
+// metricsRuns cuenta las corridas distintas (modo y número) escritas en path.
+func metricsRuns(t *testing.T, path string) int {
+	t.Helper()
+	runs := make(map[[2]string]bool)
+	for _, row := range readMetricsRows(t, path) {
+		runs[[2]string{row["mode"], row["run"]}] = true
+	}
+	return len(runs)
+}
+
+// TestInterleaveOrdersRows comprueba el orden de las corridas en el CSV, con y sin -interleave;
+// cada corrida ocupa una o más filas consecutivas.
+func TestInterleaveOrdersRows(t *testing.T) {
+	tests := []struct {
+		interleave bool
+		want       string
+	}{
+		{false, "especulativo 1, especulativo 2, especulativo 3, secuencial 1, secuencial 2, secuencial 3"},
+		{true, "especulativo 1, secuencial 1, especulativo 2, secuencial 2, especulativo 3, secuencial 3"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		cfg.Interleave = tt.interleave
+		cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+		writeMetrics(t, cfg, nil)
+
+		var order []string
+		for _, row := range readMetricsRows(t, cfg.OutputFile) {
+			run := fmt.Sprintf("%s %s", row["mode"], row["run"])
+			if len(order) == 0 || order[len(order)-1] != run {
+				order = append(order, run)
+			}
+		}
+		if got := strings.Join(order, ", "); got != tt.want {
+			t.Errorf("interleave=%v: runs in file order %s, want %s", tt.interleave, got, tt.want)
+		}
+	}
+}
+
+func TestDelimiterReadsBack(t *testing.T) {
+	for name, comma := range CSVDelimiters {
+		cfg := testConfig()
+		cfg.Delimiter = name
+		cfg.Threshold = math.MaxInt64 // gana siempre B, cuyo detalle count=...,last=... lleva una coma
+		cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+		writeMetrics(t, cfg, nil)
+
+		content, err := ReadMetricsFile(cfg.OutputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := csv.NewReader(bytes.NewReader(content))
+		reader.Comma = comma
+		reader.Comment = '#'
+		records, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("delimiter %q: %v", name, err)
+		}
+		header := records[0]
+		detail := columnIndex(header, "result_detail")
+		if len(header) < 10 || detail < 0 {
+			t.Fatalf("delimiter %q: header %v", name, header)
+		}
+		checked := 0
+		for _, record := range records[1:] {
+			if record[columnIndex(header, "branch")] != branchB || !strings.HasPrefix(record[detail], "count=") {
+				continue
+			}
+			checked++
+			// La coma del detalle solo obliga a citar el campo cuando también es el delimitador.
+			field := string(comma) + record[detail] + string(comma)
+			if quoted := !bytes.Contains(content, []byte(field)); quoted != (comma == ',') {
+				t.Errorf("delimiter %q: detail %q quoted = %v", name, record[detail], quoted)
+			}
+		}
+		if checked == 0 {
+			t.Errorf("delimiter %q: no branch B rows with a count detail", name)
+		}
+	}
+}
+
+func TestSummaryOnlyWritesHeaderAndSummary(t *testing.T) {
+	cfg := testConfig()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	cfg.SummaryOnly = true
+	writeMetrics(t, cfg, nil)
+
+	content, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	// Registro de reproducibilidad, encabezado, separación y resumen.
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "# {") || lines[2] != "" {
+		t.Fatalf("got %d lines, want the reproducibility record, the header, a blank line and the summary:\n%s", len(lines), content)
+	}
+	if lines[1] != strings.Join(csvMetricsHeader(), ",") {
+		t.Errorf("header %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[3], "resumen,") {
+		t.Errorf("last line %q is not the summary", lines[3])
+	}
+}
+
+// TestCSVSafeEscapesFormulas ejecuta con un -pow-data que empieza con cada carácter de fórmula y
+// con ramas cuyo result_detail también empieza así: con -csv-safe ninguna celda de las corridas
+// queda interpretable como fórmula, y sin él el detalle se escribe tal cual.
+func TestCSVSafeEscapesFormulas(t *testing.T) {
+	for _, prefix := range strings.Split(csvFormulaPrefixes, "") {
+		cfg := testConfig()
+		cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+		cfg.PowData = prefix + `HYPERLINK("http://example.com")`
+		writeMetrics(t, cfg, nil)
+		for _, row := range readMetricsRows(t, cfg.OutputFile) {
+			for column, value := range row {
+				if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+					t.Errorf("pow-data %q: %s run %s %s = %q starts a formula", cfg.PowData, row["mode"], row["run"], column, value)
+				}
+			}
+		}
+
+		detail := prefix + "cmd|' /C calc'!A0"
+		branches := []NamedBranch{{Name: branchA, Work: fixedWork(1, detail)}, {Name: branchB, Work: fixedWork(2, detail)}}
+		for _, safe := range []bool{true, false} {
+			cfg.CSVSafe = safe
+			writeMetrics(t, cfg, branches)
+			want := detail
+			if safe {
+				want = "'" + detail
+			}
+			for _, row := range readMetricsRows(t, cfg.OutputFile) {
+				if row["result_detail"] != want {
+					t.Errorf("csv-safe=%v: result_detail %q, want %q", safe, row["result_detail"], want)
+				}
+			}
+		}
+	}
+}
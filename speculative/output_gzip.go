@@ -0,0 +1,64 @@
+package speculative
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipSuffix es la extensión con que -nombre_archivo pide comprimir el archivo de métricas.
+const gzipSuffix = ".gz"
+
+// gzipFile es un archivo de salida comprimido con gzip. Close cierra primero el compresor, que
+// escribe el final del flujo, y después el archivo; en el orden inverso el archivo queda truncado.
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (f *gzipFile) Close() error {
+	if err := f.Writer.Close(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
+// createOutputFile crea el archivo de métricas path, comprimido con gzip si termina en .gz.
+func createOutputFile(path string) (io.WriteCloser, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, gzipSuffix) {
+		return file, nil
+	}
+	return &gzipFile{Writer: gzip.NewWriter(file), file: file}, nil
+}
+
+// flushOutput lleva al archivo lo que el compresor de w tiene pendiente, para que las corridas
+// escritas hasta el momento puedan leerse aunque el proceso termine sin cerrarlo. Sin compresión
+// no hace nada.
+func flushOutput(w io.Writer) error {
+	if gz, ok := w.(*gzipFile); ok {
+		return gz.Flush()
+	}
+	return nil
+}
+
+// ReadMetricsFile devuelve el contenido del archivo de métricas path, descomprimido si termina en
+// .gz.
+func ReadMetricsFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil || !strings.HasSuffix(path, gzipSuffix) {
+		return content, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,56 @@
+package speculative
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestGzipOutputReadsBack lee el CSV comprimido con gzip.NewReader, sin ReadMetricsFile, para
+// comprobar que es un flujo gzip válido y completo.
+func TestGzipOutputReadsBack(t *testing.T) {
+	cfg := testConfig()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv.gz")
+	report := writeMetrics(t, cfg, nil)
+
+	file, err := os.Open(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("reading the gzip stream: %v", err)
+	}
+
+	if !bytes.HasPrefix(content, []byte("# {")) {
+		t.Errorf("content does not start with the reproducibility header:\n%s", content)
+	}
+	records := csv.NewReader(bytes.NewReader(content))
+	records.Comment = '#'
+	records.FieldsPerRecord = -1
+	rows, err := records.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(rows[0], csvMetricsHeader()) {
+		t.Errorf("header %v, want %v", rows[0], csvMetricsHeader())
+	}
+	branchRows := 0
+	for _, run := range append(report.Speculative, report.Sequential...) {
+		branchRows += len(run.Branches)
+	}
+	// Encabezado, una fila por rama y la fila de resumen (la línea en blanco no es un registro).
+	if len(rows) != 1+branchRows+1 || rows[len(rows)-1][0] != "resumen" {
+		t.Errorf("%d records, want the header, %d branch rows and the summary", len(rows), branchRows)
+	}
+}
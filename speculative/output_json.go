@@ -0,0 +1,250 @@
+package speculative
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// jsonBranch es la representación JSON de una rama; replica las columnas del CSV.
+type jsonBranch struct {
+	Branch           string  `json:"branch"`
+	WasWinner        bool    `json:"was_winner"`
+	Cancelled        bool    `json:"cancelled"`
+	ResultNumeric    int64   `json:"result_numeric"`
+	ResultDetail     string  `json:"result_detail"`
+	BranchStartMs    float64 `json:"branch_start_ms"`
+	BranchEndMs      float64 `json:"branch_end_ms"`
+	BranchDurationMs float64 `json:"branch_duration_ms"`
+	BranchAllocBytes uint64  `json:"branch_alloc_bytes"`
+	FinishOrder      int     `json:"finish_order"`
+	AllocPerPrime    float64 `json:"alloc_per_prime,omitempty"`
+	HashesAttempted  int64   `json:"hashes_attempted,omitempty"`
+	HashRate         float64 `json:"hash_rate,omitempty"`
+	Retries          int     `json:"retries"`
+	CPUTimeMs        float64 `json:"cpu_time_ms"`
+	CPUTimeSource    string  `json:"cpu_time_source"`
+	ShadowNumeric    *int64  `json:"shadow_numeric,omitempty"`
+	ShadowDetail     string  `json:"shadow_detail,omitempty"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// jsonRun es la representación JSON de una corrida con sus ramas anidadas.
+type jsonRun struct {
+	Mode                 string       `json:"mode"`
+	Run                  int          `json:"run"`
+	Winner               string       `json:"winner"`
+	ConditionValue       int64        `json:"condition_value"`
+	ConditionDurationMs  float64      `json:"condition_duration_ms"`
+	ConditionFraction    float64      `json:"condition_fraction"`
+	TotalDurationMs      float64      `json:"total_duration_ms"`
+	EffectiveParallelism float64      `json:"effective_parallelism"`
+	Branches             []jsonBranch `json:"branches"`
+}
+
+// jsonSummary es la representación JSON de la fila de resumen; speedup es null cuando no está
+// definido (ver ComputeSpeedup).
+type jsonSummary struct {
+	AvgSpeculativeMs          float64  `json:"avg_speculative_ms"`
+	AvgSequentialMs           *float64 `json:"avg_sequential_ms,omitempty"`
+	ReferenceMs               *float64 `json:"reference_ms,omitempty"`
+	Speedup                   *float64 `json:"speedup"`
+	AvgParallelismSpeculative float64  `json:"avg_parallelism_speculative"`
+	// Los promedios de result_numeric van por rama, con el nombre de la rama como clave.
+	AvgNumericSpeculative map[string]float64 `json:"avg_numeric_speculative"`
+	AvgNumericSequential  map[string]float64 `json:"avg_numeric_sequential,omitempty"`
+	// Las claves de los percentiles son "p50", "p90", "p95" y "p99".
+	PercentilesSpeculativeMs map[string]float64 `json:"percentiles_speculative_ms"`
+	PercentilesSequentialMs  map[string]float64 `json:"percentiles_sequential_ms,omitempty"`
+	DispersionSpeculative    jsonDispersion     `json:"dispersion_speculative_ms"`
+	DispersionSequential     *jsonDispersion    `json:"dispersion_sequential_ms,omitempty"`
+	DurationCorrelation      *float64           `json:"duration_correlation,omitempty"`
+	SpecWinRate              *float64           `json:"spec_win_rate,omitempty"`
+	WastedWorkMs             float64            `json:"wasted_work_ms"`
+	SpeculationBenefitMs     float64            `json:"speculation_benefit_ms"`
+	WinsSpeculative          map[string]int     `json:"wins_speculative"`
+	WinsSequential           map[string]int     `json:"wins_sequential,omitempty"`
+}
+
+// jsonDispersion es la dispersión de las duraciones totales de una estrategia, en milisegundos.
+type jsonDispersion struct {
+	Stddev float64 `json:"stddev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// jsonReport agrupa las corridas por modo, que es la forma por defecto de la salida JSON.
+type jsonReport struct {
+	Config      runHeader   `json:"config"`
+	Speculative []jsonRun   `json:"speculative"`
+	Sequential  []jsonRun   `json:"sequential"`
+	Summary     jsonSummary `json:"summary"`
+}
+
+// jsonFlatReport es la salida JSON de -json-flat: el registro de reproducibilidad y el resumen
+// como en jsonReport, pero con todas las corridas en un único arreglo (cada una con su mode).
+type jsonFlatReport struct {
+	Config  runHeader   `json:"config"`
+	Runs    []jsonRun   `json:"runs"`
+	Summary jsonSummary `json:"summary"`
+}
+
+// jsonSummaryReport es la salida JSON de -summary-only: el registro de reproducibilidad y el
+// resumen, sin las corridas.
+type jsonSummaryReport struct {
+	Config  runHeader   `json:"config"`
+	Summary jsonSummary `json:"summary"`
+}
+
+// writeJSONMetrics escribe el registro de reproducibilidad y las corridas agrupadas por modo junto
+// al resumen o, con -json-flat, las mismas partes con todas las corridas en un único arreglo, las
+// especulativas primero.
+func writeJSONMetrics(cfg Config, specRuns, seqRuns []ExecutionRun, summary Summary) error {
+	var payload any
+	switch {
+	case cfg.JSONFlat:
+		payload = jsonFlatReport{
+			Config:  newRunHeader(cfg),
+			Runs:    append(toJSONRuns(cfg, specRuns), toJSONRuns(cfg, seqRuns)...),
+			Summary: toJSONSummary(summary),
+		}
+	case cfg.SummaryOnly:
+		payload = jsonSummaryReport{Config: newRunHeader(cfg), Summary: toJSONSummary(summary)}
+	default:
+		payload = jsonReport{
+			Config:      newRunHeader(cfg),
+			Speculative: toJSONRuns(cfg, specRuns),
+			Sequential:  toJSONRuns(cfg, seqRuns),
+			Summary:     toJSONSummary(summary),
+		}
+	}
+
+	if cfg.OutputFile == StdoutPath {
+		return encodeJSONMetrics(os.Stdout, payload)
+	}
+	if err := os.MkdirAll(Directory(cfg.OutputFile), 0o755); err != nil {
+		return err
+	}
+	file, err := createOutputFile(cfg.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := encodeJSONMetrics(file, payload); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func encodeJSONMetrics(w io.Writer, payload any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(payload)
+}
+
+func toJSONRuns(cfg Config, runs []ExecutionRun) []jsonRun {
+	out := make([]jsonRun, 0, len(runs))
+	for _, run := range runs {
+		if !sampledRun(cfg, run) {
+			continue
+		}
+		out = append(out, toJSONRun(run))
+	}
+	return out
+}
+
+func toJSONRun(run ExecutionRun) jsonRun {
+	branches := make([]jsonBranch, 0, len(run.Branches))
+	for _, branch := range run.Branches {
+		encoded := jsonBranch{
+			Branch:           branch.Name,
+			WasWinner:        branch.Name == run.Winner,
+			Cancelled:        branch.Cancelled,
+			ResultNumeric:    branch.Numeric,
+			ResultDetail:     branch.Detail,
+			BranchStartMs:    Milliseconds(branch.Start.Sub(run.RunStart)),
+			BranchEndMs:      Milliseconds(branch.End.Sub(run.RunStart)),
+			BranchDurationMs: Milliseconds(branch.Duration),
+			BranchAllocBytes: branch.AllocBytes,
+			FinishOrder:      branch.FinishOrder,
+			AllocPerPrime:    branch.AllocPerPrime,
+			HashesAttempted:  branch.Hashes,
+			HashRate:         hashRate(branch),
+			Retries:          branch.Retries,
+			CPUTimeMs:        Milliseconds(branch.CPUTime),
+			CPUTimeSource:    branch.CPUTimeSource,
+			Error:            branchErrorString(branch),
+		}
+		if branch.Shadow != nil {
+			encoded.ShadowNumeric = &branch.Shadow.Numeric
+			encoded.ShadowDetail = branch.Shadow.Detail
+		}
+		branches = append(branches, encoded)
+	}
+	return jsonRun{
+		Mode:                 run.Mode,
+		Run:                  run.RunIndex,
+		Winner:               run.Winner,
+		ConditionValue:       run.ConditionValue,
+		ConditionDurationMs:  Milliseconds(run.ConditionDuration),
+		ConditionFraction:    run.ConditionFraction,
+		TotalDurationMs:      Milliseconds(run.TotalDuration),
+		EffectiveParallelism: effectiveParallelism(run),
+		Branches:             branches,
+	}
+}
+
+func toJSONSummary(summary Summary) jsonSummary {
+	baseline := Milliseconds(summary.Baseline)
+	var speedup *float64
+	if !math.IsNaN(summary.Speedup) {
+		speedup = &summary.Speedup
+	}
+	out := jsonSummary{
+		AvgSpeculativeMs:          Milliseconds(summary.AvgSpeculative),
+		Speedup:                   speedup,
+		AvgNumericSpeculative:     summary.AvgNumericSpeculative,
+		AvgNumericSequential:      summary.AvgNumericSequential,
+		AvgParallelismSpeculative: summary.AvgParallelism,
+		WastedWorkMs:              Milliseconds(summary.WastedWork),
+		SpeculationBenefitMs:      Milliseconds(summary.SpeculationBenefit),
+		WinsSpeculative:           summary.WinsSpeculative,
+	}
+	out.PercentilesSpeculativeMs = toJSONPercentiles(summary.PercentilesSpeculative)
+	out.DispersionSpeculative = toJSONDispersion(summary.DispersionSpeculative)
+	if summary.BaselineIsReference {
+		out.ReferenceMs = &baseline
+	} else {
+		out.AvgSequentialMs = &baseline
+		out.PercentilesSequentialMs = toJSONPercentiles(summary.PercentilesSequential)
+		sequential := toJSONDispersion(summary.DispersionSequential)
+		out.DispersionSequential = &sequential
+		out.WinsSequential = summary.WinsSequential
+	}
+	if summary.HasCorrelation {
+		out.DurationCorrelation = &summary.DurationCorrelation
+	}
+	if summary.WinRatePairs > 0 {
+		out.SpecWinRate = &summary.SpecWinRate
+	}
+	return out
+}
+
+func toJSONDispersion(dispersion DurationDispersion) jsonDispersion {
+	return jsonDispersion{
+		Stddev: Milliseconds(dispersion.Stddev),
+		Min:    Milliseconds(dispersion.Min),
+		Max:    Milliseconds(dispersion.Max),
+	}
+}
+
+func toJSONPercentiles(values []time.Duration) map[string]float64 {
+	out := make(map[string]float64, len(reportedPercentiles))
+	for i, p := range reportedPercentiles {
+		out[fmt.Sprintf("p%g", p)] = Milliseconds(values[i])
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package speculative
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// Valores del campo type de cada línea NDJSON.
+const (
+	ndjsonConfig  = "config"
+	ndjsonRun     = "run"
+	ndjsonSummary = "summary"
+)
+
+// ndjsonRunLine es una línea NDJSON con una corrida: los campos de jsonRun junto a su type.
+type ndjsonRunLine struct {
+	Type string `json:"type"`
+	jsonRun
+}
+
+// ndjsonSummaryLine es la última línea NDJSON, con el resumen.
+type ndjsonSummaryLine struct {
+	Type string `json:"type"`
+	jsonSummary
+}
+
+// ndjsonHeaderLine es la primera línea NDJSON, con el registro de reproducibilidad.
+type ndjsonHeaderLine struct {
+	Type string `json:"type"`
+	runHeader
+}
+
+// ndjsonMetricsWriter escribe las métricas con -format ndjson: un objeto JSON compacto por línea,
+// cada corrida en cuanto termina, de modo que un proceso que lee el archivo (o la salida estándar)
+// puede consumirlas a medida que llegan. La primera línea lleva la configuración y la última el
+// resumen; el campo type distingue las tres.
+type ndjsonMetricsWriter struct {
+	cfg     Config
+	file    io.WriteCloser
+	encoder *json.Encoder
+}
+
+func newNDJSONMetricsWriter(cfg Config) (*ndjsonMetricsWriter, error) {
+	w := &ndjsonMetricsWriter{cfg: cfg}
+	var out io.Writer = os.Stdout
+	if cfg.OutputFile != StdoutPath {
+		if err := os.MkdirAll(Directory(cfg.OutputFile), 0o755); err != nil {
+			return nil, err
+		}
+		file, err := createOutputFile(cfg.OutputFile)
+		if err != nil {
+			return nil, err
+		}
+		w.file, out = file, file
+	}
+	// Encode escribe cada objeto con una única llamada a Write, sin buffer intermedio.
+	w.encoder = json.NewEncoder(out)
+	if err := w.encoder.Encode(ndjsonHeaderLine{Type: ndjsonConfig, runHeader: newRunHeader(cfg)}); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// WriteRun escribe la línea de run, salvo que -sample-rows la excluya.
+func (w *ndjsonMetricsWriter) WriteRun(run ExecutionRun) error {
+	if !sampledRun(w.cfg, run) {
+		return nil
+	}
+	if err := w.encoder.Encode(ndjsonRunLine{Type: ndjsonRun, jsonRun: toJSONRun(run)}); err != nil {
+		return err
+	}
+	return flushOutput(w.file)
+}
+
+// Finish escribe la línea de resumen y cierra el archivo.
+func (w *ndjsonMetricsWriter) Finish(summary Summary) error {
+	if err := w.encoder.Encode(ndjsonSummaryLine{Type: ndjsonSummary, jsonSummary: toJSONSummary(summary)}); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (w *ndjsonMetricsWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,56 @@
+package speculative
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestNDJSONLinesAreIndependent decodifica cada línea por separado: la primera es la
+// configuración, las del medio una corrida cada una y la última el resumen.
+func TestNDJSONLinesAreIndependent(t *testing.T) {
+	cfg := testConfig()
+	cfg.Format = formatNDJSON
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.ndjson")
+	writeMetrics(t, cfg, nil)
+
+	file, err := os.Open(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var types []string
+	runs := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v\n%s", len(types)+1, err, scanner.Text())
+		}
+		types = append(types, line.Type)
+		if line.Type != ndjsonRun {
+			continue
+		}
+		var run ndjsonRunLine
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			t.Fatal(err)
+		}
+		runs++
+		if run.Mode == "" || run.Run < 1 || run.Winner == "" || len(run.Branches) == 0 {
+			t.Errorf("line %d: incomplete run %+v", len(types), run.jsonRun)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(types) < 2 || types[0] != ndjsonConfig || types[len(types)-1] != ndjsonSummary {
+		t.Fatalf("line types %v, want config first and summary last", types)
+	}
+	if runs != 2*cfg.Runs || runs != len(types)-2 {
+		t.Errorf("%d run lines out of %d, want %d between config and summary", runs, len(types), 2*cfg.Runs)
+	}
+}
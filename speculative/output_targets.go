@@ -0,0 +1,180 @@
+package speculative
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// outputFormats asocia la extensión de un archivo de métricas con el formato que se infiere de
+// ella cuando -nombre_archivo es una lista.
+var outputFormats = map[string]string{
+	".csv":    formatCSV,
+	".json":   formatJSON,
+	".ndjson": formatNDJSON,
+}
+
+// parseOutputFiles interpreta -nombre_archivo: uno o más archivos separados por comas, sin
+// repetir; "-" es la salida estándar.
+func parseOutputFiles(spec string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, raw := range strings.Split(spec, ",") {
+		path := strings.TrimSpace(raw)
+		if path == "" {
+			return nil, errors.New("la lista tiene un nombre vacío")
+		}
+		if seen[path] {
+			return nil, fmt.Errorf("el archivo %s aparece más de una vez", path)
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// outputTargets devuelve un destino por archivo de -nombre_archivo, cada uno con la configuración
+// con que se escribe: cfg con su OutputFile y su Format. En una lista el formato se infiere de la
+// extensión (.csv, .json o .ndjson, también seguidas de .gz) y solo los nombres sin una extensión
+// conocida usan -format; un único nombre conserva -format, como antes de admitir listas.
+func outputTargets(cfg Config) []Config {
+	paths, err := parseOutputFiles(cfg.OutputFile)
+	if err != nil {
+		// ValidateConfig ya rechazó la lista; se conserva el comportamiento de un único archivo.
+		return []Config{cfg}
+	}
+	targets := make([]Config, len(paths))
+	for i, path := range paths {
+		targets[i] = cfg
+		targets[i].OutputFile = path
+		if len(paths) == 1 {
+			continue
+		}
+		ext := filepath.Ext(strings.TrimSuffix(path, gzipSuffix))
+		if format, ok := outputFormats[strings.ToLower(ext)]; ok {
+			targets[i].Format = format
+		}
+	}
+	return targets
+}
+
+// writesToStdout informa si alguno de los destinos de -nombre_archivo es la salida estándar.
+func writesToStdout(cfg Config) bool {
+	for _, target := range outputTargets(cfg) {
+		if target.OutputFile == StdoutPath {
+			return true
+		}
+	}
+	return false
+}
+
+// FirstOutputFile devuelve el primer destino de -nombre_archivo que es un archivo, junto al cual
+// se escribe el manifiesto; ok es falso si todas las métricas van a la salida estándar.
+func FirstOutputFile(cfg Config) (path string, ok bool) {
+	for _, target := range outputTargets(cfg) {
+		if target.OutputFile != StdoutPath {
+			return target.OutputFile, true
+		}
+	}
+	return "", false
+}
+
+// NewMetricsWriter crea el escritor de métricas de cada destino de -nombre_archivo; con varios,
+// las corridas y el resumen (calculado una sola vez) se entregan a todos.
+func NewMetricsWriter(cfg Config) (MetricsWriter, error) {
+	var writers multiMetricsWriter
+	for _, target := range outputTargets(cfg) {
+		var w MetricsWriter
+		var err error
+		switch target.Format {
+		case formatCSV:
+			w, err = newCSVMetricsWriter(target)
+		case formatNDJSON:
+			w, err = newNDJSONMetricsWriter(target)
+		default:
+			w = &jsonMetricsWriter{cfg: target}
+		}
+		if err != nil {
+			writers.Close()
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+	if len(writers) == 1 {
+		return writers[0], nil
+	}
+	return writers, nil
+}
+
+// multiMetricsWriter reparte las métricas entre los escritores de varios destinos.
+type multiMetricsWriter []MetricsWriter
+
+func (ws multiMetricsWriter) WriteRun(run ExecutionRun) error {
+	for _, w := range ws {
+		if err := w.WriteRun(run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finish termina todos los destinos aunque alguno falle. Devuelve el primer error distinto de
+// ErrOutputLimit o, si no lo hay, ErrOutputLimit cuando algún CSV se truncó.
+func (ws multiMetricsWriter) Finish(summary Summary) error {
+	var limited, failed error
+	for _, w := range ws {
+		err := w.Finish(summary)
+		switch {
+		case errors.Is(err, ErrOutputLimit):
+			limited = err
+		case err != nil && failed == nil:
+			failed = err
+		}
+	}
+	if failed != nil {
+		return failed
+	}
+	return limited
+}
+
+func (ws multiMetricsWriter) Close() error {
+	var first error
+	for _, w := range ws {
+		if err := w.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// jsonMetricsWriter reúne las corridas y escribe el JSON completo en Finish, ya que, a diferencia
+// del CSV y del NDJSON, el documento no puede escribirse por partes.
+type jsonMetricsWriter struct {
+	cfg         Config
+	speculative []ExecutionRun
+	sequential  []ExecutionRun
+}
+
+func (w *jsonMetricsWriter) WriteRun(run ExecutionRun) error {
+	if run.Mode == ModeSequential {
+		w.sequential = append(w.sequential, run)
+	} else {
+		w.speculative = append(w.speculative, run)
+	}
+	return nil
+}
+
+// Finish escribe el archivo con las corridas en orden de número de corrida, que con
+// -parallel-runs puede diferir del orden en que terminaron.
+func (w *jsonMetricsWriter) Finish(summary Summary) error {
+	for _, runs := range [][]ExecutionRun{w.speculative, w.sequential} {
+		sort.SliceStable(runs, func(i, j int) bool { return runs[i].RunIndex < runs[j].RunIndex })
+	}
+	return writeJSONMetrics(w.cfg, w.speculative, w.sequential, summary)
+}
+
+func (w *jsonMetricsWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package speculative
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultipleOutputTargets(t *testing.T) {
+	dir := t.TempDir()
+	csvPath, jsonPath := filepath.Join(dir, "out.csv"), filepath.Join(dir, "out.json")
+	cfg := testConfig()
+	cfg.OutputFile = csvPath + "," + jsonPath
+	report := writeMetrics(t, cfg, nil)
+	want := FormatSpeedup(report.Summary.Speedup)
+
+	content, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded jsonReport
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatalf("out.json: %v", err)
+	}
+	if decoded.Summary.Speedup == nil || FormatSpeedup(*decoded.Summary.Speedup) != want {
+		t.Errorf("out.json speedup %v, want %s", decoded.Summary.Speedup, want)
+	}
+	if len(decoded.Speculative) != cfg.Runs || len(decoded.Sequential) != cfg.Runs {
+		t.Errorf("out.json has %d and %d runs, want %d each", len(decoded.Speculative), len(decoded.Sequential), cfg.Runs)
+	}
+
+	content, err = os.ReadFile(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("out.csv: %v", err)
+	}
+	summary := records[len(records)-1]
+	cell := summary[columnIndex(records[0], "total_duration_ms")]
+	if summary[0] != "resumen" || !strings.Contains(cell, ";speedup="+want+";") {
+		t.Errorf("out.csv summary %q, want speedup=%s", cell, want)
+	}
+}
@@ -0,0 +1,45 @@
+package speculative
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"sort"
+)
+
+// DefaultPowHash es el algoritmo del anexo.
+const DefaultPowHash = "sha256"
+
+// HashFunc calcula el resumen con que el Proof-of-Work evalúa cada nonce.
+type HashFunc func(data []byte) []byte
+
+// PowHashes relaciona los nombres aceptados por -pow-hash con su función.
+var PowHashes = map[string]HashFunc{
+	"sha1": func(data []byte) []byte {
+		sum := sha1.Sum(data)
+		return sum[:]
+	},
+	"sha256": func(data []byte) []byte {
+		sum := sha256.Sum256(data)
+		return sum[:]
+	},
+	"sha512": func(data []byte) []byte {
+		sum := sha512.Sum512(data)
+		return sum[:]
+	},
+}
+
+// powHashBits devuelve el largo en bits del resumen producido por el algoritmo name.
+func powHashBits(name string) int {
+	return len(PowHashes[name](nil)) * 8
+}
+
+// PowHashNames devuelve los nombres registrados en orden alfabético, para los mensajes de error.
+func PowHashNames() []string {
+	names := make([]string, 0, len(PowHashes))
+	for name := range PowHashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
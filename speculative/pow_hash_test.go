@@ -0,0 +1,79 @@
+package speculative
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProofOfWorkWithEachHash(t *testing.T) {
+	const difficulty = 3
+	hexLength := map[string]int{"sha1": 40, "sha256": 64, "sha512": 128}
+	for _, name := range PowHashNames() {
+		hash, nonce, err := SimularProofOfWorkHashCtx(context.Background(), PowHashes[name], "hashes", difficulty, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !strings.HasPrefix(hash, strings.Repeat("0", difficulty)) {
+			t.Errorf("%s: hash %s lacks %d leading zeros", name, hash, difficulty)
+		}
+		if len(hash) != hexLength[name] || powHashBits(name) != 4*hexLength[name] {
+			t.Errorf("%s: hash has %d hex digits and powHashBits %d, want %d digits", name, len(hash), powHashBits(name), hexLength[name])
+		}
+		if want := hex.EncodeToString(PowHashes[name]([]byte("hashes" + strconv.Itoa(nonce)))); hash != want {
+			t.Errorf("%s: nonce %d hashes to %s, search returned %s", name, nonce, want, hash)
+		}
+	}
+}
+
+func TestPoWCountsAttemptedHashes(t *testing.T) {
+	modes := []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{"hex", func(*Config) {}},
+		{"bits", func(c *Config) { c.PowMode, c.PowDifficulty = PowModeBits, 8 }},
+		{"target", func(c *Config) { c.PowMode, c.PowDifficulty = PowModeTarget, 200 }},
+		{"parallel", func(c *Config) { c.PowWorkers = 4 }},
+	}
+	for _, mode := range modes {
+		cfg := testConfig()
+		mode.modify(&cfg)
+		for i := 0; i < 5; i++ {
+			cfg.PowData = fmt.Sprintf("bloque-%d", i)
+			output, err := powBranchWork(cfg)(context.Background())
+			if err != nil {
+				t.Fatalf("%s %s: %v", mode.name, cfg.PowData, err)
+			}
+			// Los workers concurrentes pueden haber probado nonces mayores que el ganador.
+			if output.Hashes < output.Numeric+1 {
+				t.Errorf("%s %s: %d hashes attempted for nonce %d", mode.name, cfg.PowData, output.Hashes, output.Numeric)
+			}
+			if mode.name != "parallel" && output.Hashes != output.Numeric+1 {
+				t.Errorf("%s %s: %d hashes attempted for nonce %d, want nonce+1", mode.name, cfg.PowData, output.Hashes, output.Numeric)
+			}
+		}
+	}
+}
+
+func TestCancelledPoWReportsAttemptedHashes(t *testing.T) {
+	cfg := testConfig()
+	cfg.PowDifficulty = 12 // inalcanzable en la duración de la prueba
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result := executeBranchSync(ctx, realClock{}, branchA, powBranchWork(cfg))
+	if !errors.Is(result.Err, ErrCancelled) && !result.Cancelled {
+		t.Fatalf("result err = %v cancelled = %v, want a cancelled branch", result.Err, result.Cancelled)
+	}
+	if result.Hashes <= 0 {
+		t.Fatalf("cancelled branch reports %d hashes", result.Hashes)
+	}
+	if rate := hashRate(result); rate <= 0 {
+		t.Errorf("hash rate = %v for %d hashes in %v", rate, result.Hashes, result.Duration)
+	}
+}
@@ -0,0 +1,89 @@
+package speculative
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SimularPoWParalelo reparte la búsqueda de SimularProofOfWork entre workers goroutines: el worker
+// w prueba los nonces w, w+workers, w+2·workers, ... Devuelve siempre el menor nonce válido, el
+// mismo que la versión secuencial, ya que un worker que encuentra un nonce lo publica como cota y
+// los demás solo se detienen al superarla. Respeta también el canal externo cancel.
+func SimularPoWParalelo(cancel <-chan struct{}, blockData string, dificultad, workers int) (string, int, error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return simularPoWParaleloCtx(ctx, nil, blockData, dificultad, workers)
+}
+
+// simularPoWParaleloCtx es la versión basada en contexto, con la función de hash inyectada (nil
+// usa SHA-256).
+func simularPoWParaleloCtx(ctx context.Context, hash HashFunc, blockData string, dificultad, workers int) (string, int, error) {
+	hashString, nonce, _, err := powParallelSearch(ctx, hash, blockData, dificultad, workers)
+	return hashString, nonce, err
+}
+
+// powParallelSearch es simularPoWParaleloCtx con la cantidad total de nonces probados por todos
+// los workers, que supera al nonce ganador porque los demás siguen probando hasta superar la cota.
+func powParallelSearch(ctx context.Context, hash HashFunc, blockData string, dificultad, workers int) (string, int, int, error) {
+	if hash == nil {
+		hash = PowHashes[DefaultPowHash]
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	targetPrefix := strings.Repeat("0", dificultad)
+	done := ctx.Done()
+
+	// best es el menor nonce válido encontrado hasta ahora; actúa como señal de término compartida.
+	var best atomic.Int64
+	best.Store(math.MaxInt64)
+	hashes := make([]string, workers)
+	var attempts atomic.Int64
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			tried := int64(0)
+			defer func() { attempts.Add(tried) }()
+			for nonce := w; int64(nonce) < best.Load(); nonce += workers {
+				if done != nil && (nonce/workers)%1_000 == 0 {
+					select {
+					case <-done:
+						return
+					default:
+					}
+				}
+
+				hashString := hex.EncodeToString(hash([]byte(fmt.Sprintf("%s%d", blockData, nonce))))
+				tried++
+				if !strings.HasPrefix(hashString, targetPrefix) {
+					continue
+				}
+				// Los nonces de cada worker son crecientes, así que el primero que encuentra es su menor.
+				hashes[w] = hashString
+				for {
+					current := best.Load()
+					if int64(nonce) >= current || best.CompareAndSwap(current, int64(nonce)) {
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Con ctx terminado algún worker pudo detenerse antes de la cota, así que el nonce podría no ser
+	// el menor: se informa la cancelación.
+	nonce := best.Load()
+	if ctx.Err() != nil || nonce == math.MaxInt64 {
+		return "", 0, int(attempts.Load()), cancelledError(ctx)
+	}
+	return hashes[int(nonce)%workers], int(nonce), int(attempts.Load()), nil
+}
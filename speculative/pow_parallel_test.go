@@ -0,0 +1,35 @@
+package speculative
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestParallelPoWReturnsSmallestNonce compara la búsqueda repartida con la secuencial, que recorre
+// los nonces en orden y por lo tanto devuelve el menor válido.
+func TestParallelPoWReturnsSmallestNonce(t *testing.T) {
+	for _, difficulty := range []int{1, 2, 3, 4} {
+		for i := 0; i < 3; i++ {
+			data := fmt.Sprintf("parallel-%d", i)
+			wantHash, wantNonce := SimularProofOfWork(data, difficulty)
+			for _, workers := range []int{1, 2, 3, 7} {
+				hash, nonce, err := SimularPoWParalelo(nil, data, difficulty, workers)
+				if err != nil {
+					t.Fatalf("%s difficulty %d, %d workers: %v", data, difficulty, workers, err)
+				}
+				if nonce != wantNonce || hash != wantHash {
+					t.Errorf("%s difficulty %d, %d workers: nonce %d, want the smallest %d", data, difficulty, workers, nonce, wantNonce)
+				}
+			}
+		}
+	}
+}
+
+func TestParallelPoWHonorsCancellation(t *testing.T) {
+	cancel := make(chan struct{})
+	close(cancel)
+	if _, _, err := SimularPoWParalelo(cancel, "parallel", 30, 4); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("err = %v, want ErrCancelled", err)
+	}
+}
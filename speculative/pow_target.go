@@ -0,0 +1,77 @@
+package speculative
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// TargetDeDificultad convierte una dificultad en el objetivo de 256 bits equivalente, como en la
+// minería real: target = (2^256 - 1) / difficulty. Un hash SHA-256 uniforme queda bajo ese objetivo
+// con probabilidad 1/difficulty, así que el nonce esperado crece en proporción directa a la
+// dificultad, con un control mucho más fino que los ceros iniciales. Una dificultad menor que 1
+// se trata como 1.
+func TargetDeDificultad(difficulty int) *big.Int {
+	return targetForBits(difficulty, 256)
+}
+
+// targetForBits es TargetDeDificultad para un resumen de bits bits.
+func targetForBits(difficulty, bits int) *big.Int {
+	if difficulty < 1 {
+		difficulty = 1
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	limit.Sub(limit, big.NewInt(1))
+	return limit.Div(limit, big.NewInt(int64(difficulty)))
+}
+
+// SimularPoWTarget busca el primer nonce cuyo hash SHA-256, leído como entero big-endian, sea
+// menor que target. Devuelve el hash en hexadecimal y el nonce, o ErrCancelled si cancel se cierra
+// antes.
+func SimularPoWTarget(cancel <-chan struct{}, blockData string, target *big.Int) (string, int, error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return SimularPoWTargetHashCtx(ctx, nil, blockData, target, 0)
+}
+
+// SimularPoWTargetHashCtx es SimularPoWTarget con la función de hash de -pow-hash (nil usa
+// SHA-256), un contexto y maxNonce, que se interpreta igual que en SimularProofOfWorkWithCancel.
+func SimularPoWTargetHashCtx(ctx context.Context, hash HashFunc, blockData string, target *big.Int, maxNonce int) (string, int, error) {
+	hashString, nonce, _, err := powTargetSearch(ctx, hash, blockData, target, maxNonce)
+	return hashString, nonce, err
+}
+
+// powTargetSearch es SimularPoWTargetHashCtx con la cantidad de nonces probados.
+func powTargetSearch(ctx context.Context, hash HashFunc, blockData string, target *big.Int, maxNonce int) (string, int, int, error) {
+	if target == nil || target.Sign() <= 0 {
+		return "", 0, 0, errors.New("el objetivo del Proof-of-Work debe ser positivo")
+	}
+	if hash == nil {
+		hash = PowHashes[DefaultPowHash]
+	}
+	nonce := 0
+	done := ctx.Done()
+	value := new(big.Int)
+
+	for {
+		if done != nil && nonce%1_000 == 0 {
+			select {
+			case <-done:
+				return "", 0, nonce, cancelledError(ctx)
+			default:
+			}
+		}
+
+		data := fmt.Sprintf("%s%d", blockData, nonce)
+		hashBytes := hash([]byte(data))
+		if value.SetBytes(hashBytes).Cmp(target) < 0 {
+			return hex.EncodeToString(hashBytes), nonce, nonce + 1, nil
+		}
+		if maxNonce > 0 && nonce >= maxNonce {
+			return "", nonce, nonce + 1, ErrExhausted
+		}
+		nonce++
+	}
+}
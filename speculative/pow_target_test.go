@@ -0,0 +1,48 @@
+package speculative
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// TestLargerTargetFindsSmallerNonce mina los mismos bloques con dos objetivos. El nonce esperado
+// es proporcional a la dificultad, así que el objetivo mayor (dificultad menor) debe dar un nonce
+// medio menor.
+func TestLargerTargetFindsSmallerNonce(t *testing.T) {
+	const blocks = 40
+	average := func(difficulty int) float64 {
+		target := TargetDeDificultad(difficulty)
+		total := 0
+		for i := 0; i < blocks; i++ {
+			data := fmt.Sprintf("bloque-%d", i)
+			hash, nonce, err := SimularPoWTarget(nil, data, target)
+			if err != nil {
+				t.Fatalf("difficulty %d, %s: %v", difficulty, data, err)
+			}
+			value, ok := new(big.Int).SetString(hash, 16)
+			if !ok || value.Cmp(target) >= 0 {
+				t.Fatalf("difficulty %d, %s: hash %s is not below the target", difficulty, data, hash)
+			}
+			total += nonce
+		}
+		return float64(total) / blocks
+	}
+	easy, hard := average(4), average(256)
+	if easy >= hard {
+		t.Errorf("average nonce with difficulty 4 = %.1f, with 256 = %.1f; the larger target should need fewer", easy, hard)
+	}
+}
+
+func TestTargetDeDificultad(t *testing.T) {
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	for _, difficulty := range []int{-1, 0, 1} {
+		if got := TargetDeDificultad(difficulty); got.Cmp(max) != 0 {
+			t.Errorf("TargetDeDificultad(%d) = %x, want 2^256-1", difficulty, got)
+		}
+	}
+	// Duplicar la dificultad reduce el objetivo a la mitad.
+	if got, want := TargetDeDificultad(2), new(big.Int).Rsh(max, 1); got.Cmp(want) != 0 {
+		t.Errorf("TargetDeDificultad(2) = %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package speculative
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// EncontrarPrimosSieveParallel devuelve los mismos primos que EncontrarPrimosSieve, pero reparte
+// el marcado de múltiplos entre workers goroutines que escriben sin bloqueos sobre un bitset
+// compartido de []uint32. Los primos base (hasta √max) se obtienen con la criba serial y se
+// asignan de forma intercalada (el worker w toma los primos base w, w+workers, ...), de modo que
+// los primos pequeños, que concentran la mayor parte del trabajo, quedan repartidos.
+func EncontrarPrimosSieveParallel(cancel <-chan struct{}, max, workers int) ([]int, error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return EncontrarPrimosSieveParallelCtx(ctx, max, workers)
+}
+
+// EncontrarPrimosSieveParallelCtx es la variante basada en context.Context; al terminar ctx
+// devuelve ErrCancelled envolviendo ctx.Err().
+func EncontrarPrimosSieveParallelCtx(ctx context.Context, max, workers int) ([]int, error) {
+	if max < 2 {
+		return []int{}, nil
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	limit := int(math.Sqrt(float64(max)))
+	for limit*limit < max {
+		limit++
+	}
+	base, err := EncontrarPrimosSieveCtx(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	done := ctx.Done()
+	composite := make([]uint32, (max+31)/32)
+	var wg sync.WaitGroup
+	var cancelled atomic.Bool
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			marks := 0
+			for k := w; k < len(base); k += workers {
+				p := base[k]
+				for j := p * p; j < max; j += p {
+					marks++
+					if marks%sieveCancelEvery == 0 && isClosed(done) {
+						cancelled.Store(true)
+						return
+					}
+					setBit(composite, j)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if cancelled.Load() {
+		return nil, cancelledError(ctx)
+	}
+
+	primes := make([]int, 0, primeCountEstimate(max))
+	for i := 2; i < max; i++ {
+		if i%sieveCancelEvery == 0 && isClosed(done) {
+			return nil, cancelledError(ctx)
+		}
+		if composite[i/32]&(1<<(uint(i)%32)) == 0 {
+			primes = append(primes, i)
+		}
+	}
+	return primes, nil
+}
+
+// setBit marca el bit i de bits con un ciclo compare-and-swap, ya que varios workers pueden
+// escribir la misma palabra de 32 bits a la vez.
+func setBit(bits []uint32, i int) {
+	word := &bits[i/32]
+	mask := uint32(1) << (uint(i) % 32)
+	for {
+		old := atomic.LoadUint32(word)
+		if old&mask != 0 || atomic.CompareAndSwapUint32(word, old, old|mask) {
+			return
+		}
+	}
+}
+
+// isClosed informa si cancel ya fue cerrado sin bloquear; un canal nil nunca se cierra.
+func isClosed(cancel <-chan struct{}) bool {
+	if cancel == nil {
+		return false
+	}
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,56 @@
+package speculative
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestSieveParallelMatchesSerial(t *testing.T) {
+	for _, limit := range []int{0, 2, 3, 64, 65, 1000, 7919, 100000, 1 << 20} {
+		want, err := EncontrarPrimosSieve(nil, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{1, 2, 3, 8} {
+			got, err := EncontrarPrimosSieveParallel(nil, limit, workers)
+			if err != nil {
+				t.Fatalf("limit %d, %d workers: %v", limit, workers, err)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("limit %d, %d workers: %d primes, serial sieve %d", limit, workers, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestSieveParallelHonorsCancellation(t *testing.T) {
+	cancel := make(chan struct{})
+	close(cancel)
+	if _, err := EncontrarPrimosSieveParallel(cancel, 1<<20, 4); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("err = %v, want ErrCancelled", err)
+	}
+}
+
+// BenchmarkSieveParallel mide cómo escala la criba concurrente con la cantidad de workers
+// (go test -bench SieveParallel); workers=1 es la referencia.
+func BenchmarkSieveParallel(b *testing.B) {
+	const limit = 5000000
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := EncontrarPrimosSieveParallel(nil, limit, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := EncontrarPrimosSieve(nil, limit); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
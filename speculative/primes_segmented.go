@@ -0,0 +1,75 @@
+package speculative
+
+import "context"
+
+// DefaultPrimesSegment es el tamaño por defecto de cada bloque de la criba segmentada; 256 KiB de
+// marcas caben en la caché L2 de la mayoría de los procesadores.
+const DefaultPrimesSegment = 1 << 18
+
+// EncontrarPrimosSegmented cuenta los primos menores que max con una criba de Eratóstenes
+// segmentada: solo mantiene en memoria los primos base (hasta √max) y un bloque de segmentSize
+// marcas, que se reutiliza para cada tramo [lo, lo+segmentSize). Por eso no devuelve la lista de
+// primos sino su cantidad y el último encontrado (0 si no hay), que es lo que informa la rama B, y
+// su memoria no crece con max. Un segmentSize menor que 1 usa DefaultPrimesSegment.
+func EncontrarPrimosSegmented(cancel <-chan struct{}, max, segmentSize int) (count, last int, err error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return EncontrarPrimosSegmentedCtx(ctx, max, segmentSize)
+}
+
+// EncontrarPrimosSegmentedCtx es la variante basada en context.Context; al terminar ctx devuelve
+// ErrCancelled envolviendo ctx.Err().
+func EncontrarPrimosSegmentedCtx(ctx context.Context, max, segmentSize int) (count, last int, err error) {
+	if max < 2 {
+		return 0, 0, nil
+	}
+	if segmentSize < 1 {
+		segmentSize = DefaultPrimesSegment
+	}
+
+	limit := 1
+	for limit*limit < max {
+		limit++
+	}
+	base, err := EncontrarPrimosSieveCtx(ctx, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	done := ctx.Done()
+	composite := make([]bool, segmentSize)
+	marks := 0
+	for lo := 2; lo < max; lo += segmentSize {
+		if isClosed(done) {
+			return 0, 0, cancelledError(ctx)
+		}
+		hi := min(lo+segmentSize, max)
+		clear(composite)
+
+		for _, p := range base {
+			if p*p >= hi {
+				break
+			}
+			// Primer múltiplo de p dentro del tramo, sin marcar p ni los múltiplos menores que p².
+			start := (lo + p - 1) / p * p
+			if start < p*p {
+				start = p * p
+			}
+			for j := start; j < hi; j += p {
+				marks++
+				if marks%sieveCancelEvery == 0 && isClosed(done) {
+					return 0, 0, cancelledError(ctx)
+				}
+				composite[j-lo] = true
+			}
+		}
+
+		for i := lo; i < hi; i++ {
+			if !composite[i-lo] {
+				count++
+				last = i
+			}
+		}
+	}
+	return count, last, nil
+}
@@ -0,0 +1,37 @@
+package speculative
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSegmentedMatchesSieve(t *testing.T) {
+	for _, limit := range []int{0, 1, 2, 3, 10, 97, 1000, 7919, 100000, 1 << 20} {
+		want, err := EncontrarPrimosSieve(nil, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantLast := 0
+		if len(want) > 0 {
+			wantLast = want[len(want)-1]
+		}
+		// Segmentos menores, iguales y mayores que √limit, y uno que no divide al rango.
+		for _, segment := range []int{1, 7, 1000, DefaultPrimesSegment} {
+			count, last, err := EncontrarPrimosSegmented(nil, limit, segment)
+			if err != nil {
+				t.Fatalf("limit %d, segment %d: %v", limit, segment, err)
+			}
+			if count != len(want) || last != wantLast {
+				t.Errorf("limit %d, segment %d: count %d last %d, sieve %d last %d", limit, segment, count, last, len(want), wantLast)
+			}
+		}
+	}
+}
+
+func TestSegmentedHonorsCancellation(t *testing.T) {
+	cancel := make(chan struct{})
+	close(cancel)
+	if _, _, err := EncontrarPrimosSegmented(cancel, 1<<22, 1024); !errors.Is(err, ErrCancelled) {
+		t.Fatalf("err = %v, want ErrCancelled", err)
+	}
+}
@@ -0,0 +1,28 @@
+package speculative
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ProgressFunc recibe el avance del lote cada vez que termina una corrida medida: el modo, cuántas
+// corridas de ese modo van (done de total) y una estimación del tiempo restante para todo el lote,
+// calculada con la duración media de las corridas completadas hasta el momento.
+type ProgressFunc func(mode string, done, total int, eta time.Duration)
+
+// PrintProgress es el ProgressFunc de -progress: una línea por corrida en stderr.
+func PrintProgress(mode string, done, total int, eta time.Duration) {
+	fmt.Fprintf(os.Stderr, "run %d/%d (%s) ETA %s\n", done, total, mode, eta.Round(100*time.Millisecond))
+}
+
+// reportProgress informa a cfg.Progress que terminó la corrida done del modo mode. completed es la
+// cantidad de corridas medidas desde start, leído con runClock(cfg), y remaining, las que faltan en
+// todo el lote; el tiempo restante se estima con la duración media de las completadas.
+func reportProgress(cfg Config, mode string, done, completed, remaining int, start time.Time) {
+	if cfg.Progress == nil {
+		return
+	}
+	perRun := runClock(cfg).Now().Sub(start) / time.Duration(completed)
+	cfg.Progress(mode, done, cfg.Runs, perRun*time.Duration(remaining))
+}
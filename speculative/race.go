@@ -0,0 +1,111 @@
+package speculative
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// conditionOutcome es el resultado de la condición que runRace evalúa en segundo plano.
+type conditionOutcome struct {
+	metrics  ConditionMetrics
+	duration time.Duration
+	err      error
+}
+
+// runRace es la estrategia especulativa de -race: lanza todas las ramas y la primera que termina
+// con un resultado completo gana y cancela a las demás. La condición se evalúa a la vez, en otra
+// goroutine, solo para registrarla en condition_value; no elige la ganadora. TotalDuration va desde
+// el lanzamiento hasta el resultado de la ganadora (o, con -cancel-mode drain, hasta el de la última
+// perdedora) y no incluye la espera de la condición si esta termina después. Si ninguna rama termina
+// completa (todas agotadas o con timeout) gana la primera en llegar; si ctx termina antes de que
+// haya ganadora la corrida se descarta con ErrInterrupted.
+func runRace(ctx context.Context, cfg Config, runIndex int, branches []NamedBranch) (ExecutionRun, error) {
+	if len(branches) == 0 {
+		return ExecutionRun{}, errors.New("debe definirse al menos una rama")
+	}
+
+	clock := runClock(cfg)
+	runStart := clock.Now()
+	resultsCh := make(chan BranchResult, len(branches))
+
+	cancels := make(map[string]context.CancelFunc, len(branches))
+	for _, branch := range branches {
+		branchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cancels[branch.Name] = cancel
+		go executeBranchAsync(branchCtx, clock, branch.Name, branch.Work, resultsCh)
+	}
+
+	conditionCh := make(chan conditionOutcome, 1)
+	go func() {
+		start := clock.Now()
+		metrics, err := evaluateCondition(cfg, runIndex)
+		conditionCh <- conditionOutcome{metrics: metrics, duration: clock.Now().Sub(start), err: err}
+	}()
+
+	// abort cancela todas las ramas y espera a que terminen, junto con la condición, antes de
+	// devolver err, para que ninguna goroutine sobreviva a la corrida.
+	received := 0
+	abort := func(err error) (ExecutionRun, error) {
+		for _, cancel := range cancels {
+			cancel()
+		}
+		for ; received < len(branches); received++ {
+			<-resultsCh
+		}
+		<-conditionCh
+		return ExecutionRun{}, err
+	}
+
+	results := make([]BranchResult, 0, len(branches))
+	winner := ""
+	for len(results) < len(branches) {
+		result := <-resultsCh
+		received++
+		result.FinishOrder = received
+		if result.Err != nil && !errors.Is(result.Err, ErrExhausted) {
+			return abort(&BranchError{Name: result.Name, RunIndex: runIndex, Err: result.Err})
+		}
+		results = append(results, result)
+		if winner != "" || result.Cancelled || result.Err != nil {
+			continue
+		}
+		winner = result.Name
+		for name, cancel := range cancels {
+			if name != winner {
+				cancel()
+			}
+		}
+		if cfg.CancelMode == CancelAbandon {
+			break
+		}
+	}
+	totalDuration := clock.Now().Sub(runStart)
+	if pending := len(branches) - received; pending > 0 {
+		go drainResults(resultsCh, pending)
+	}
+
+	condition := <-conditionCh
+	if condition.err != nil {
+		return ExecutionRun{}, condition.err
+	}
+	if winner == "" {
+		if ctx.Err() != nil {
+			return ExecutionRun{}, ErrInterrupted
+		}
+		winner = results[0].Name
+	}
+
+	return ExecutionRun{
+		Mode:              ModeSpeculative,
+		RunIndex:          runIndex,
+		ConditionValue:    condition.metrics.Trace,
+		ConditionDuration: condition.duration,
+		ConditionFraction: float64(condition.metrics.Rows) / float64(cfg.MatrixSize),
+		Winner:            winner,
+		TotalDuration:     totalDuration,
+		RunStart:          runStart,
+		Branches:          results,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package speculative
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// delayedWork devuelve una rama que termina con numeric tras delay, o antes si se la cancela.
+func delayedWork(delay time.Duration, numeric int64) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		select {
+		case <-time.After(delay):
+			return BranchOutput{Numeric: numeric}, nil
+		case <-ctx.Done():
+			return BranchOutput{}, cancelledError(ctx)
+		}
+	}
+}
+
+func TestRaceWinnerIgnoresThreshold(t *testing.T) {
+	cfg := testConfig()
+	cfg.Race = true
+	cfg.Threshold = 0 // la condición elegiría siempre A
+	branches := []NamedBranch{
+		{Name: branchA, Work: delayedWork(time.Second, 1)},
+		{Name: branchB, Work: delayedWork(5*time.Millisecond, 2)},
+	}
+	for runIndex := 1; runIndex <= 3; runIndex++ {
+		start := time.Now()
+		run, err := runRace(context.Background(), cfg, runIndex, branches)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run.Winner != branchB {
+			t.Errorf("run %d: winner %q, want B, which finishes first", runIndex, run.Winner)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("run %d took %v; A should have been cancelled", runIndex, elapsed)
+		}
+		for _, branch := range run.Branches {
+			if branch.Name == branchA && !branch.Cancelled {
+				t.Errorf("run %d: A was not cancelled", runIndex)
+			}
+		}
+		if thresholdSelector(cfg.Threshold, cfg.Tie)(ConditionMetrics{Trace: run.ConditionValue}) != branchA {
+			t.Errorf("run %d: the condition should have picked A", runIndex)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package speculative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// selfCheckRun es lo que una pasada de -selfcheck registra de una corrida: el valor de la
+// condición, la rama ganadora y el resultado completo de cada rama.
+type selfCheckRun struct {
+	Trace   int64
+	Winner  string
+	Outputs []BranchOutput
+}
+
+// RunSelfCheck comprueba que la configuración sea reproducible: calcula dos veces, para cada
+// corrida de 1 a cfg.Runs, la condición, la ganadora y el resultado de todas las ramas ejecutadas
+// hasta el final (sin cancelación ni medición de tiempos) y devuelve una línea por cada valor que
+// difiera entre ambas pasadas. Una diferencia delata un generador aleatorio sin semilla u otra
+// fuente de no determinismo en una rama.
+func RunSelfCheck(ctx context.Context, cfg Config, branches []NamedBranch) ([]string, error) {
+	var passes [2][]selfCheckRun
+	for pass := range passes {
+		for runIndex := 1; runIndex <= cfg.Runs; runIndex++ {
+			record, err := selfCheckPass(ctx, cfg, runIndex, branches)
+			if err != nil {
+				return nil, fmt.Errorf("pasada %d, corrida %d: %w", pass+1, runIndex, err)
+			}
+			passes[pass] = append(passes[pass], record)
+		}
+	}
+
+	var diffs []string
+	for i := range passes[0] {
+		first, second := passes[0][i], passes[1][i]
+		runIndex := i + 1
+		if first.Trace != second.Trace {
+			diffs = append(diffs, fmt.Sprintf("run %d: condition_value %d != %d", runIndex, first.Trace, second.Trace))
+		}
+		if first.Winner != second.Winner {
+			diffs = append(diffs, fmt.Sprintf("run %d: winner %s != %s", runIndex, first.Winner, second.Winner))
+		}
+		for j, branch := range branches {
+			a, b := first.Outputs[j], second.Outputs[j]
+			if a.Numeric != b.Numeric {
+				diffs = append(diffs, fmt.Sprintf("run %d: branch %s result_numeric %d != %d", runIndex, branch.Name, a.Numeric, b.Numeric))
+			}
+			if a.Detail != b.Detail {
+				diffs = append(diffs, fmt.Sprintf("run %d: branch %s result_detail %q != %q", runIndex, branch.Name, a.Detail, b.Detail))
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// selfCheckPass evalúa la condición de la corrida runIndex y ejecuta cada rama hasta el final. Un
+// ErrExhausted forma parte del resultado; cualquier otro error de rama lo interrumpe.
+func selfCheckPass(ctx context.Context, cfg Config, runIndex int, branches []NamedBranch) (selfCheckRun, error) {
+	metrics, err := evaluateCondition(cfg, runIndex)
+	if err != nil {
+		return selfCheckRun{}, err
+	}
+	record := selfCheckRun{Trace: metrics.Trace, Winner: selectWinner(cfg, metrics)}
+	for _, branch := range branches {
+		output, err := branch.Work(ctx)
+		if errors.Is(err, ErrBranchTimeout) {
+			return selfCheckRun{}, fmt.Errorf("branch %s hit -branch-timeout", branch.Name)
+		}
+		if errors.Is(err, ErrCancelled) {
+			return selfCheckRun{}, ErrInterrupted
+		}
+		if err != nil && !errors.Is(err, ErrExhausted) {
+			return selfCheckRun{}, &BranchError{Name: branch.Name, RunIndex: runIndex, Err: err}
+		}
+		record.Outputs = append(record.Outputs, output)
+	}
+	return record, nil
+}
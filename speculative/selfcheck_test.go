@@ -0,0 +1,51 @@
+package speculative
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunSelfCheckFixedSeedPasses(t *testing.T) {
+	cfg := testConfig()
+	branches, err := BuildBranchWorkload(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := RunSelfCheck(context.Background(), cfg, branches)
+	if err != nil {
+		t.Fatalf("RunSelfCheck: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("a fixed seed reported differences: %v", diffs)
+	}
+}
+
+func TestRunSelfCheckReportsNondeterministicBranch(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 2
+	branches, err := BuildBranchWorkload(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// La rama devuelve un valor distinto en cada llamada, como una que usara una fuente aleatoria
+	// sin la semilla de la corrida.
+	var calls atomic.Int64
+	branches = append(branches, NamedBranch{Name: "NOISY", Work: func(context.Context) (BranchOutput, error) {
+		return BranchOutput{Numeric: calls.Add(1)}, nil
+	}})
+
+	diffs, err := RunSelfCheck(context.Background(), cfg, branches)
+	if err != nil {
+		t.Fatalf("RunSelfCheck: %v", err)
+	}
+	if len(diffs) != cfg.Runs {
+		t.Fatalf("%d differences, want one per run: %v", len(diffs), diffs)
+	}
+	// La primera pasada devuelve 1 y 2; la segunda, 3 y 4.
+	for i, want := range []string{"run 1: branch NOISY result_numeric 1 != 3", "run 2: branch NOISY result_numeric 2 != 4"} {
+		if diffs[i] != want {
+			t.Errorf("difference %d = %q, want %q", i, diffs[i], want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package speculative
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// stopSignalNames relaciona los nombres aceptados por -stop-signals con la señal correspondiente.
+var stopSignalNames = map[string]os.Signal{
+	"SIGINT":  os.Interrupt,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+}
+
+// ParseStopSignals convierte una lista separada por comas (ej. "SIGINT,SIGTERM") en señales.
+// El prefijo SIG es opcional y una lista vacía desactiva el manejo de señales.
+func ParseStopSignals(spec string) ([]os.Signal, error) {
+	var signals []os.Signal
+	for _, raw := range strings.Split(spec, ",") {
+		name := strings.ToUpper(strings.TrimSpace(raw))
+		if name == "" {
+			continue
+		}
+		if !strings.HasPrefix(name, "SIG") {
+			name = "SIG" + name
+		}
+		sig, ok := stopSignalNames[name]
+		if !ok {
+			return nil, fmt.Errorf("señal desconocida %q", strings.TrimSpace(raw))
+		}
+		signals = append(signals, sig)
+	}
+	return signals, nil
+}
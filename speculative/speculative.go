@@ -0,0 +1,2933 @@
+// Package speculative implementa la ejecución especulativa de la Tarea 2: las ramas de trabajo
+// (Proof-of-Work, primos y las registradas con RegisterBranch), la condición que elige la
+// ganadora, las estrategias especulativa y secuencial con su resumen (Engine) y los escritores de
+// métricas. El comando tarea02 es una interfaz de línea de comandos sobre este paquete.
+package speculative
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	branchA = "A"
+	branchB = "B"
+
+	ModeSpeculative = "especulativo"
+	ModeSequential  = "secuencial"
+
+	// Valores de -cancel-mode: si la corrida especulativa espera a que las ramas canceladas
+	// devuelvan su resultado (drain) o termina al llegar el de la ganadora (abandon).
+	CancelDrain   = "drain"
+	CancelAbandon = "abandon"
+
+	formatCSV    = "csv"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+
+	PowModeHex  = "hex"
+	PowModeBits = "bits"
+	// PowModeTarget compara el hash, como entero, con el objetivo (2^bits - 1) / difficulty.
+	PowModeTarget = "target"
+
+	PrimesTrial = "trial"
+	PrimesSieve = "sieve"
+	// PrimesSieveParallel es la criba con marcado concurrente sobre un bitset atómico.
+	PrimesSieveParallel = "sieve-parallel"
+	// PrimesSegmented es la criba segmentada, que solo cuenta los primos y acota la memoria.
+	PrimesSegmented = "segmented"
+
+	// sieveCancelEvery es la cantidad de iteraciones de la criba entre dos revisiones de cancelación.
+	sieveCancelEvery = 4096
+
+	// maxPrimesBits acota -primes-bits para que los candidatos quepan en un int de 32 bits.
+	maxPrimesBits = 31
+
+	// Valores de cpu_time_source: el tiempo de CPU del hilo fijado de la rama (Linux), ese mismo
+	// tiempo cuando la rama reparte trabajo en otras goroutines cuyo tiempo no incluye, el del
+	// subproceso con -branch-isolation process, la duración de reloj como aproximación donde no hay
+	// medición por hilo o unavailable cuando el subproceso no llegó a ejecutarse (cpu_time_ms 0).
+	cpuTimeThread      = "thread"
+	cpuTimeThreadOnly  = "thread-only"
+	cpuTimeProcess     = "process"
+	cpuTimeWall        = "wall"
+	cpuTimeUnavailable = "unavailable"
+)
+
+// Version identifica la versión de la herramienta registrada en los archivos de salida; puede
+// fijarse al compilar con -ldflags "-X tarea02/speculative.Version=...".
+var Version = "dev"
+
+var (
+	// ErrCancelled indica que la operación fue cancelada por el controlador principal.
+	ErrCancelled = errors.New("branch cancelled")
+	// ErrInterrupted indica que la corrida se abandonó porque se recibió una señal de detención.
+	ErrInterrupted = errors.New("run interrupted by signal")
+	// ErrExhausted indica que el Proof-of-Work superó el nonce máximo sin encontrar un hash válido.
+	// A diferencia de otros errores de rama, no aborta el lote: queda registrado en la columna error.
+	ErrExhausted = errors.New("proof-of-work nonce limit exhausted")
+	// ErrBranchTimeout acompaña a ErrCancelled cuando una rama se detuvo por -branch-timeout; la
+	// rama queda cancelada, pero la corrida se registra en lugar de descartarse.
+	ErrBranchTimeout = errors.New("branch timed out")
+)
+
+// BranchError es el error con que una corrida se aborta porque la rama Name falló en la corrida
+// RunIndex; Err es el error de la rama. Permite a quien invoca las corridas identificar con
+// errors.As qué rama y qué corrida fallaron.
+type BranchError struct {
+	Name     string
+	RunIndex int
+	Err      error
+}
+
+func (e *BranchError) Error() string {
+	return fmt.Sprintf("branch %s failed: %v", e.Name, e.Err)
+}
+
+func (e *BranchError) Unwrap() error {
+	return e.Err
+}
+
+// Config reúne los parámetros controlables desde la línea de comandos.
+// Las etiquetas JSON coinciden con el nombre de cada flag.
+type Config struct {
+	MatrixSize      int     `json:"n"`
+	Threshold       int64   `json:"umbral"`
+	OutputFile      string  `json:"nombre_archivo"`
+	Runs            int     `json:"runs"`
+	Warmup          int     `json:"warmup"`
+	PowDifficulty   int     `json:"difficulty"`
+	PowData         string  `json:"pow-data"`
+	PowMode         string  `json:"pow-mode"`
+	MaxNonce        int     `json:"max-nonce"`
+	PowHash         string  `json:"pow-hash"`
+	PowWorkers      int     `json:"pow-workers"`
+	PrimesLimit     int     `json:"primes-limit"`
+	StopSignals     string  `json:"stop-signals"`
+	SampleRows      int     `json:"sample-rows"`
+	WorkloadSpec    string  `json:"workload-spec"`
+	ReferenceMs     float64 `json:"reference-ms"`
+	Format          string  `json:"format"`
+	JSONFlat        bool    `json:"json-flat"`
+	PrimesBits      int     `json:"primes-bits"`
+	DecisionLog     string  `json:"decision-log"`
+	PrimesAlgo      string  `json:"primes-algo"`
+	PrimesWorkers   int     `json:"primes-workers"`
+	PrimesSegment   int     `json:"primes-segment"`
+	DetectThrottle  bool    `json:"detect-throttle"`
+	BranchIsolation string  `json:"branch-isolation"`
+	AllocPerPrime   bool    `json:"alloc-per-prime"`
+	PrimesStats     bool    `json:"primes-stats"`
+	ShadowLosers    bool    `json:"shadow-losers"`
+	MaxOutputBytes  int64   `json:"max-output-bytes"`
+	Append          bool    `json:"append"`
+	WarningsJSON    bool    `json:"warnings-json"`
+	SpeedupTrend    int     `json:"speedup-trend"`
+	TrendFile       string  `json:"speedup-trend-file"`
+	Rotate          bool    `json:"rotate"`
+	Validate        bool    `json:"validate"`
+	ShowVersion     bool    `json:"version"`
+	ListBranches    bool    `json:"list-branches"`
+	ConfigFile      string  `json:"config"`
+	Sweep           bool    `json:"sweep"`
+	Quiet           bool    `json:"quiet"`
+	Interleave      bool    `json:"interleave"`
+	MatrixFile      string  `json:"matrix-file"`
+	MatrixMax       int     `json:"matrix-max"`
+	Retries         int     `json:"retries"`
+	Manifest        bool    `json:"manifest"`
+	CSVSafe         bool    `json:"csv-safe"`
+	CPUProfile      string  `json:"cpuprofile"`
+	MemProfile      string  `json:"memprofile"`
+	Delimiter       string  `json:"delimiter"`
+	Tie             string  `json:"tie"`
+	DumpMatrix      string  `json:"dump-matrix"`
+	Force           bool    `json:"force"`
+	PrimesLow       int     `json:"primes-low"`
+	Verbose         bool    `json:"verbose"`
+	SweepSizes      string  `json:"sizes"`
+	SelfCheck       bool    `json:"selfcheck"`
+	SweepFile       string  `json:"sweep-file"`
+	CancelMode      string  `json:"cancel-mode"`
+	EarlyCancel     bool    `json:"early-cancel"`
+	SummaryOnly     bool    `json:"summary-only"`
+	ConditionReps   int     `json:"condition-reps"`
+	Race            bool    `json:"race"`
+	Symmetric       bool    `json:"symmetric"`
+	MinSpeedup      float64 `json:"min-speedup"`
+	// DifficultySweep es la lista de -difficulty-sweep; vacía desactiva el barrido (ver
+	// RunDifficultySweep).
+	DifficultySweep string `json:"difficulty-sweep"`
+	DifficultyFile  string `json:"difficulty-sweep-file"`
+	// Cooldown es la pausa entre corridas medidas consecutivas (ver cooldown).
+	Cooldown time.Duration `json:"cooldown"`
+	// BranchTimeout es el plazo máximo de cada rama (ver timeoutWork); 0 no lo limita.
+	BranchTimeout time.Duration `json:"branch-timeout"`
+	// Branches es la lista de -branches: las ramas registradas (ver RegisterBranch) que participan.
+	Branches string `json:"branches"`
+	// ParallelRuns es la cantidad de corridas medidas que se ejecutan a la vez (ver
+	// collectRunsParallel).
+	ParallelRuns int `json:"parallel-runs"`
+	// Seed es la semilla efectiva: la de -seed o, si es 0, una derivada de la hora de inicio.
+	Seed int64 `json:"seed"`
+	// Policy es la regla de -policy; con PolicyThreshold la condición solo calcula la traza.
+	Policy string `json:"policy"`
+	// Selector decide la rama ganadora a partir de la condición; por defecto compara con Threshold.
+	Selector WinnerSelector `json:"-"`
+	// Clock mide los tiempos de cada corrida; nil usa el reloj del sistema (ver runClock).
+	Clock Clock `json:"-"`
+	// Progress, si no es nil, se invoca al terminar cada corrida medida; -progress lo asocia a
+	// PrintProgress.
+	Progress ProgressFunc `json:"-"`
+
+	// WorkerArgs son los argumentos originales, que se reenvían a los subprocesos de rama.
+	WorkerArgs []string `json:"-"`
+	// FileMatrices son las dos matrices de MatrixFile, leídas una sola vez por LoadMatrixFile;
+	// nil mientras no se cargaron.
+	FileMatrices [2][][]int `json:"-"`
+}
+
+// DefaultConfig devuelve la configuración con los valores por defecto de las flags de la línea de
+// comandos, que parseFlags toma de aquí. Es el punto de partida para usar Engine como biblioteca:
+// basta con cambiar los campos que interesan para que ValidateConfig la acepte.
+func DefaultConfig() Config {
+	return Config{
+		MatrixSize:      125,
+		MatrixMax:       DefaultMatrixMax,
+		Threshold:       500000,
+		OutputFile:      "metricas.csv",
+		Runs:            30,
+		PowDifficulty:   5,
+		PowData:         "speculative",
+		PowMode:         PowModeHex,
+		PowHash:         DefaultPowHash,
+		PowWorkers:      1,
+		PrimesLimit:     500000,
+		PrimesLow:       2,
+		PrimesAlgo:      PrimesTrial,
+		PrimesSegment:   DefaultPrimesSegment,
+		PrimesWorkers:   runtime.NumCPU(),
+		StopSignals:     "SIGINT,SIGTERM",
+		SampleRows:      1,
+		Format:          formatCSV,
+		Delimiter:       ",",
+		CSVSafe:         true,
+		Manifest:        true,
+		BranchIsolation: IsolationGoroutine,
+		TrendFile:       "speedup_trend.csv",
+		SweepSizes:      "50,100,200,400",
+		SweepFile:       "sweep.csv",
+		DifficultyFile:  "difficulty_sweep.csv",
+		Policy:          PolicyThreshold,
+		Tie:             TieA,
+		CancelMode:      CancelDrain,
+		ConditionReps:   1,
+		ParallelRuns:    1,
+		Branches:        "A,B",
+	}
+}
+
+// BranchOutput encapsula la información relevante producida por un trabajo.
+type BranchOutput struct {
+	Numeric int64
+	Detail  string
+	// AllocPerPrime son los bytes asignados por primo encontrado; 0 si no se midió.
+	AllocPerPrime float64
+	// AllocBytes son los bytes asignados medidos por el propio trabajo (los subprocesos de rama
+	// informan los suyos); 0 deja la medición a executeBranchSync.
+	AllocBytes uint64
+	// Hashes son los nonces probados por una rama de Proof-of-Work, también cuando se cancela; 0 en
+	// las demás ramas.
+	Hashes int64
+	// Retries son los reintentos que necesitó la rama con -retries.
+	Retries int
+	// CPUTime es el tiempo de CPU medido por el propio trabajo (los subprocesos de rama informan el
+	// suyo) y CPUTimeSource, cómo se obtuvo; un CPUTimeSource vacío deja la medición a
+	// executeBranchSync.
+	CPUTime       time.Duration
+	CPUTimeSource string
+	// UsesWorkers indica que el trabajo reparte parte del cómputo en otras goroutines, cuyo tiempo
+	// de CPU no entra en la medición por hilo (ver workersWork).
+	UsesWorkers bool
+}
+
+// BranchWork representa una carga de trabajo que debe detenerse cuando ctx termina.
+type BranchWork func(ctx context.Context) (BranchOutput, error)
+
+// NamedBranch asocia un trabajo con el nombre con que se reporta la rama.
+type NamedBranch struct {
+	Name string
+	Work BranchWork
+}
+
+// WinnerSelector devuelve el nombre de la rama ganadora a partir de las métricas de la condición.
+type WinnerSelector func(metrics ConditionMetrics) string
+
+// BranchResult almacena las métricas capturadas durante la ejecución de una rama.
+type BranchResult struct {
+	Name      string
+	Numeric   int64
+	Detail    string
+	Start     time.Time
+	End       time.Time
+	Duration  time.Duration
+	Cancelled bool
+	Err       error
+	// TimedOut indica que la rama se canceló al vencer -branch-timeout y no por perder la
+	// corrida; la columna error la registra como timed_out.
+	TimedOut bool
+	// AllocPerPrime replica BranchOutput.AllocPerPrime (0 si no se midió).
+	AllocPerPrime float64
+	// FinishOrder es la posición (desde 1) en que la rama terminó dentro de su corrida; en la
+	// estrategia especulativa una rama perdedora termina cuando atiende su cancelación.
+	FinishOrder int
+	// AllocBytes es el aumento de runtime.MemStats.TotalAlloc mientras corrió la rama. El contador
+	// es global del proceso, así que en la estrategia especulativa incluye lo asignado por las
+	// ramas concurrentes y la condición; solo es exacto en la secuencial o con -branch-isolation
+	// process.
+	AllocBytes uint64
+	// Hashes replica BranchOutput.Hashes.
+	Hashes int64
+	// Retries son las veces que la rama se volvió a ejecutar tras un error con -retries; su
+	// duración incluye todos los intentos.
+	Retries int
+	// CPUTime es el tiempo de CPU que consumió la rama y CPUTimeSource, cómo se midió (ver
+	// cpuTimeThread, cpuTimeProcess y cpuTimeWall).
+	CPUTime       time.Duration
+	CPUTimeSource string
+	// Shadow es el resultado completo de una rama cancelada, recalculada con -shadow-losers;
+	// nil si no se recalculó.
+	Shadow *BranchOutput
+}
+
+// Summary reúne los agregados calculados una única vez sobre todas las corridas.
+type Summary struct {
+	AvgSpeculative      time.Duration
+	Baseline            time.Duration
+	BaselineIsReference bool
+	Speedup             float64
+	AvgParallelism      float64
+	// AvgNumericSpeculative y AvgNumericSequential promedian result_numeric por rama (ver
+	// averageNumericByBranch), porque cada rama produce un valor de distinta naturaleza.
+	AvgNumericSpeculative map[string]float64
+	AvgNumericSequential  map[string]float64
+	// PercentilesSpeculative y PercentilesSequential son las duraciones totales en los percentiles de
+	// reportedPercentiles, en el mismo orden.
+	PercentilesSpeculative []time.Duration
+	PercentilesSequential  []time.Duration
+	// DispersionSpeculative y DispersionSequential describen la variación de las duraciones totales.
+	DispersionSpeculative DurationDispersion
+	DispersionSequential  DurationDispersion
+	// DurationCorrelation es la correlación de Pearson entre las duraciones totales de las corridas
+	// pareadas (mismo número de corrida) de ambas estrategias; solo es válida si HasCorrelation.
+	DurationCorrelation float64
+	HasCorrelation      bool
+	CorrelationPairs    int
+	// SpecWinRate es la fracción de las corridas pareadas (ver pairedWinRate) en que la duración
+	// total especulativa fue menor que la secuencial; solo es válida si WinRatePairs > 0.
+	SpecWinRate  float64
+	WinRatePairs int
+	// WastedWork es la duración acumulada de las ramas canceladas en las corridas especulativas y
+	// SpeculationBenefit, el tiempo total ahorrado frente a la línea base (negativo si se perdió).
+	WastedWork         time.Duration
+	SpeculationBenefit time.Duration
+	// WinsSpeculative y WinsSequential cuentan las corridas que ganó cada rama (ver winnerCounts);
+	// deberían coincidir, porque la condición de cada corrida es la misma en ambas estrategias.
+	WinsSpeculative map[string]int
+	WinsSequential  map[string]int
+}
+
+// DurationDispersion reúne la desviación estándar muestral, el mínimo y el máximo de las
+// duraciones totales de una estrategia.
+type DurationDispersion struct {
+	Stddev time.Duration
+	Min    time.Duration
+	Max    time.Duration
+}
+
+// reportedPercentiles son los percentiles de la duración total que se informan en el resumen.
+var reportedPercentiles = []float64{50, 90, 95, 99}
+
+// ExecutionRun agrega la información relevante de una simulación completa (una corrida).
+type ExecutionRun struct {
+	Mode              string
+	RunIndex          int
+	ConditionValue    int64
+	ConditionDuration time.Duration
+	// ConditionFraction es la fracción de las filas de la traza que se calcularon (ver -early-cancel).
+	ConditionFraction float64
+	Winner            string
+	TotalDuration     time.Duration
+	RunStart          time.Time
+	Branches          []BranchResult
+}
+
+// ValidateConfig comprueba que cfg se pueda ejecutar y devuelve el primer problema encontrado,
+// descrito en términos de las flags de la línea de comandos.
+func ValidateConfig(cfg Config) error {
+	// Las flags que solo admiten CSV, o un archivo, se comprueban en cada destino de -nombre_archivo.
+	allCSV, toStdout, compressed := true, false, false
+	for _, target := range outputTargets(cfg) {
+		allCSV = allCSV && target.Format == formatCSV
+		toStdout = toStdout || target.OutputFile == StdoutPath
+		compressed = compressed || strings.HasSuffix(target.OutputFile, gzipSuffix)
+	}
+	switch {
+	case cfg.MatrixSize <= 0:
+		return errors.New("n debe ser mayor que cero")
+	case cfg.MatrixMax < 1:
+		return errors.New("matrix-max debe ser al menos 1")
+	case cfg.Cooldown < 0:
+		return errors.New("cooldown no puede ser negativo")
+	case cfg.BranchTimeout < 0:
+		return errors.New("branch-timeout no puede ser negativo")
+	case cfg.CancelMode != CancelDrain && cfg.CancelMode != CancelAbandon:
+		return fmt.Errorf("cancel-mode debe ser %q o %q", CancelDrain, CancelAbandon)
+	case cfg.ParallelRuns <= 0:
+		return errors.New("parallel-runs debe ser mayor que cero")
+	case cfg.ParallelRuns > 1 && (cfg.Interleave || cfg.Cooldown > 0):
+		return errors.New("parallel-runs no admite interleave ni cooldown, que ordenan las corridas en el tiempo")
+	case cfg.DumpMatrix != "" && cfg.MatrixSize > maxDumpMatrixSize && !cfg.Force:
+		return fmt.Errorf("dump-matrix con n mayor que %d genera un archivo de n² valores; use -force para continuar", maxDumpMatrixSize)
+	case cfg.DumpMatrix != "" && cfg.Sweep:
+		return errors.New("sweep recorre varios tamaños y no admite dump-matrix")
+	case cfg.Tie != TieA && cfg.Tie != TieB && cfg.Tie != TieRandom:
+		return fmt.Errorf("tie debe ser %q, %q o %q", TieA, TieB, TieRandom)
+	case CSVDelimiters[cfg.Delimiter] == 0:
+		return errors.New(`delimiter debe ser ",", ";", "|" o "tab"`)
+	case cfg.Retries < 0:
+		return errors.New("retries no puede ser negativo")
+	case cfg.Threshold < 0:
+		return errors.New("umbral no puede ser negativo")
+	case cfg.Runs <= 0:
+		return errors.New("runs debe ser mayor que cero")
+	case cfg.Warmup < 0:
+		return errors.New("warmup no puede ser negativo")
+	case cfg.PowDifficulty <= 0:
+		return errors.New("difficulty debe ser mayor que cero")
+	case strings.TrimSpace(cfg.PowData) == "":
+		return errors.New("pow-data no puede estar vacío")
+	case PowHashes[cfg.PowHash] == nil:
+		return fmt.Errorf("pow-hash debe ser uno de %s", strings.Join(PowHashNames(), ", "))
+	case cfg.PowMode != PowModeHex && cfg.PowMode != PowModeBits && cfg.PowMode != PowModeTarget:
+		return fmt.Errorf("pow-mode debe ser %q, %q o %q", PowModeHex, PowModeBits, PowModeTarget)
+	case cfg.PowMode == PowModeBits && cfg.PowDifficulty > powHashBits(cfg.PowHash):
+		return fmt.Errorf("con pow-mode bits y pow-hash %s, difficulty no puede superar %d", cfg.PowHash, powHashBits(cfg.PowHash))
+	case cfg.PowWorkers <= 0:
+		return errors.New("pow-workers debe ser mayor que cero")
+	case cfg.PowWorkers > 1 && (cfg.PowMode != PowModeHex || cfg.MaxNonce != 0):
+		return errors.New("pow-workers mayor que 1 solo está disponible con pow-mode hex y sin max-nonce")
+	case cfg.ConditionReps < 1:
+		return errors.New("condition-reps debe ser al menos 1")
+	case cfg.ConditionReps > 1 && (cfg.Policy == PolicyMulti || cfg.EarlyCancel || cfg.MatrixFile != ""):
+		return errors.New("condition-reps promedia trazas de matrices aleatorias y no admite policy multi, early-cancel ni matrix-file")
+	case cfg.Symmetric && (cfg.Policy == PolicyMulti || cfg.EarlyCancel || cfg.ConditionReps > 1 || cfg.MatrixFile != ""):
+		return errors.New("symmetric genera una única matriz aleatoria y no admite policy multi, early-cancel, condition-reps ni matrix-file")
+	case cfg.MinSpeedup < 0:
+		return errors.New("min-speedup no puede ser negativo")
+	case cfg.MinSpeedup > 0 && (cfg.Sweep || cfg.DifficultySweep != ""):
+		return errors.New("min-speedup controla el speedup de un único lote y no admite sweep ni difficulty-sweep")
+	case cfg.Race && cfg.EarlyCancel:
+		return errors.New("race no usa la condición para cancelar ramas y no admite early-cancel")
+	case cfg.EarlyCancel && (cfg.Policy != PolicyThreshold || cfg.MatrixFile != ""):
+		return errors.New("early-cancel solo admite policy threshold con matrices aleatorias, cuyos elementos acotan lo que falta sumar")
+	case cfg.Policy != PolicyThreshold && cfg.Policy != PolicyMulti && cfg.Policy != PolicyParity:
+		return fmt.Errorf("policy debe ser %q, %q o %q", PolicyThreshold, PolicyMulti, PolicyParity)
+	case cfg.MaxNonce < 0:
+		return errors.New("max-nonce no puede ser negativo")
+	case cfg.PrimesLimit <= 0:
+		return errors.New("primes-limit debe ser mayor que cero")
+	case cfg.PrimesBits != 0 && (cfg.PrimesBits < 2 || cfg.PrimesBits > maxPrimesBits):
+		return fmt.Errorf("primes-bits debe estar entre 2 y %d", maxPrimesBits)
+	case cfg.PrimesAlgo != PrimesTrial && cfg.PrimesAlgo != PrimesSieve && cfg.PrimesAlgo != PrimesSieveParallel && cfg.PrimesAlgo != PrimesSegmented:
+		return fmt.Errorf("primes-algo debe ser %q, %q, %q o %q", PrimesTrial, PrimesSieve, PrimesSieveParallel, PrimesSegmented)
+	case cfg.PrimesSegment <= 0:
+		return errors.New("primes-segment debe ser mayor que cero")
+	case cfg.PrimesWorkers <= 0:
+		return errors.New("primes-workers debe ser mayor que cero")
+	case cfg.PrimesBits != 0 && cfg.PrimesAlgo != PrimesTrial:
+		return errors.New("primes-bits solo está disponible con primes-algo trial")
+	case cfg.PrimesLow < 0:
+		return errors.New("primes-low no puede ser negativo")
+	// Con el primes-low por defecto un primes-limit de 2 o menos es un rango vacío, sin primos, como
+	// antes de -primes-low; solo se rechaza un rango vacío pedido con una cota inferior explícita.
+	case cfg.PrimesLow > 2 && cfg.PrimesLow >= cfg.PrimesLimit:
+		return errors.New("primes-low debe ser menor que primes-limit")
+	case cfg.PrimesLow > 2 && (cfg.PrimesAlgo != PrimesTrial || cfg.PrimesBits != 0):
+		return errors.New("primes-low solo está disponible con primes-algo trial y sin primes-bits")
+	case cfg.PrimesStats && cfg.PrimesAlgo == PrimesSegmented:
+		return errors.New("primes-stats recorre cada primo y no admite primes-algo segmented, que solo los cuenta")
+	case cfg.PrimesStats && cfg.WorkloadSpec != "":
+		return errors.New("primes-stats solo se aplica a la rama B por defecto y no admite workload-spec")
+	case cfg.AllocPerPrime && cfg.WorkloadSpec != "":
+		return errors.New("alloc-per-prime solo se aplica a la rama B por defecto y no admite workload-spec")
+	case cfg.BranchIsolation != IsolationGoroutine && cfg.BranchIsolation != IsolationProcess:
+		return fmt.Errorf("branch-isolation debe ser %q o %q", IsolationGoroutine, IsolationProcess)
+	case cfg.SampleRows <= 0:
+		return errors.New("sample-rows debe ser mayor que cero")
+	case cfg.ReferenceMs < 0 || math.IsNaN(cfg.ReferenceMs) || math.IsInf(cfg.ReferenceMs, 0):
+		return errors.New("reference-ms debe ser un número no negativo")
+	case cfg.SpeedupTrend < 0:
+		return errors.New("speedup-trend no puede ser negativo")
+	case cfg.SpeedupTrend > 0 && strings.TrimSpace(cfg.TrendFile) == "":
+		return errors.New("speedup-trend-file no puede estar vacío")
+	case cfg.MaxOutputBytes < 0:
+		return errors.New("max-output-bytes no puede ser negativo")
+	case cfg.Rotate && cfg.MaxOutputBytes == 0:
+		return errors.New("rotate requiere max-output-bytes")
+	case cfg.MaxOutputBytes > 0 && !allCSV:
+		return errors.New("max-output-bytes solo está disponible con format csv")
+	case cfg.Append && !allCSV:
+		return errors.New("append solo está disponible con format csv")
+	case toStdout && (cfg.Append || cfg.Rotate):
+		return errors.New("con nombre_archivo - las métricas van a la salida estándar, que no admite append ni rotate")
+	case cfg.Append && cfg.MaxOutputBytes > 0:
+		return errors.New("append no admite max-output-bytes")
+	case cfg.SummaryOnly && cfg.JSONFlat:
+		return errors.New("summary-only no admite json-flat, que solo cambia cómo se escriben las corridas")
+	case cfg.Append && compressed:
+		return errors.New("append no admite un nombre_archivo comprimido (.gz)")
+	case strings.TrimSpace(cfg.OutputFile) == "":
+		return errors.New("nombre_archivo no puede estar vacío")
+	case cfg.Format != formatCSV && cfg.Format != formatJSON && cfg.Format != formatNDJSON:
+		return fmt.Errorf("format debe ser %q, %q o %q", formatCSV, formatJSON, formatNDJSON)
+	case cfg.Sweep && cfg.MatrixFile != "":
+		return errors.New("sweep recorre tamaños de matriz aleatorios y no admite matrix-file")
+	case cfg.Interleave && cfg.ReferenceMs > 0:
+		return errors.New("interleave requiere la estrategia secuencial y no admite reference-ms")
+	case cfg.Quiet && cfg.Verbose:
+		return errors.New("quiet y verbose no pueden usarse juntas")
+	case cfg.SelfCheck && (cfg.Sweep || cfg.DifficultySweep != ""):
+		return errors.New("selfcheck no admite sweep ni difficulty-sweep")
+	case cfg.DifficultySweep != "" && cfg.Sweep:
+		return errors.New("difficulty-sweep no admite sweep; ejecute un barrido a la vez")
+	case cfg.DifficultySweep != "" && cfg.WorkloadSpec != "":
+		return errors.New("difficulty-sweep no admite workload-spec, que fija la dificultad de cada rama")
+	case cfg.DifficultySweep != "" && cfg.DumpMatrix != "":
+		return errors.New("difficulty-sweep no admite dump-matrix")
+	case cfg.DifficultySweep != "" && strings.TrimSpace(cfg.DifficultyFile) == "":
+		return errors.New("difficulty-sweep-file no puede estar vacío")
+	case cfg.Sweep && strings.TrimSpace(cfg.SweepFile) == "":
+		return errors.New("sweep-file no puede estar vacío")
+	}
+	if cfg.Sweep {
+		if _, err := parseSweepSizes(cfg.SweepSizes); err != nil {
+			return fmt.Errorf("sizes inválido: %w", err)
+		}
+	}
+	if cfg.DifficultySweep != "" {
+		if _, err := parseDifficultySweep(cfg.DifficultySweep); err != nil {
+			return fmt.Errorf("difficulty-sweep inválido: %w", err)
+		}
+	}
+	if cfg.WorkloadSpec == "" {
+		if _, err := parseBranchNames(cfg.Branches); err != nil {
+			return fmt.Errorf("branches inválido: %w", err)
+		}
+	}
+	if _, err := ParseStopSignals(cfg.StopSignals); err != nil {
+		return fmt.Errorf("stop-signals inválido: %w", err)
+	}
+	if _, err := parseOutputFiles(cfg.OutputFile); err != nil {
+		return fmt.Errorf("nombre_archivo inválido: %w", err)
+	}
+	return nil
+}
+
+// BuildBranchWorkload arma las ramas a ejecutar según el aislamiento configurado: en la propia
+// goroutine o, con -branch-isolation process, delegando cada rama en un subproceso.
+func BuildBranchWorkload(cfg Config) ([]NamedBranch, error) {
+	branches, err := buildLocalBranches(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for i := range branches {
+		if cfg.BranchIsolation == IsolationProcess {
+			branches[i].Work = processBranchWork(branches[i].Name, cfg.WorkerArgs, cfg.Seed)
+		}
+		if cfg.Retries > 0 {
+			branches[i].Work = retryWork(branches[i].Work, cfg.Retries)
+		}
+		if cfg.BranchTimeout > 0 {
+			branches[i].Work = timeoutWork(branches[i].Work, cfg.BranchTimeout)
+		}
+	}
+	return branches, nil
+}
+
+// timeoutWork cancela work si no termina dentro de timeout, que abarca también los reintentos. Si
+// el plazo vence antes de que ctx termine, el error de cancelación incluye ErrBranchTimeout.
+func timeoutWork(work BranchWork, timeout time.Duration) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		branchCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		output, err := work(branchCtx)
+		if errors.Is(err, ErrCancelled) && ctx.Err() == nil && errors.Is(branchCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%w: %w", ErrCancelled, ErrBranchTimeout)
+		}
+		return output, err
+	}
+}
+
+// retryWork vuelve a ejecutar work hasta retries veces mientras falle con un error transitorio, y
+// completa Retries con los reintentos usados. La cancelación y ErrExhausted no se reintentan: la
+// primera es una orden de detenerse y el agotamiento de nonces se repetiría igual.
+func retryWork(work BranchWork, retries int) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		attempt := 0
+		for {
+			output, err := work(ctx)
+			output.Retries = attempt
+			if err == nil || errors.Is(err, ErrCancelled) || errors.Is(err, ErrExhausted) ||
+				attempt == retries || ctx.Err() != nil {
+				return output, err
+			}
+			attempt++
+		}
+	}
+}
+
+// buildLocalBranches arma las ramas a ejecutar, en orden: las del archivo -workload-spec si se
+// indicó, o en su defecto las ramas registradas que elige -branches (por defecto la rama A de
+// Proof-of-Work y la rama B de primos del enunciado).
+func buildLocalBranches(cfg Config) ([]NamedBranch, error) {
+	if cfg.WorkloadSpec != "" {
+		return loadWorkloadSpec(cfg.WorkloadSpec)
+	}
+	names, err := parseBranchNames(cfg.Branches)
+	if err != nil {
+		return nil, err
+	}
+	branches := make([]NamedBranch, 0, len(names))
+	for _, name := range names {
+		branches = append(branches, NamedBranch{Name: name, Work: branchRegistry[name].Factory(cfg)})
+	}
+	return branches, nil
+}
+
+// primesBranchWork arma la rama B registrada: la búsqueda de primos de -primes-algo.
+func primesBranchWork(cfg Config) BranchWork {
+	primes := primesWork(cfg.PrimesLow, cfg.PrimesLimit)
+	switch {
+	case cfg.PrimesBits > 0:
+		primes = primesBitsWork(cfg.PrimesBits)
+	case cfg.PrimesAlgo == PrimesSieve:
+		primes = primesSieveWork(cfg.PrimesLimit)
+	case cfg.PrimesAlgo == PrimesSieveParallel:
+		primes = primesSieveParallelWork(cfg.PrimesLimit, cfg.PrimesWorkers)
+	case cfg.PrimesAlgo == PrimesSegmented:
+		primes = primesSegmentedWork(cfg.PrimesLimit, cfg.PrimesSegment)
+	}
+	if cfg.PrimesStats {
+		primes = primeStatsWork(primeVisitor(cfg))
+	}
+	if cfg.AllocPerPrime {
+		primes = allocPerPrimeWork(primes)
+	}
+	if cfg.PrimesBits == 0 && cfg.PrimesAlgo == PrimesSieveParallel {
+		primes = workersWork(primes)
+	}
+	return primes
+}
+
+// powBranchWork arma la rama A registrada: el Proof-of-Work de -pow-mode.
+func powBranchWork(cfg Config) BranchWork {
+	hash := PowHashes[cfg.PowHash]
+	pow := powWork(hash, cfg.PowData, cfg.PowDifficulty, cfg.MaxNonce)
+	switch {
+	case cfg.PowMode == PowModeBits:
+		pow = powBitsWork(hash, cfg.PowData, cfg.PowDifficulty, cfg.MaxNonce)
+	case cfg.PowMode == PowModeTarget:
+		target := targetForBits(cfg.PowDifficulty, powHashBits(cfg.PowHash))
+		pow = powTargetWork(hash, cfg.PowData, target, cfg.MaxNonce)
+	case cfg.PowWorkers > 1:
+		pow = workersWork(powParallelWork(hash, cfg.PowData, cfg.PowDifficulty, cfg.PowWorkers))
+	}
+	return pow
+}
+
+// workersWork marca la salida de work con UsesWorkers, para que executeBranchSync informe su tiempo
+// de CPU como thread-only: la medición por hilo excluye el de las goroutines que work lanza.
+func workersWork(work BranchWork) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		output, err := work(ctx)
+		output.UsesWorkers = true
+		return output, err
+	}
+}
+
+func powWork(hashFunc HashFunc, data string, difficulty, maxNonce int) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		hash, nonce, attempts, err := proofOfWorkSearch(ctx, hashFunc, data, difficulty, maxNonce)
+		if err != nil && !errors.Is(err, ErrCancelled) {
+			return BranchOutput{}, err
+		}
+		detail := fmt.Sprintf("hash=%s", hash)
+		return BranchOutput{
+			Numeric: int64(nonce),
+			Detail:  detail,
+			Hashes:  int64(attempts),
+		}, err
+	}
+}
+
+func powBitsWork(hashFunc HashFunc, data string, bits, maxNonce int) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		hash, nonce, attempts, err := powBitsSearch(ctx, hashFunc, data, bits, maxNonce)
+		if err != nil && !errors.Is(err, ErrCancelled) {
+			return BranchOutput{}, err
+		}
+		return BranchOutput{
+			Numeric: int64(nonce),
+			Detail:  fmt.Sprintf("hash=%s", hash),
+			Hashes:  int64(attempts),
+		}, err
+	}
+}
+
+func powTargetWork(hashFunc HashFunc, data string, target *big.Int, maxNonce int) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		hash, nonce, attempts, err := powTargetSearch(ctx, hashFunc, data, target, maxNonce)
+		if err != nil && !errors.Is(err, ErrCancelled) {
+			return BranchOutput{}, err
+		}
+		return BranchOutput{
+			Numeric: int64(nonce),
+			Detail:  fmt.Sprintf("hash=%s", hash),
+			Hashes:  int64(attempts),
+		}, err
+	}
+}
+
+func powParallelWork(hashFunc HashFunc, data string, difficulty, workers int) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		hash, nonce, attempts, err := powParallelSearch(ctx, hashFunc, data, difficulty, workers)
+		if err != nil && !errors.Is(err, ErrCancelled) {
+			return BranchOutput{}, err
+		}
+		return BranchOutput{
+			Numeric: int64(nonce),
+			Detail:  fmt.Sprintf("hash=%s", hash),
+			Hashes:  int64(attempts),
+		}, err
+	}
+}
+
+// primesWork es la rama B por defecto: cuenta los primos de [low, limit) por división sucesiva
+// sin guardarlos.
+func primesWork(low, limit int) BranchWork {
+	return primeCountWork(func(ctx context.Context) (int, int, error) {
+		return contarPrimosRangoCtx(ctx, low, limit)
+	})
+}
+
+func primesSieveWork(limit int) BranchWork {
+	return primeSearchWork(func(ctx context.Context) ([]int, error) {
+		return EncontrarPrimosSieveCtx(ctx, limit)
+	})
+}
+
+func primesSieveParallelWork(limit, workers int) BranchWork {
+	return primeSearchWork(func(ctx context.Context) ([]int, error) {
+		return EncontrarPrimosSieveParallelCtx(ctx, limit, workers)
+	})
+}
+
+func primesSegmentedWork(limit, segmentSize int) BranchWork {
+	return primeCountWork(func(ctx context.Context) (int, int, error) {
+		return EncontrarPrimosSegmentedCtx(ctx, limit, segmentSize)
+	})
+}
+
+// primeCountWork informa lo mismo que primeSearchWork, pero a partir de un conteo (cantidad y
+// último primo), sin materializar la lista de primos.
+func primeCountWork(countPrimes func(ctx context.Context) (int, int, error)) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		count, last, err := countPrimes(ctx)
+		if err != nil && !errors.Is(err, ErrCancelled) {
+			return BranchOutput{}, err
+		}
+		detail := "count=0"
+		if count > 0 {
+			detail = fmt.Sprintf("count=%d,last=%d", count, last)
+		}
+		return BranchOutput{
+			Numeric: int64(count),
+			Detail:  detail,
+		}, err
+	}
+}
+
+func primesBitsWork(bits int) BranchWork {
+	return primeSearchWork(func(ctx context.Context) ([]int, error) {
+		return EncontrarPrimosEnRangoBits(ctx.Done(), bits)
+	})
+}
+
+// primeSearchWork adapta una búsqueda de primos a una rama cuyo detalle informa la cantidad
+// encontrada y el último primo.
+func primeSearchWork(search func(ctx context.Context) ([]int, error)) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		primes, err := search(ctx)
+		if err != nil && !errors.Is(err, ErrCancelled) {
+			return BranchOutput{}, err
+		}
+		var detail string
+		if len(primes) > 0 {
+			detail = fmt.Sprintf("count=%d,last=%d", len(primes), primes[len(primes)-1])
+		} else {
+			detail = "count=0"
+		}
+		return BranchOutput{
+			Numeric: int64(len(primes)),
+			Detail:  detail,
+		}, err
+	}
+}
+
+// primeStats acumula, en una sola pasada sobre primos en orden creciente, la cantidad, el último,
+// los pares de primos gemelos (p y p+2) y la mayor brecha entre dos primos consecutivos.
+type primeStats struct {
+	count, last, twins, maxGap int
+}
+
+func (s *primeStats) add(p int) {
+	if s.count > 0 {
+		gap := p - s.last
+		if gap == 2 {
+			s.twins++
+		}
+		s.maxGap = max(s.maxGap, gap)
+	}
+	s.count++
+	s.last = p
+}
+
+// primeVisitor devuelve un recorrido, en orden, de los primos que busca la rama B según cfg: la
+// división sucesiva los visita a medida que los encuentra y las demás estrategias recorren la
+// lista que producen.
+func primeVisitor(cfg Config) func(ctx context.Context, visit func(p int)) error {
+	var search func(ctx context.Context) ([]int, error)
+	switch {
+	case cfg.PrimesBits > 0:
+		search = func(ctx context.Context) ([]int, error) {
+			return EncontrarPrimosEnRangoBits(ctx.Done(), cfg.PrimesBits)
+		}
+	case cfg.PrimesAlgo == PrimesSieve:
+		search = func(ctx context.Context) ([]int, error) { return EncontrarPrimosSieveCtx(ctx, cfg.PrimesLimit) }
+	case cfg.PrimesAlgo == PrimesSieveParallel:
+		search = func(ctx context.Context) ([]int, error) {
+			return EncontrarPrimosSieveParallelCtx(ctx, cfg.PrimesLimit, cfg.PrimesWorkers)
+		}
+	default:
+		return func(ctx context.Context, visit func(p int)) error {
+			return visitPrimesInRange(ctx, max(cfg.PrimesLow, 2), cfg.PrimesLimit, visit)
+		}
+	}
+	return func(ctx context.Context, visit func(p int)) error {
+		primes, err := search(ctx)
+		for _, p := range primes {
+			visit(p)
+		}
+		return err
+	}
+}
+
+// primeStatsWork es la rama B de -primes-stats: además de la cantidad y el último primo, el
+// detalle informa twins (pares de primos gemelos) y maxgap (mayor brecha entre consecutivos).
+func primeStatsWork(visitPrimes func(ctx context.Context, visit func(p int)) error) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		var stats primeStats
+		err := visitPrimes(ctx, stats.add)
+		if err != nil && !errors.Is(err, ErrCancelled) {
+			return BranchOutput{}, err
+		}
+		detail := "count=0"
+		if stats.count > 0 {
+			detail = fmt.Sprintf("count=%d,last=%d", stats.count, stats.last)
+		}
+		return BranchOutput{
+			Numeric: int64(stats.count),
+			Detail:  fmt.Sprintf("%s;twins=%d;maxgap=%d", detail, stats.twins, stats.maxGap),
+		}, err
+	}
+}
+
+// allocPerPrimeWork envuelve una búsqueda de primos y completa AllocPerPrime con los bytes
+// asignados en el heap durante la búsqueda divididos por la cantidad de primos (Numeric).
+// TotalAlloc es global al proceso: con ramas concurrentes en goroutines la medición incluye lo
+// asignado por las demás, por lo que es exacta en la estrategia secuencial o con
+// -branch-isolation process.
+func allocPerPrimeWork(work BranchWork) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		before := heapAllocated()
+		output, err := work(ctx)
+		allocated := heapAllocated() - before
+		if output.Numeric > 0 {
+			output.AllocPerPrime = float64(allocated) / float64(output.Numeric)
+		}
+		return output, err
+	}
+}
+
+// heapAllocated devuelve los bytes asignados en el heap (acumulados) desde el inicio del proceso.
+func heapAllocated() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.TotalAlloc
+}
+
+// CollectRuns ejecuta cfg.Runs corridas de la estrategia mode (ModeSpeculative o ModeSequential)
+// y las devuelve en orden. Antes ejecuta por el mismo camino cfg.Warmup corridas de calentamiento
+// (con los números 1…Warmup), cuyos resultados se descartan. Si ctx termina, devuelve las
+// corridas medidas completadas junto con ErrInterrupted; cualquier otro error detiene el lote. No
+// comparte estado mutable entre invocaciones (cada corrida usa un generador propio derivado de
+// cfg.Seed y su índice), por lo que puede llamarse desde varias goroutines a la vez siempre que
+// las ramas también lo permitan, como ocurre con las incluidas.
+func CollectRuns(ctx context.Context, cfg Config, mode string, branches []NamedBranch) ([]ExecutionRun, error) {
+	return collectRuns(ctx, cfg, mode, branches, nil)
+}
+
+// collectRuns es CollectRuns con onRun, que (si no es nil) recibe cada corrida medida en cuanto
+// termina; un error de onRun detiene el lote y se devuelve sin envolver.
+func collectRuns(ctx context.Context, cfg Config, mode string, branches []NamedBranch, onRun func(ExecutionRun) error) ([]ExecutionRun, error) {
+	if mode != ModeSpeculative && mode != ModeSequential {
+		return nil, fmt.Errorf("modo desconocido %q", mode)
+	}
+	if err := warmupRuns(ctx, cfg, mode, branches); err != nil {
+		return nil, err
+	}
+
+	// Las corridas secuenciales que todavía no empezaron entran en la estimación del avance.
+	pending := 0
+	if mode == ModeSpeculative && cfg.ReferenceMs <= 0 {
+		pending = cfg.Warmup + cfg.Runs
+	}
+	if cfg.ParallelRuns > 1 {
+		return collectRunsParallel(ctx, cfg, mode, branches, onRun, pending)
+	}
+
+	runs := make([]ExecutionRun, 0, cfg.Runs)
+	start := runClock(cfg).Now()
+	for i := 1; i <= cfg.Runs; i++ {
+		if i > 1 {
+			if err := cooldown(ctx, cfg); err != nil {
+				return runs, err
+			}
+		}
+		run, err := measuredRun(ctx, cfg, mode, i, branches, onRun)
+		if err != nil {
+			return runs, err
+		}
+		runs = append(runs, run)
+		reportProgress(cfg, mode, i, i, cfg.Runs-i+pending, start)
+	}
+	return runs, nil
+}
+
+// collectRunsParallel es collectRuns con cfg.ParallelRuns corridas medidas en ejecución a la vez,
+// repartidas entre un grupo de workers. Las corridas se entregan a onRun y se devuelven en orden de
+// índice aunque terminen desordenadas. Como compiten por la CPU, la duración de cada una queda
+// inflada y el speedup deja de ser representativo; sirve para reunir muchas muestras de la
+// condición y de las ramas ganadoras rápidamente. Ante el primer error se cancelan las corridas en
+// curso y se devuelven las completadas hasta la primera faltante.
+func collectRunsParallel(ctx context.Context, cfg Config, mode string, branches []NamedBranch, onRun func(ExecutionRun) error, pending int) ([]ExecutionRun, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		index int
+		run   ExecutionRun
+		err   error
+	}
+	indices := make(chan int)
+	outcomes := make(chan outcome)
+
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.ParallelRuns; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				run, err := collectRun(ctx, cfg, mode, i, branches)
+				outcomes <- outcome{index: i, run: run, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(indices)
+		for i := 1; i <= cfg.Runs; i++ {
+			select {
+			case indices <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	runs := make([]ExecutionRun, 0, cfg.Runs)
+	completed := make(map[int]ExecutionRun)
+	start := runClock(cfg).Now()
+	var firstErr error
+	for result := range outcomes {
+		if firstErr != nil {
+			continue
+		}
+		if result.err != nil {
+			firstErr = measuredRunError(mode, result.index, result.err)
+			cancel()
+			continue
+		}
+		completed[result.index] = result.run
+		for {
+			run, ok := completed[len(runs)+1]
+			if !ok {
+				break
+			}
+			delete(completed, run.RunIndex)
+			if onRun != nil {
+				if err := onRun(run); err != nil {
+					firstErr = err
+					cancel()
+					break
+				}
+			}
+			runs = append(runs, run)
+			reportProgress(cfg, mode, len(runs), len(runs), cfg.Runs-len(runs)+pending, start)
+		}
+	}
+	return runs, firstErr
+}
+
+// collectInterleaved ejecuta las corridas de ambas estrategias alternadas (especulativa 1,
+// secuencial 1, especulativa 2, ...), para que las dos enfrenten condiciones del sistema similares
+// (temperatura, carga de fondo). Las corridas de calentamiento se ejecutan antes, también
+// alternadas. Devuelve las corridas de cada estrategia por separado, igual que CollectRuns.
+func collectInterleaved(ctx context.Context, cfg Config, branches []NamedBranch, onRun func(ExecutionRun) error) (specRuns, seqRuns []ExecutionRun, err error) {
+	for i := 1; i <= cfg.Warmup; i++ {
+		for _, mode := range []string{ModeSpeculative, ModeSequential} {
+			if err := warmupRun(ctx, cfg, mode, i, branches); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	specRuns = make([]ExecutionRun, 0, cfg.Runs)
+	seqRuns = make([]ExecutionRun, 0, cfg.Runs)
+	start := runClock(cfg).Now()
+	for i := 1; i <= cfg.Runs; i++ {
+		if i > 1 {
+			if err := cooldown(ctx, cfg); err != nil {
+				return specRuns, seqRuns, err
+			}
+		}
+		run, err := measuredRun(ctx, cfg, ModeSpeculative, i, branches, onRun)
+		if err != nil {
+			return specRuns, seqRuns, err
+		}
+		specRuns = append(specRuns, run)
+		reportProgress(cfg, ModeSpeculative, i, 2*i-1, 2*(cfg.Runs-i)+1, start)
+
+		if err := cooldown(ctx, cfg); err != nil {
+			return specRuns, seqRuns, err
+		}
+		run, err = measuredRun(ctx, cfg, ModeSequential, i, branches, onRun)
+		if err != nil {
+			return specRuns, seqRuns, err
+		}
+		seqRuns = append(seqRuns, run)
+		reportProgress(cfg, ModeSequential, i, 2*i, 2*(cfg.Runs-i), start)
+	}
+	return specRuns, seqRuns, nil
+}
+
+// cooldown espera cfg.Cooldown antes de la siguiente corrida medida, para que la CPU se enfríe y
+// las últimas corridas del lote no resulten más lentas que las primeras. Quienes la invocan lo
+// hacen solo entre corridas, nunca después de la última; sin cooldown vuelve de inmediato. Si ctx
+// termina durante la espera devuelve ErrInterrupted.
+func cooldown(ctx context.Context, cfg Config) error {
+	if cfg.Cooldown <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(cfg.Cooldown)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ErrInterrupted
+	}
+}
+
+// warmupRuns ejecuta las cfg.Warmup corridas de calentamiento de mode y descarta sus resultados.
+func warmupRuns(ctx context.Context, cfg Config, mode string, branches []NamedBranch) error {
+	for i := 1; i <= cfg.Warmup; i++ {
+		if err := warmupRun(ctx, cfg, mode, i, branches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func warmupRun(ctx context.Context, cfg Config, mode string, runIndex int, branches []NamedBranch) error {
+	if _, err := collectRun(ctx, cfg, mode, runIndex, branches); err != nil {
+		if errors.Is(err, ErrInterrupted) {
+			return err
+		}
+		return fmt.Errorf("%s warmup run %d failed: %w", mode, runIndex, err)
+	}
+	return nil
+}
+
+// measuredRun ejecuta la corrida medida runIndex de mode y se la entrega a onRun (si no es nil).
+func measuredRun(ctx context.Context, cfg Config, mode string, runIndex int, branches []NamedBranch, onRun func(ExecutionRun) error) (ExecutionRun, error) {
+	run, err := collectRun(ctx, cfg, mode, runIndex, branches)
+	if err != nil {
+		return ExecutionRun{}, measuredRunError(mode, runIndex, err)
+	}
+	if onRun != nil {
+		if err := onRun(run); err != nil {
+			return ExecutionRun{}, err
+		}
+	}
+	return run, nil
+}
+
+// measuredRunError identifica la corrida que falló con err; una interrupción se devuelve tal cual.
+func measuredRunError(mode string, runIndex int, err error) error {
+	if errors.Is(err, ErrInterrupted) {
+		return err
+	}
+	return fmt.Errorf("%s run %d failed: %w", mode, runIndex, err)
+}
+
+// collectRun ejecuta la corrida runIndex de la estrategia mode, incluido el recálculo de las ramas
+// canceladas de -shadow-losers.
+func collectRun(ctx context.Context, cfg Config, mode string, runIndex int, branches []NamedBranch) (ExecutionRun, error) {
+	if ctx.Err() != nil {
+		return ExecutionRun{}, ErrInterrupted
+	}
+	if mode == ModeSequential {
+		return runSequential(ctx, cfg, runIndex, branches)
+	}
+	speculate := runSpeculative
+	if cfg.Race {
+		speculate = runRace
+	}
+	run, err := speculate(ctx, cfg, runIndex, branches)
+	if err == nil && cfg.ShadowLosers {
+		shadowLosers(ctx, &run, branches)
+	}
+	return run, err
+}
+
+// runSpeculative lanza todas las ramas, cada una con un contexto hijo de ctx, mientras evalúa la
+// condición; al conocer la ganadora cancela el contexto de todas las demás. Si ctx termina (por una
+// señal de detención) también se cancela la ganadora y la corrida se descarta con ErrInterrupted.
+// Con -cancel-mode abandon la corrida termina al llegar el resultado de la ganadora: las perdedoras
+// que aún no respondieron a la cancelación no cuentan en TotalDuration ni aparecen en Branches, y
+// drainResults recoge sus resultados en segundo plano.
+func runSpeculative(ctx context.Context, cfg Config, runIndex int, branches []NamedBranch) (ExecutionRun, error) {
+	if len(branches) == 0 {
+		return ExecutionRun{}, errors.New("debe definirse al menos una rama")
+	}
+
+	clock := runClock(cfg)
+	runStart := clock.Now()
+	resultsCh := make(chan BranchResult, len(branches))
+
+	cancels := make(map[string]context.CancelFunc, len(branches))
+	for _, branch := range branches {
+		branchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cancels[branch.Name] = cancel
+		go executeBranchAsync(branchCtx, clock, branch.Name, branch.Work, resultsCh)
+	}
+
+	// abort cancela todas las ramas y espera a que terminen las que siguen en curso antes de
+	// devolver err, para que ninguna goroutine sobreviva a la corrida.
+	received := 0
+	abort := func(err error) (ExecutionRun, error) {
+		for _, cancel := range cancels {
+			cancel()
+		}
+		for ; received < len(branches); received++ {
+			<-resultsCh
+		}
+		return ExecutionRun{}, err
+	}
+
+	conditionStart := clock.Now()
+	metrics, err := evaluateCondition(cfg, runIndex)
+	conditionDuration := clock.Now().Sub(conditionStart)
+	if err != nil {
+		return abort(err)
+	}
+
+	winner := selectWinner(cfg, metrics)
+	if _, ok := cancels[winner]; !ok {
+		return abort(fmt.Errorf("no existe la rama %s", winner))
+	}
+	for name, cancel := range cancels {
+		if name != winner {
+			cancel()
+		}
+	}
+
+	results := make([]BranchResult, 0, len(branches))
+	interrupted := false
+	for len(results) < len(branches) {
+		result := <-resultsCh
+		received++
+		result.FinishOrder = received
+		if result.Err != nil && !errors.Is(result.Err, ErrExhausted) {
+			return abort(&BranchError{Name: result.Name, RunIndex: runIndex, Err: result.Err})
+		}
+		if result.Name == winner && result.Cancelled && !result.TimedOut {
+			interrupted = true
+		}
+		results = append(results, result)
+		if result.Name == winner && cfg.CancelMode == CancelAbandon {
+			break
+		}
+	}
+	totalDuration := clock.Now().Sub(runStart)
+	if pending := len(branches) - received; pending > 0 {
+		go drainResults(resultsCh, pending)
+	}
+	if interrupted {
+		return ExecutionRun{}, ErrInterrupted
+	}
+
+	return ExecutionRun{
+		Mode:              ModeSpeculative,
+		RunIndex:          runIndex,
+		ConditionValue:    metrics.Trace,
+		ConditionDuration: conditionDuration,
+		ConditionFraction: float64(metrics.Rows) / float64(cfg.MatrixSize),
+		Winner:            winner,
+		TotalDuration:     totalDuration,
+		RunStart:          runStart,
+		Branches:          results,
+	}, nil
+}
+
+// drainResults recibe los pending resultados de resultsCh que runSpeculative dejó de esperar con
+// -cancel-mode abandon. Esas ramas ya están canceladas y el canal tiene lugar para todos sus
+// resultados, así que ninguna queda bloqueada al enviar; la goroutine termina con el último.
+func drainResults(resultsCh <-chan BranchResult, pending int) {
+	for ; pending > 0; pending-- {
+		<-resultsCh
+	}
+}
+
+// shadowLosers ejecuta hasta el final cada rama cancelada de run y guarda su resultado en Shadow,
+// para comprobar que la cancelación no alteró lo que la rama habría producido. Se llama después
+// de medir la corrida, por lo que no afecta sus tiempos. Si ctx termina, la rama en curso queda
+// sin resultado sombra.
+func shadowLosers(ctx context.Context, run *ExecutionRun, branches []NamedBranch) {
+	for i := range run.Branches {
+		branch := &run.Branches[i]
+		if !branch.Cancelled {
+			continue
+		}
+		work, ok := findBranch(branches, branch.Name)
+		if !ok {
+			continue
+		}
+		output, err := work(ctx)
+		switch {
+		case errors.Is(err, ErrCancelled):
+			return
+		case err != nil:
+			output = BranchOutput{Detail: "error=" + err.Error()}
+		}
+		branch.Shadow = &output
+	}
+}
+
+func runSequential(ctx context.Context, cfg Config, runIndex int, branches []NamedBranch) (ExecutionRun, error) {
+	clock := runClock(cfg)
+	runStart := clock.Now()
+
+	conditionStart := clock.Now()
+	metrics, err := evaluateCondition(cfg, runIndex)
+	conditionDuration := clock.Now().Sub(conditionStart)
+	if err != nil {
+		return ExecutionRun{}, err
+	}
+
+	winner := selectWinner(cfg, metrics)
+	work, ok := findBranch(branches, winner)
+	if !ok {
+		return ExecutionRun{}, fmt.Errorf("no existe la rama %s", winner)
+	}
+
+	result := executeBranchSync(ctx, clock, winner, work)
+	result.FinishOrder = 1
+	if result.Err != nil && !errors.Is(result.Err, ErrExhausted) {
+		return ExecutionRun{}, &BranchError{Name: result.Name, RunIndex: runIndex, Err: result.Err}
+	}
+	if result.Cancelled && !result.TimedOut {
+		return ExecutionRun{}, ErrInterrupted
+	}
+
+	totalDuration := clock.Now().Sub(runStart)
+
+	return ExecutionRun{
+		Mode:              ModeSequential,
+		RunIndex:          runIndex,
+		ConditionValue:    metrics.Trace,
+		ConditionDuration: conditionDuration,
+		ConditionFraction: float64(metrics.Rows) / float64(cfg.MatrixSize),
+		Winner:            winner,
+		TotalDuration:     totalDuration,
+		RunStart:          runStart,
+		Branches:          []BranchResult{result},
+	}, nil
+}
+
+func executeBranchAsync(ctx context.Context, clock Clock, name string, work BranchWork, out chan<- BranchResult) {
+	out <- executeBranchSync(ctx, clock, name, work)
+}
+
+// executeBranchSync ejecuta work en la goroutine actual y mide con clock su intervalo; el tiempo
+// de CPU y la memoria asignada se miden siempre con los contadores reales.
+func executeBranchSync(ctx context.Context, clock Clock, name string, work BranchWork) BranchResult {
+	// La rama queda fijada a su hilo para que el tiempo de CPU del hilo sea el de la rama.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// La lectura de MemStats detiene brevemente el mundo, así que queda fuera del intervalo medido.
+	allocBefore := heapAllocated()
+	cpuBefore, threadCPU := threadCPUTime()
+	start := clock.Now()
+	output, err := work(ctx)
+	end := clock.Now()
+	cpuAfter, _ := threadCPUTime()
+	allocBytes := heapAllocated() - allocBefore
+	if output.AllocBytes > 0 {
+		allocBytes = output.AllocBytes
+	}
+
+	result := BranchResult{
+		Name:          name,
+		Numeric:       output.Numeric,
+		Detail:        output.Detail,
+		Start:         start,
+		End:           end,
+		Duration:      end.Sub(start),
+		AllocPerPrime: output.AllocPerPrime,
+		AllocBytes:    allocBytes,
+		Hashes:        output.Hashes,
+		Retries:       output.Retries,
+	}
+	switch {
+	case output.CPUTimeSource != "":
+		result.CPUTime, result.CPUTimeSource = output.CPUTime, output.CPUTimeSource
+	case threadCPU && output.UsesWorkers:
+		result.CPUTime, result.CPUTimeSource = cpuAfter-cpuBefore, cpuTimeThreadOnly
+	case threadCPU:
+		result.CPUTime, result.CPUTimeSource = cpuAfter-cpuBefore, cpuTimeThread
+	default:
+		result.CPUTime, result.CPUTimeSource = result.Duration, cpuTimeWall
+	}
+
+	switch {
+	case errors.Is(err, ErrCancelled):
+		result.Cancelled = true
+		result.TimedOut = errors.Is(err, ErrBranchTimeout)
+	case err != nil:
+		result.Err = err
+	}
+
+	return result
+}
+
+// csvMetricsHeader devuelve las columnas del CSV de métricas, sin la columna config de -append.
+func csvMetricsHeader() []string {
+	return []string{
+		"mode",
+		"run",
+		"branch",
+		"was_winner",
+		"cancelled",
+		"result_numeric",
+		"result_detail",
+		"condition_value",
+		"condition_duration_ms",
+		"branch_start_ms",
+		"branch_end_ms",
+		"branch_duration_ms",
+		"branch_alloc_bytes",
+		"finish_order",
+		"total_duration_ms",
+		"effective_parallelism",
+		"alloc_per_prime",
+		"hashes_attempted",
+		"hash_rate",
+		"retries",
+		"cpu_time_ms",
+		"cpu_time_source",
+		"condition_fraction",
+		"shadow_numeric",
+		"shadow_detail",
+		"error",
+	}
+}
+
+// newCSVMetricsWriter crea el CSV de métricas antes de iniciar las corridas, para que las filas de
+// cada corrida queden en disco en cuanto termina: si el proceso falla a mitad del lote, el archivo
+// conserva las corridas completadas. Con -append comprueba en este momento que las columnas del
+// archivo existente coincidan.
+func newCSVMetricsWriter(cfg Config) (*csvMetricsWriter, error) {
+	if cfg.OutputFile != StdoutPath {
+		if err := os.MkdirAll(Directory(cfg.OutputFile), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	header := csvMetricsHeader()
+	out, err := newCSVOutput(cfg, header)
+	if err != nil {
+		return nil, err
+	}
+	return &csvMetricsWriter{cfg: cfg, header: header, out: out}, nil
+}
+
+// MetricsWriter escribe las métricas a medida que terminan las corridas y, al final, el resumen.
+type MetricsWriter interface {
+	WriteRun(run ExecutionRun) error
+	Finish(summary Summary) error
+	Close() error
+}
+
+// csvMetricsWriter escribe las filas del CSV de a una corrida y, al final, la fila de resumen.
+type csvMetricsWriter struct {
+	cfg    Config
+	header []string
+	out    *csvOutput
+	// limited indica que se alcanzó -max-output-bytes: las filas siguientes y el resumen se omiten.
+	limited bool
+}
+
+// WriteRun escribe las filas de las ramas de run, salvo que -sample-rows la excluya. Si las filas
+// ya no caben en -max-output-bytes devuelve ErrOutputLimit, con lo que el lote se detiene.
+func (w *csvMetricsWriter) WriteRun(run ExecutionRun) error {
+	if w.limited {
+		return ErrOutputLimit
+	}
+	if !sampledRun(w.cfg, run) {
+		return nil
+	}
+	records := make([][]string, 0, len(run.Branches))
+	for _, branch := range run.Branches {
+		startOffset := branch.Start.Sub(run.RunStart).Seconds() * 1000
+		endOffset := branch.End.Sub(run.RunStart).Seconds() * 1000
+		record := []string{
+			run.Mode,
+			strconv.Itoa(run.RunIndex),
+			branch.Name,
+			boolToString(branch.Name == run.Winner),
+			boolToString(branch.Cancelled),
+			strconv.FormatInt(branch.Numeric, 10),
+			csvSafeCell(w.cfg, branch.Detail),
+			strconv.FormatInt(run.ConditionValue, 10),
+			floatToString(run.ConditionDuration.Seconds() * 1000),
+			floatToString(startOffset),
+			floatToString(endOffset),
+			floatToString(branch.Duration.Seconds() * 1000),
+			strconv.FormatUint(branch.AllocBytes, 10),
+			strconv.Itoa(branch.FinishOrder),
+			floatToString(run.TotalDuration.Seconds() * 1000),
+			floatToString(effectiveParallelism(run)),
+			allocPerPrimeString(branch.AllocPerPrime),
+			hashesString(branch.Hashes),
+			hashRateString(branch),
+			strconv.Itoa(branch.Retries),
+			floatToString(Milliseconds(branch.CPUTime)),
+			branch.CPUTimeSource,
+			floatToString(run.ConditionFraction),
+			shadowNumericString(branch.Shadow),
+			csvSafeCell(w.cfg, shadowDetailString(branch.Shadow)),
+			csvSafeCell(w.cfg, branchErrorString(branch)),
+		}
+		records = append(records, record)
+	}
+	err := w.out.WriteRows(records)
+	if errors.Is(err, ErrOutputLimit) {
+		w.limited = true
+	}
+	if err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// Finish agrega la fila de resumen y cierra el archivo. Devuelve ErrOutputLimit si alguna fila se
+// omitió por -max-output-bytes.
+func (w *csvMetricsWriter) Finish(summary Summary) error {
+	defer w.out.Close()
+	if w.limited {
+		return ErrOutputLimit
+	}
+
+	cfg, header := w.cfg, w.header
+	baselineKey := "avg_sequential_ms"
+	if summary.BaselineIsReference {
+		baselineKey = "reference_ms"
+	}
+
+	summaryRow := make([]string, len(header))
+	summaryRow[columnIndex(header, "mode")] = "resumen"
+	summaryRow[columnIndex(header, "branch")] = "version=" + buildVersion()
+	summaryRow[columnIndex(header, "was_winner")] = WinsFields(summary.WinsSpeculative, "")
+	if !summary.BaselineIsReference {
+		summaryRow[columnIndex(header, "was_winner")] += ";" + WinsFields(summary.WinsSequential, "_sequential")
+	}
+	summaryRow[columnIndex(header, "condition_value")] = fmt.Sprintf("seed=%d", cfg.Seed)
+	summaryRow[columnIndex(header, "result_numeric")] = numericFields(summary.AvgNumericSpeculative, "")
+	summaryRow[columnIndex(header, "result_detail")] = numericFields(summary.AvgNumericSequential, "_sequential")
+	summaryRow[columnIndex(header, "total_duration_ms")] = fmt.Sprintf("avg_speculative_ms=%.3f;%s=%.3f;speedup=%s",
+		summary.AvgSpeculative.Seconds()*1000,
+		baselineKey,
+		summary.Baseline.Seconds()*1000,
+		FormatSpeedup(summary.Speedup))
+	summaryRow[columnIndex(header, "total_duration_ms")] += percentileFields("speculative", summary.PercentilesSpeculative)
+	summaryRow[columnIndex(header, "total_duration_ms")] += dispersionFields("speculative", summary.DispersionSpeculative)
+	if !summary.BaselineIsReference {
+		summaryRow[columnIndex(header, "total_duration_ms")] += percentileFields("sequential", summary.PercentilesSequential)
+		summaryRow[columnIndex(header, "total_duration_ms")] += dispersionFields("sequential", summary.DispersionSequential)
+	}
+	if summary.HasCorrelation {
+		summaryRow[columnIndex(header, "total_duration_ms")] += fmt.Sprintf(";duration_correlation=%.3f", summary.DurationCorrelation)
+	}
+	if summary.WinRatePairs > 0 {
+		summaryRow[columnIndex(header, "total_duration_ms")] += fmt.Sprintf(";spec_win_rate=%.3f", summary.SpecWinRate)
+	}
+	summaryRow[columnIndex(header, "total_duration_ms")] += fmt.Sprintf(";wasted_work_ms=%.3f;speculation_benefit_ms=%.3f",
+		Milliseconds(summary.WastedWork), Milliseconds(summary.SpeculationBenefit))
+	summaryRow[columnIndex(header, "effective_parallelism")] = fmt.Sprintf("avg_parallelism_speculative=%.3f", summary.AvgParallelism)
+	// La fila vacía que separa las corridas del resumen y el resumen se escriben juntas, para que el
+	// límite de -max-output-bytes no deje la separación sin el resumen.
+	if err := w.out.WriteRows([][]string{{}, summaryRow}); err != nil {
+		return err
+	}
+	return w.out.Close()
+}
+
+// Close cierra el archivo sin escribir el resumen; puede llamarse después de Finish.
+func (w *csvMetricsWriter) Close() error {
+	return w.out.Close()
+}
+
+// DecisionLog es el registro de decisiones de -decision-log: una línea
+// timestamp,run,winner,condition_value por corrida especulativa. Es independiente del formato de
+// -format y nunca trunca el archivo, para poder seguir la distribución de ganadoras a lo largo de
+// muchas invocaciones.
+type DecisionLog struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+// NewDecisionLog abre path para agregar líneas al final, creándolo (y su directorio) si no existe.
+func NewDecisionLog(path string) (*DecisionLog, error) {
+	if err := os.MkdirAll(Directory(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &DecisionLog{file: file, writer: csv.NewWriter(file)}, nil
+}
+
+// WriteRun agrega la línea de run y la vuelca de inmediato, para que una ejecución interrumpida
+// conserve las decisiones de las corridas que terminaron.
+func (l *DecisionLog) WriteRun(run ExecutionRun) error {
+	record := []string{
+		run.RunStart.Format(time.RFC3339Nano),
+		strconv.Itoa(run.RunIndex),
+		run.Winner,
+		strconv.FormatInt(run.ConditionValue, 10),
+	}
+	if err := l.writer.Write(record); err != nil {
+		return err
+	}
+	l.writer.Flush()
+	return l.writer.Error()
+}
+
+// Close cierra el archivo del registro.
+func (l *DecisionLog) Close() error {
+	return l.file.Close()
+}
+
+// AppendDecisionLog agrega de una vez al registro de decisiones de path una línea por cada una de
+// runs (ver DecisionLog).
+func AppendDecisionLog(path string, runs []ExecutionRun) error {
+	decisions, err := NewDecisionLog(path)
+	if err != nil {
+		return err
+	}
+	defer decisions.Close()
+	for _, run := range runs {
+		if err := decisions.WriteRun(run); err != nil {
+			return err
+		}
+	}
+	return decisions.Close()
+}
+
+// runHeader es el registro de reproducibilidad que encabeza cada archivo de métricas.
+type runHeader struct {
+	Tool    string `json:"tool"`
+	Version string `json:"version"`
+	Config  Config `json:"config"`
+}
+
+func newRunHeader(cfg Config) runHeader {
+	return runHeader{Tool: "tarea02", Version: Version, Config: cfg}
+}
+
+// PrintResolvedConfig escribe en w la configuración ya resuelta (con la semilla efectiva y los
+// valores por defecto) como JSON indentado; es la salida de -validate.
+func PrintResolvedConfig(w io.Writer, cfg Config) error {
+	encoded, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", encoded)
+	return err
+}
+
+// WriteSpeedupTrend escribe en cfg.TrendFile una fila runs_so_far,speedup cada cfg.SpeedupTrend
+// corridas, con el speedup calculado sobre las primeras runs_so_far corridas de cada estrategia;
+// la última fila corresponde siempre al total. Muestra cómo converge la estimación y ayuda a
+// decidir si la cantidad de corridas es suficiente.
+func WriteSpeedupTrend(cfg Config, specRuns, seqRuns []ExecutionRun) error {
+	available := len(specRuns)
+	if cfg.ReferenceMs <= 0 {
+		available = min(available, len(seqRuns))
+	}
+
+	if err := os.MkdirAll(Directory(cfg.TrendFile), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(cfg.TrendFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"runs_so_far", "speedup"}); err != nil {
+		return err
+	}
+	for k := cfg.SpeedupTrend; ; k += cfg.SpeedupTrend {
+		if k > available {
+			k = available
+		}
+		if k == 0 {
+			break
+		}
+		speedup := ComputeSpeedup(baselineDuration(cfg, seqRuns[:min(k, len(seqRuns))]), AverageDuration(specRuns[:k]))
+		if err := writer.Write([]string{strconv.Itoa(k), FormatSpeedup(speedup)}); err != nil {
+			return err
+		}
+		if k == available {
+			break
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// writeCSVHeaderComment escribe el registro de reproducibilidad como una línea de comentario
+// ("# {...}") antes del encabezado del CSV.
+func writeCSVHeaderComment(w io.Writer, cfg Config) error {
+	encoded, err := json.Marshal(newRunHeader(cfg))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "# %s\n", encoded)
+	return err
+}
+
+// sampledRun informa si las filas de run se escriben en el archivo de métricas: con -summary-only
+// ninguna y con -sample-rows N, una de cada N corridas.
+func sampledRun(cfg Config, run ExecutionRun) bool {
+	return !cfg.SummaryOnly && (run.RunIndex-1)%cfg.SampleRows == 0
+}
+
+// thresholdSelector reproduce la regla del enunciado: la rama A gana cuando la traza supera el
+// umbral y la rama B cuando queda por debajo. Un empate exacto se resuelve con tie; con TieA es la
+// comparación traza >= umbral del enunciado y con TieB, traza > umbral.
+func thresholdSelector(threshold int64, tie string) WinnerSelector {
+	return func(metrics ConditionMetrics) string {
+		if favorsA(metrics, threshold, tie) {
+			return branchA
+		}
+		return branchB
+	}
+}
+
+func selectWinner(cfg Config, metrics ConditionMetrics) string {
+	if cfg.Selector == nil {
+		return thresholdSelector(cfg.Threshold, cfg.Tie)(metrics)
+	}
+	return cfg.Selector(metrics)
+}
+
+func findBranch(branches []NamedBranch, name string) (BranchWork, bool) {
+	for _, branch := range branches {
+		if branch.Name == name {
+			return branch.Work, true
+		}
+	}
+	return nil, false
+}
+
+// SimularProofOfWork simula la búsqueda de un hash con prefijo de ceros, tal como se entrega en el
+// anexo.
+func SimularProofOfWork(blockData string, dificultad int) (string, int) {
+	hash, nonce, _ := SimularProofOfWorkWithCancel(nil, blockData, dificultad, 0)
+	return hash, nonce
+}
+
+// SimularProofOfWorkWithCancel es una variante que permite cancelación cooperativa. Si maxNonce es
+// mayor que cero y ningún nonce hasta maxNonce (inclusive) produce un hash válido, devuelve
+// ErrExhausted; con 0 la búsqueda no tiene límite.
+func SimularProofOfWorkWithCancel(cancel <-chan struct{}, blockData string, dificultad, maxNonce int) (string, int, error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return SimularProofOfWorkCtx(ctx, blockData, dificultad, maxNonce)
+}
+
+// SimularProofOfWorkCtx es la variante basada en context.Context; al terminar ctx devuelve
+// ErrCancelled envolviendo ctx.Err().
+func SimularProofOfWorkCtx(ctx context.Context, blockData string, dificultad, maxNonce int) (string, int, error) {
+	return SimularProofOfWorkHashCtx(ctx, nil, blockData, dificultad, maxNonce)
+}
+
+// SimularProofOfWorkConIntentos es SimularProofOfWorkWithCancel que además devuelve la cantidad de
+// nonces probados, incluso si la búsqueda se cancela o se agota: con éxito es el nonce ganador más
+// uno, y al cancelarse refleja el trabajo hecho hasta atender la cancelación.
+func SimularProofOfWorkConIntentos(cancel <-chan struct{}, blockData string, dificultad, maxNonce int) (string, int, int, error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return proofOfWorkSearch(ctx, nil, blockData, dificultad, maxNonce)
+}
+
+// SimularProofOfWorkHashCtx es el ciclo de búsqueda de SimularProofOfWorkCtx con la función de
+// hash inyectada (ver -pow-hash); con hash nil se usa SHA-256.
+func SimularProofOfWorkHashCtx(ctx context.Context, hash HashFunc, blockData string, dificultad, maxNonce int) (string, int, error) {
+	hashString, nonce, _, err := proofOfWorkSearch(ctx, hash, blockData, dificultad, maxNonce)
+	return hashString, nonce, err
+}
+
+// proofOfWorkSearch es SimularProofOfWorkHashCtx con la cantidad de nonces probados.
+func proofOfWorkSearch(ctx context.Context, hash HashFunc, blockData string, dificultad, maxNonce int) (string, int, int, error) {
+	if hash == nil {
+		hash = PowHashes[DefaultPowHash]
+	}
+	targetPrefix := strings.Repeat("0", dificultad)
+	nonce := 0
+	done := ctx.Done()
+
+	for {
+		if done != nil {
+			select {
+			case <-done:
+				return "", 0, nonce, cancelledError(ctx)
+			default:
+			}
+		}
+
+		data := fmt.Sprintf("%s%d", blockData, nonce)
+		hashString := hex.EncodeToString(hash([]byte(data)))
+
+		if strings.HasPrefix(hashString, targetPrefix) {
+			return hashString, nonce, nonce + 1, nil
+		}
+		if maxNonce > 0 && nonce >= maxNonce {
+			return "", nonce, nonce + 1, ErrExhausted
+		}
+		nonce++
+
+		if done != nil && nonce%1_000 == 0 {
+			select {
+			case <-done:
+				return "", 0, nonce, cancelledError(ctx)
+			default:
+			}
+		}
+	}
+}
+
+// VerificarProofOfWork recalcula SHA-256(blockData + nonce) y devuelve si tiene al menos dificultad
+// ceros hexadecimales iniciales, junto con el hash recalculado en hexadecimal. Permite auditar los
+// pares nonce/hash registrados en result_numeric y result_detail con la configuración por
+// defecto; para otros -pow-mode o -pow-hash, ver VerificarProofOfWorkHash.
+func VerificarProofOfWork(blockData string, nonce int, dificultad int) (bool, string) {
+	return VerificarProofOfWorkHash(blockData, nonce, dificultad, PowModeHex, DefaultPowHash)
+}
+
+// VerificarProofOfWorkHash es VerificarProofOfWork con la función hashName de -pow-hash y el
+// criterio mode de -pow-mode (hex, bits o target); es la comprobación que usa el subcomando
+// verify. Un hashName vacío usa DefaultPowHash y un mode vacío, hex. Con un hashName desconocido
+// devuelve false y un hash vacío.
+func VerificarProofOfWorkHash(blockData string, nonce int, dificultad int, mode, hashName string) (bool, string) {
+	if hashName == "" {
+		hashName = DefaultPowHash
+	}
+	hash := PowHashes[hashName]
+	if hash == nil {
+		return false, ""
+	}
+	sum := hash([]byte(fmt.Sprintf("%s%d", blockData, nonce)))
+	return powSatisfies(mode, hashName, dificultad, sum), hex.EncodeToString(sum)
+}
+
+// powSatisfies informa si el hash sum cumple difficulty según mode, con el mismo criterio que la
+// búsqueda de cada modo.
+func powSatisfies(mode, hashName string, difficulty int, sum []byte) bool {
+	switch mode {
+	case PowModeBits:
+		return leadingZeroBits(sum) >= difficulty
+	case PowModeTarget:
+		return new(big.Int).SetBytes(sum).Cmp(targetForBits(difficulty, powHashBits(hashName))) < 0
+	}
+	return strings.HasPrefix(hex.EncodeToString(sum), strings.Repeat("0", difficulty))
+}
+
+// SimularPoWBits busca un nonce cuyo hash tenga al menos bits bits en cero al inicio. A diferencia
+// de SimularProofOfWork, que compara caracteres hexadecimales (saltos de 4 bits, 16 veces más
+// trabajo por nivel), la dificultad avanza de a un bit y el trabajo esperado se duplica por nivel.
+// maxNonce se interpreta igual que en SimularProofOfWorkWithCancel.
+func SimularPoWBits(cancel <-chan struct{}, blockData string, bits, maxNonce int) (string, int, error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return SimularPoWBitsCtx(ctx, blockData, bits, maxNonce)
+}
+
+// SimularPoWBitsCtx es la variante de SimularPoWBits basada en context.Context.
+func SimularPoWBitsCtx(ctx context.Context, blockData string, bits, maxNonce int) (string, int, error) {
+	return SimularPoWBitsHashCtx(ctx, nil, blockData, bits, maxNonce)
+}
+
+// SimularPoWBitsHashCtx es el ciclo de SimularPoWBitsCtx con la función de hash inyectada; con
+// hash nil se usa SHA-256.
+func SimularPoWBitsHashCtx(ctx context.Context, hash HashFunc, blockData string, bits, maxNonce int) (string, int, error) {
+	hashString, nonce, _, err := powBitsSearch(ctx, hash, blockData, bits, maxNonce)
+	return hashString, nonce, err
+}
+
+// powBitsSearch es SimularPoWBitsHashCtx con la cantidad de nonces probados.
+func powBitsSearch(ctx context.Context, hash HashFunc, blockData string, bits, maxNonce int) (string, int, int, error) {
+	if hash == nil {
+		hash = PowHashes[DefaultPowHash]
+	}
+	nonce := 0
+	done := ctx.Done()
+
+	for {
+		if done != nil && nonce%1_000 == 0 {
+			select {
+			case <-done:
+				return "", 0, nonce, cancelledError(ctx)
+			default:
+			}
+		}
+
+		data := fmt.Sprintf("%s%d", blockData, nonce)
+		hashBytes := hash([]byte(data))
+		if leadingZeroBits(hashBytes) >= bits {
+			return hex.EncodeToString(hashBytes), nonce, nonce + 1, nil
+		}
+		if maxNonce > 0 && nonce >= maxNonce {
+			return "", nonce, nonce + 1, ErrExhausted
+		}
+		nonce++
+	}
+}
+
+// leadingZeroBits cuenta los bits en cero al inicio de b, leyendo cada byte desde su bit más
+// significativo.
+func leadingZeroBits(b []byte) int {
+	count := 0
+	for _, value := range b {
+		if value != 0 {
+			return count + bits.LeadingZeros8(value)
+		}
+		count += 8
+	}
+	return count
+}
+
+// EncontrarPrimos devuelve la lista de números primos hasta max, siguiendo el anexo.
+func EncontrarPrimos(max int) []int {
+	primes, _ := EncontrarPrimosWithCancel(nil, max)
+	return primes
+}
+
+// EncontrarPrimosWithCancel es una variante que añade soporte para cancelación cooperativa.
+func EncontrarPrimosWithCancel(cancel <-chan struct{}, max int) ([]int, error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return EncontrarPrimosCtx(ctx, max)
+}
+
+// EncontrarPrimosCtx es la variante basada en context.Context; al terminar ctx devuelve
+// ErrCancelled envolviendo ctx.Err().
+func EncontrarPrimosCtx(ctx context.Context, max int) ([]int, error) {
+	if max < 2 {
+		return []int{}, nil
+	}
+	return primesInRangeCtx(ctx, 2, max)
+}
+
+// EncontrarPrimosRango devuelve los primos del intervalo [lo, hi) por división sucesiva, para
+// estudiar una ventana alejada del origen (por ejemplo [1000000, 1001000)), donde los primos son
+// más dispersos y cada candidato cuesta más. Un lo menor que 2 se trata como 2.
+func EncontrarPrimosRango(cancel <-chan struct{}, lo, hi int) ([]int, error) {
+	if lo < 2 {
+		lo = 2
+	}
+	if hi <= lo {
+		return []int{}, nil
+	}
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return primesInRangeCtx(ctx, lo, hi)
+}
+
+// EncontrarPrimosEnRangoBits devuelve los primos de exactamente bits bits, es decir, los del
+// intervalo [2^(bits-1), 2^bits). Produce primos más grandes y dispersos que EncontrarPrimos.
+func EncontrarPrimosEnRangoBits(cancel <-chan struct{}, bits int) ([]int, error) {
+	if bits < 2 || bits > maxPrimesBits {
+		return nil, fmt.Errorf("bits fuera de rango: %d", bits)
+	}
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return primesInRangeCtx(ctx, 1<<(bits-1), 1<<bits)
+}
+
+// primesInRangeCtx aplica división sucesiva a cada candidato de [lo, hi), con lo >= 2.
+func primesInRangeCtx(ctx context.Context, lo, hi int) ([]int, error) {
+	primes := make([]int, 0, primeRangeEstimate(lo, hi))
+	err := visitPrimesInRange(ctx, lo, hi, func(p int) { primes = append(primes, p) })
+	if err != nil {
+		return nil, err
+	}
+	return primes, nil
+}
+
+// ContarPrimos devuelve la cantidad de primos menores que max y el último de ellos (0 si no hay)
+// con la misma división sucesiva que EncontrarPrimosWithCancel, pero sin guardar la lista, que es
+// todo lo que informa la rama B.
+func ContarPrimos(cancel <-chan struct{}, max int) (count int, last int, err error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return contarPrimosCtx(ctx, max)
+}
+
+func contarPrimosCtx(ctx context.Context, max int) (count int, last int, err error) {
+	return contarPrimosRangoCtx(ctx, 2, max)
+}
+
+// contarPrimosRangoCtx es contarPrimosCtx restringido a [lo, max); un lo menor que 2 se trata
+// como 2.
+func contarPrimosRangoCtx(ctx context.Context, lo, max int) (count int, last int, err error) {
+	if lo < 2 {
+		lo = 2
+	}
+	if max <= lo {
+		return 0, 0, nil
+	}
+	err = visitPrimesInRange(ctx, lo, max, func(p int) {
+		count++
+		last = p
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, last, nil
+}
+
+// visitPrimesInRange invoca visit, en orden, con cada primo de [lo, hi) hallado por división
+// sucesiva, con lo >= 2. La cancelación se revisa cada primeCheckEvery(hi) candidatos y, dentro de
+// un candidato, cada 1024 divisores.
+func visitPrimesInRange(ctx context.Context, lo, hi int, visit func(p int)) error {
+	done := ctx.Done()
+	every := primeCheckEvery(hi)
+	for i := lo; i < hi; i++ {
+		if done != nil && (i-lo)%every == 0 {
+			select {
+			case <-done:
+				return cancelledError(ctx)
+			default:
+			}
+		}
+
+		isPrime := true
+		upper := int(math.Sqrt(float64(i)))
+		for j := 2; j <= upper; j++ {
+			if done != nil && j%1024 == 0 {
+				select {
+				case <-done:
+					return cancelledError(ctx)
+				default:
+				}
+			}
+			if i%j == 0 {
+				isPrime = false
+				break
+			}
+		}
+		if isPrime {
+			visit(i)
+		}
+	}
+	return nil
+}
+
+const (
+	// smallPrimesCapacity es la capacidad inicial de la lista de primos con límites menores que 3,
+	// donde la aproximación de primeCountEstimate no está definida.
+	smallPrimesCapacity = 4
+	// primeCountBound es la constante de la cota de Rosser y Schoenfeld π(x) < 1.25506·x/ln(x),
+	// válida para todo x > 1.
+	primeCountBound = 1.25506
+)
+
+// primeCountEstimate acota π(x), la cantidad de primos menores que x, para reservar de una vez la
+// lista de primos. x/ln(x) sola se queda corta (un 8 % para x = 10⁶) y obliga a hacer crecer la
+// lista al final; con la cota la lista nunca crece y la reserva excedente es menor que la de una
+// capacidad fija x/10 (que además se queda corta con límites menores que unos 60000).
+func primeCountEstimate(x int) int {
+	if x < 3 {
+		return smallPrimesCapacity
+	}
+	return int(primeCountBound*float64(x)/math.Log(float64(x))) + 1
+}
+
+// primeRangeEstimate estima la cantidad de primos de [lo, hi). Desde lo = 17 a la cota de π(hi)
+// le resta la cota inferior π(lo) > lo/ln(lo); como esa diferencia es muy holgada en ventanas
+// angostas (como [1000000, 1001000)), se queda además con el ancho de la ventana por la densidad
+// 1/ln(lo) de su extremo inferior, afectada por la misma constante. Un rango vacío no tiene primos.
+func primeRangeEstimate(lo, hi int) int {
+	if hi <= lo {
+		return 0
+	}
+	estimate := primeCountEstimate(hi)
+	if lo >= 17 {
+		logLo := math.Log(float64(lo))
+		estimate -= int(float64(lo) / logLo)
+		estimate = min(estimate, int(primeCountBound*float64(hi-lo)/logLo)+1)
+	}
+	return estimate
+}
+
+// primeCheckDivisions es la cantidad aproximada de divisiones que la división sucesiva hace entre
+// dos revisiones de cancelación.
+const primeCheckDivisions = 1 << 16
+
+// primeCheckEvery devuelve cada cuántos candidatos se revisa la cancelación al buscar primos
+// menores que hi. Un candidato cuesta hasta √hi divisiones, así que el intervalo se reduce a
+// medida que crece hi para que la cancelación siga siendo rápida, y con límites pequeños se
+// amplía (hasta 4096) para no pagar una revisión por candidato.
+func primeCheckEvery(hi int) int {
+	every := primeCheckDivisions / max(int(math.Sqrt(float64(hi))), 1)
+	return min(max(every, 1), 4096)
+}
+
+// EncontrarPrimosSieve devuelve los mismos primos que EncontrarPrimos usando la criba de
+// Eratóstenes, en O(n log log n) en lugar de O(n·√n). Revisa la cancelación cada
+// sieveCancelEvery iteraciones.
+func EncontrarPrimosSieve(cancel <-chan struct{}, max int) ([]int, error) {
+	ctx, release := contextFromCancel(cancel)
+	defer release()
+	return EncontrarPrimosSieveCtx(ctx, max)
+}
+
+// EncontrarPrimosSieveCtx es la variante basada en context.Context; al terminar ctx devuelve
+// ErrCancelled envolviendo ctx.Err().
+func EncontrarPrimosSieveCtx(ctx context.Context, max int) ([]int, error) {
+	if max < 2 {
+		return []int{}, nil
+	}
+
+	done := ctx.Done()
+	checks := 0
+	cancelled := func() bool {
+		checks++
+		if done == nil || checks%sieveCancelEvery != 0 {
+			return false
+		}
+		return isClosed(done)
+	}
+
+	composite := make([]bool, max)
+	for i := 2; i*i < max; i++ {
+		if composite[i] {
+			continue
+		}
+		for j := i * i; j < max; j += i {
+			if cancelled() {
+				return nil, cancelledError(ctx)
+			}
+			composite[j] = true
+		}
+	}
+
+	primes := make([]int, 0, primeCountEstimate(max))
+	for i := 2; i < max; i++ {
+		if cancelled() {
+			return nil, cancelledError(ctx)
+		}
+		if !composite[i] {
+			primes = append(primes, i)
+		}
+	}
+	return primes, nil
+}
+
+// contextFromCancel adapta un canal de cancelación a un contexto que termina cuando el canal se
+// cierra; si ya está cerrado, el contexto nace terminado. Un canal nil produce un contexto que
+// solo termina al invocar la función devuelta.
+func contextFromCancel(cancel <-chan struct{}) (context.Context, context.CancelFunc) {
+	if cancel == nil {
+		return context.Background(), func() {}
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	if isClosed(cancel) {
+		stop()
+		return ctx, stop
+	}
+	go func() {
+		select {
+		case <-cancel:
+			stop()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, stop
+}
+
+func cancelledError(ctx context.Context) error {
+	return fmt.Errorf("%w: %w", ErrCancelled, ctx.Err())
+}
+
+// CalcularTrazaDeProductoDeMatrices multiplica dos matrices NxN con valores aleatorios y devuelve
+// la traza. La suma se acumula en int64 para no desbordar en plataformas de 32 bits o con n muy
+// grande.
+func CalcularTrazaDeProductoDeMatrices(n int) int64 {
+	return CalcularTrazaConRNG(n, nil)
+}
+
+// CalcularTrazaConRNG es la variante que genera las matrices con rng, de modo que una misma
+// semilla reproduce la misma traza. Con rng nil se usa la fuente global de math/rand.
+//
+// Los tamaños degenerados no asignan matrices: con n <= 0 la traza de la matriz vacía es 0, y con
+// n = 1 es a·b, el producto de los dos únicos elementos, sorteados en el mismo orden que
+// randomMatrices para que la semilla dé el mismo valor. Con n = 2 ya se sigue el camino general:
+// a11·b11 + a12·b21 + a21·b12 + a22·b22.
+func CalcularTrazaConRNG(n int, rng *rand.Rand) int64 {
+	switch {
+	case n <= 0:
+		return 0
+	case n == 1:
+		intn := rand.Intn
+		if rng != nil {
+			intn = rng.Intn
+		}
+		a := intn(DefaultMatrixMax)
+		return int64(a) * int64(intn(DefaultMatrixMax))
+	}
+	m1, m2 := randomMatrices(n, DefaultMatrixMax, rng)
+	return productTrace(m1, m2)
+}
+
+// DefaultMatrixMax es la cota de los elementos del anexo, rand.Intn(10): valores entre 0 y 9.
+const DefaultMatrixMax = 10
+
+// randomMatrices genera las dos matrices NxN de la condición, con elementos entre 0 y maxValue-1,
+// en el mismo orden que el anexo (intercalando m1 y m2). Como traza = Σ m1[i][k]·m2[k][i], su valor
+// esperado es n²·((maxValue-1)/2)², así que crece con el cuadrado de maxValue. Con rng nil se usa
+// la fuente global.
+func randomMatrices(n, maxValue int, rng *rand.Rand) ([][]int, [][]int) {
+	intn := rand.Intn
+	if rng != nil {
+		intn = rng.Intn
+	}
+
+	m1 := make([][]int, n)
+	m2 := make([][]int, n)
+	for i := 0; i < n; i++ {
+		m1[i] = make([]int, n)
+		m2[i] = make([]int, n)
+		for j := 0; j < n; j++ {
+			m1[i][j] = intn(maxValue)
+			m2[i][j] = intn(maxValue)
+		}
+	}
+	return m1, m2
+}
+
+// productTrace devuelve la traza de m1·m2 sin calcular el producto completo.
+func productTrace(m1, m2 [][]int) int64 {
+	n := len(m1)
+	var trace int64
+	for i := 0; i < n; i++ {
+		var sum int64
+		for k := 0; k < n; k++ {
+			sum += int64(m1[i][k]) * int64(m2[k][i])
+		}
+		trace += sum
+	}
+	return trace
+}
+
+// TrazaStreaming calcula la traza de un producto de matrices aleatorias sin materializarlas:
+// como cada elemento de m1 y de m2 interviene exactamente una vez en traza = Σ m1[i][k]·m2[k][i],
+// basta con generar cada par (m1[i][k], m2[k][i]) al vuelo, usando memoria O(1). La distribución
+// del resultado es la misma que la de CalcularTrazaDeProductoDeMatrices, aunque para una misma
+// semilla los valores difieren porque los elementos se generan en otro orden. Con rng nil se usa
+// la fuente global de math/rand.
+func TrazaStreaming(n int, rng *rand.Rand) int64 {
+	intn := rand.Intn
+	if rng != nil {
+		intn = rng.Intn
+	}
+
+	var trace int64
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			trace += int64(intn(DefaultMatrixMax)) * int64(intn(DefaultMatrixMax))
+		}
+	}
+	return trace
+}
+
+// CalcularTrazaPlana produce la misma traza que CalcularTrazaConRNG para un mismo rng, pero guarda
+// cada matriz en un único []int64 de n·n elementos (el elemento [i][j] en i·n+j) en lugar de un
+// slice por fila, y m2 ya traspuesta: así el producto escalar de la fila i de m1 con la columna i
+// de m2 recorre ambos operandos por filas, en memoria contigua. Con rng nil se usa la fuente
+// global de math/rand.
+func CalcularTrazaPlana(n int, rng *rand.Rand) int64 {
+	m1, m2T := randomFlatMatrices(n, DefaultMatrixMax, rng)
+	return flatProductTrace(m1, m2T, n)
+}
+
+// randomFlatMatrices genera los mismos elementos, en el mismo orden, que randomMatrices, y
+// devuelve m1 y la traspuesta de m2 en formato plano.
+func randomFlatMatrices(n, maxValue int, rng *rand.Rand) ([]int64, []int64) {
+	intn := rand.Intn
+	if rng != nil {
+		intn = rng.Intn
+	}
+
+	m1 := make([]int64, n*n)
+	m2T := make([]int64, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			m1[i*n+j] = int64(intn(maxValue))
+			m2T[j*n+i] = int64(intn(maxValue))
+		}
+	}
+	return m1, m2T
+}
+
+// flatProductTrace devuelve la traza de m1·m2 a partir de m1 y de la traspuesta de m2, ambas
+// planas de n·n: traza = Σ m1[i][k]·m2ᵀ[i][k].
+func flatProductTrace(m1, m2T []int64, n int) int64 {
+	var trace int64
+	for i := 0; i < n; i++ {
+		row1 := m1[i*n : (i+1)*n]
+		row2 := m2T[i*n : (i+1)*n]
+		for k, value := range row1 {
+			trace += value * row2[k]
+		}
+	}
+	return trace
+}
+
+// TrazaAAt genera una única matriz A de n×n con los valores del anexo y devuelve la traza de A·Aᵀ,
+// que es la suma de los cuadrados de todos sus elementos: tr(A·Aᵀ) = Σᵢ Σₖ A[i][k]². Cuesta O(n²)
+// sin multiplicar dos matrices distintas. Con rng nil se usa la fuente global de math/rand.
+func TrazaAAt(n int, rng *rand.Rand) int64 {
+	return squareSum(randomMatrix(n, DefaultMatrixMax, rng))
+}
+
+// randomMatrix genera una matriz n×n con elementos entre 0 y maxValue-1, fila por fila. Con rng
+// nil se usa la fuente global.
+func randomMatrix(n, maxValue int, rng *rand.Rand) [][]int {
+	intn := rand.Intn
+	if rng != nil {
+		intn = rng.Intn
+	}
+
+	m := make([][]int, n)
+	for i := range m {
+		m[i] = make([]int, n)
+		for j := range m[i] {
+			m[i][j] = intn(maxValue)
+		}
+	}
+	return m
+}
+
+// squareSum devuelve la suma de los cuadrados de los elementos de m, la traza de m·mᵀ. Con
+// elementos uniformes entre 0 y maxValue-1 su valor esperado es n²·(maxValue-1)·(2·maxValue-1)/6.
+func squareSum(m [][]int) int64 {
+	var sum int64
+	for _, row := range m {
+		for _, value := range row {
+			sum += int64(value) * int64(value)
+		}
+	}
+	return sum
+}
+
+// runRNG devuelve el generador de la corrida runIndex. Depende solo de la semilla y del índice,
+// por lo que la corrida i de cada estrategia evalúa las mismas matrices y puede reproducirse
+// por separado.
+func runRNG(seed int64, runIndex int) *rand.Rand {
+	return rand.New(rand.NewSource(seed + int64(runIndex)))
+}
+
+func buildSummary(cfg Config, specRuns, seqRuns []ExecutionRun) Summary {
+	avgSpec := AverageDuration(specRuns)
+	baseline := baselineDuration(cfg, seqRuns)
+	correlation, pairs, hasCorrelation := pairedDurationCorrelation(specRuns, seqRuns)
+	winRate, winRatePairs := pairedWinRate(specRuns, seqRuns)
+	return Summary{
+		AvgSpeculative:         avgSpec,
+		Baseline:               baseline,
+		BaselineIsReference:    cfg.ReferenceMs > 0,
+		Speedup:                ComputeSpeedup(baseline, avgSpec),
+		AvgNumericSpeculative:  averageNumericByBranch(specRuns),
+		AvgNumericSequential:   averageNumericByBranch(seqRuns),
+		AvgParallelism:         averageParallelism(specRuns),
+		PercentilesSpeculative: percentileDurations(specRuns),
+		PercentilesSequential:  percentileDurations(seqRuns),
+		DispersionSpeculative:  durationDispersion(specRuns),
+		DispersionSequential:   durationDispersion(seqRuns),
+		DurationCorrelation:    correlation,
+		HasCorrelation:         hasCorrelation,
+		CorrelationPairs:       pairs,
+		SpecWinRate:            winRate,
+		WinRatePairs:           winRatePairs,
+		WastedWork:             wastedWork(specRuns),
+		SpeculationBenefit:     speculationBenefit(baseline, avgSpec, len(specRuns)),
+		WinsSpeculative:        winnerCounts(specRuns),
+		WinsSequential:         winnerCounts(seqRuns),
+	}
+}
+
+// pairedDurationCorrelation calcula la correlación de Pearson entre las duraciones totales de las
+// corridas de ambas estrategias con el mismo número de corrida. Un valor alto indica ruido del
+// entorno compartido por cada par y justifica analizar el speedup con diferencias pareadas. ok es
+// falso con menos de dos pares o si alguna de las series no varía.
+func pairedDurationCorrelation(specRuns, seqRuns []ExecutionRun) (r float64, pairs int, ok bool) {
+	seqByRun := make(map[int]time.Duration, len(seqRuns))
+	for _, run := range seqRuns {
+		seqByRun[run.RunIndex] = run.TotalDuration
+	}
+	var xs, ys []float64
+	for _, run := range specRuns {
+		if seq, found := seqByRun[run.RunIndex]; found {
+			xs = append(xs, Milliseconds(run.TotalDuration))
+			ys = append(ys, Milliseconds(seq))
+		}
+	}
+	if len(xs) < 2 {
+		return 0, len(xs), false
+	}
+
+	n := float64(len(xs))
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, len(xs), false
+	}
+	return sxy / math.Sqrt(sxx*syy), len(xs), true
+}
+
+// pairedWinRate devuelve la fracción de las corridas con el mismo número en ambas estrategias en
+// que la duración total especulativa fue menor que la secuencial, junto a la cantidad de pares
+// (rate es 0 si no hay ninguno). A diferencia del speedup, que compara promedios, cuenta cuántas
+// veces convino especular; tiene sentido porque cada par evalúa la misma condición con la misma
+// configuración, y es reproducible si se fija -seed.
+func pairedWinRate(specRuns, seqRuns []ExecutionRun) (rate float64, pairs int) {
+	seqByRun := make(map[int]time.Duration, len(seqRuns))
+	for _, run := range seqRuns {
+		seqByRun[run.RunIndex] = run.TotalDuration
+	}
+	wins := 0
+	for _, run := range specRuns {
+		seq, found := seqByRun[run.RunIndex]
+		if !found {
+			continue
+		}
+		pairs++
+		if run.TotalDuration < seq {
+			wins++
+		}
+	}
+	if pairs == 0 {
+		return 0, 0
+	}
+	return float64(wins) / float64(pairs), pairs
+}
+
+func durationDispersion(runs []ExecutionRun) DurationDispersion {
+	return DurationDispersion{
+		Stddev: stddevDuration(runs),
+		Min:    minDuration(runs),
+		Max:    maxDuration(runs),
+	}
+}
+
+// stddevDuration devuelve la desviación estándar muestral (con n-1) de las duraciones totales; es
+// 0 con menos de dos corridas.
+func stddevDuration(runs []ExecutionRun) time.Duration {
+	if len(runs) < 2 {
+		return 0
+	}
+	mean := float64(AverageDuration(runs))
+	var squares float64
+	for _, run := range runs {
+		diff := float64(run.TotalDuration) - mean
+		squares += diff * diff
+	}
+	return time.Duration(math.Round(math.Sqrt(squares / float64(len(runs)-1))))
+}
+
+// minDuration devuelve la menor duración total, o 0 si no hay corridas.
+func minDuration(runs []ExecutionRun) time.Duration {
+	if len(runs) == 0 {
+		return 0
+	}
+	shortest := runs[0].TotalDuration
+	for _, run := range runs[1:] {
+		shortest = min(shortest, run.TotalDuration)
+	}
+	return shortest
+}
+
+// maxDuration devuelve la mayor duración total, o 0 si no hay corridas.
+func maxDuration(runs []ExecutionRun) time.Duration {
+	if len(runs) == 0 {
+		return 0
+	}
+	longest := runs[0].TotalDuration
+	for _, run := range runs[1:] {
+		longest = max(longest, run.TotalDuration)
+	}
+	return longest
+}
+
+func percentileDurations(runs []ExecutionRun) []time.Duration {
+	out := make([]time.Duration, len(reportedPercentiles))
+	for i, p := range reportedPercentiles {
+		out[i] = percentileDuration(runs, p)
+	}
+	return out
+}
+
+// percentileDuration devuelve el percentil p (0-100) de las duraciones totales, interpolando
+// linealmente entre las dos corridas más cercanas al rango p/100·(n-1). Ordena una copia, de modo
+// que runs conserva su orden.
+func percentileDuration(runs []ExecutionRun, p float64) time.Duration {
+	if len(runs) == 0 {
+		return 0
+	}
+	durations := make([]time.Duration, len(runs))
+	for i, run := range runs {
+		durations[i] = run.TotalDuration
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	rank := p / 100 * float64(len(durations)-1)
+	lower := int(math.Floor(rank))
+	if lower >= len(durations)-1 {
+		return durations[len(durations)-1]
+	}
+	fraction := rank - float64(lower)
+	return durations[lower] + time.Duration(math.Round(fraction*float64(durations[lower+1]-durations[lower])))
+}
+
+func AverageDuration(runs []ExecutionRun) time.Duration {
+	if len(runs) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, run := range runs {
+		total += run.TotalDuration
+	}
+	return total / time.Duration(len(runs))
+}
+
+// DurationTrend ajusta por mínimos cuadrados la recta duración = a + pendiente·corrida y devuelve
+// la pendiente en ms por corrida junto con su estadístico t. ok es falso con menos de tres corridas.
+func DurationTrend(runs []ExecutionRun) (slope, t float64, ok bool) {
+	n := float64(len(runs))
+	if len(runs) < 3 {
+		return 0, 0, false
+	}
+	var meanX, meanY float64
+	for i, run := range runs {
+		meanX += float64(i)
+		meanY += Milliseconds(run.TotalDuration)
+	}
+	meanX /= n
+	meanY /= n
+
+	var sxx, sxy float64
+	for i, run := range runs {
+		dx := float64(i) - meanX
+		sxx += dx * dx
+		sxy += dx * (Milliseconds(run.TotalDuration) - meanY)
+	}
+	slope = sxy / sxx
+	intercept := meanY - slope*meanX
+
+	var residuals float64
+	for i, run := range runs {
+		r := Milliseconds(run.TotalDuration) - (intercept + slope*float64(i))
+		residuals += r * r
+	}
+	stdErr := math.Sqrt(residuals / (n - 2) / sxx)
+	if stdErr == 0 {
+		return slope, math.Inf(int(math.Copysign(1, slope))), true
+	}
+	return slope, slope / stdErr, true
+}
+
+// averageNumericByBranch promedia el result_numeric de cada rama de runs por separado. Las ramas
+// canceladas no cuentan, porque su valor es 0 o parcial; una rama sin resultados terminados no
+// aparece en el mapa.
+func averageNumericByBranch(runs []ExecutionRun) map[string]float64 {
+	totals := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, run := range runs {
+		for _, branch := range run.Branches {
+			if branch.Cancelled {
+				continue
+			}
+			totals[branch.Name] += float64(branch.Numeric)
+			counts[branch.Name]++
+		}
+	}
+	averages := make(map[string]float64, len(totals))
+	for name, total := range totals {
+		averages[name] = total / float64(counts[name])
+	}
+	return averages
+}
+
+// effectiveParallelism divide el tiempo de CPU acumulado de las ramas (BranchResult.CPUTime) por el
+// intervalo de reloj que abarcan (desde el primer inicio hasta el último fin). Un valor cercano al
+// número de ramas indica que se ejecutaron de verdad en paralelo; cercano a 1, que compartieron un
+// único núcleo. Donde cpu_time_source es wall el tiempo de CPU es la duración de reloj de la rama,
+// así que el valor solo mide cuánto se solaparon; donde es thread-only falta el tiempo de las
+// goroutines auxiliares de la rama, así que el valor queda por debajo del real.
+func effectiveParallelism(run ExecutionRun) float64 {
+	if len(run.Branches) == 0 {
+		return 0
+	}
+	first := run.Branches[0].Start
+	last := run.Branches[0].End
+	var busy time.Duration
+	for _, branch := range run.Branches {
+		if branch.Start.Before(first) {
+			first = branch.Start
+		}
+		if branch.End.After(last) {
+			last = branch.End
+		}
+		busy += branch.CPUTime
+	}
+	span := last.Sub(first)
+	if span <= 0 {
+		return 0
+	}
+	return busy.Seconds() / span.Seconds()
+}
+
+func averageParallelism(runs []ExecutionRun) float64 {
+	if len(runs) == 0 {
+		return 0
+	}
+	var total float64
+	for _, run := range runs {
+		total += effectiveParallelism(run)
+	}
+	return total / float64(len(runs))
+}
+
+// TimedOutWinners cuenta las corridas de runs cuya rama ganadora se detuvo por -branch-timeout.
+func TimedOutWinners(runs []ExecutionRun) int {
+	count := 0
+	for _, run := range runs {
+		for _, branch := range run.Branches {
+			if branch.Name == run.Winner && branch.TimedOut {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// winnerCounts cuenta cuántas corridas de runs ganó cada rama, para comprobar que el umbral
+// reparte las corridas entre ambas.
+func winnerCounts(runs []ExecutionRun) map[string]int {
+	counts := make(map[string]int)
+	for _, run := range runs {
+		counts[run.Winner]++
+	}
+	return counts
+}
+
+// WinsFields formatea counts como "wins_a<suffix>=N;wins_b<suffix>=M;...", siempre con A y B
+// (aunque no hayan ganado) y el resto de las ramas en orden alfabético.
+func WinsFields(counts map[string]int, suffix string) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	fields := make([]string, 0, len(names)+2)
+	for _, name := range summaryBranchOrder(names, true) {
+		fields = append(fields, fmt.Sprintf("wins_%s%s=%d", strings.ToLower(name), suffix, counts[name]))
+	}
+	return strings.Join(fields, ";")
+}
+
+// numericFields formatea los promedios de averageNumericByBranch como
+// "avg_numeric_A<suffix>=...;avg_numeric_B<suffix>=...".
+func numericFields(averages map[string]float64, suffix string) string {
+	names := make([]string, 0, len(averages))
+	for name := range averages {
+		names = append(names, name)
+	}
+	fields := make([]string, 0, len(names))
+	for _, name := range summaryBranchOrder(names, false) {
+		fields = append(fields, fmt.Sprintf("avg_numeric_%s%s=%.3f", name, suffix, averages[name]))
+	}
+	return strings.Join(fields, ";")
+}
+
+// summaryBranchOrder ordena names para la fila de resumen: A y B primero y las demás ramas en orden
+// alfabético. Con withDefaults, A y B aparecen aunque no estén en names.
+func summaryBranchOrder(names []string, withDefaults bool) []string {
+	var ordered, others []string
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+		if name != branchA && name != branchB {
+			others = append(others, name)
+		}
+	}
+	for _, name := range []string{branchA, branchB} {
+		if withDefaults || present[name] {
+			ordered = append(ordered, name)
+		}
+	}
+	sort.Strings(others)
+	return append(ordered, others...)
+}
+
+// wastedWork suma la duración de las ramas canceladas de runs: el cómputo que la estrategia
+// especulativa lanzó y luego descartó.
+func wastedWork(runs []ExecutionRun) time.Duration {
+	var total time.Duration
+	for _, run := range runs {
+		for _, branch := range run.Branches {
+			if branch.Cancelled {
+				total += branch.Duration
+			}
+		}
+	}
+	return total
+}
+
+// speculationBenefit es el tiempo total que ahorró la estrategia especulativa respecto de la
+// línea base: (línea base - promedio especulativo) por la cantidad de corridas especulativas.
+// Es negativo cuando la especulación fue más lenta.
+func speculationBenefit(baseline, avgSpeculative time.Duration, runs int) time.Duration {
+	return (baseline - avgSpeculative) * time.Duration(runs)
+}
+
+// baselineDuration devuelve la línea base del speedup: la referencia externa de -reference-ms
+// cuando está definida o, en su defecto, el promedio de las corridas secuenciales.
+func baselineDuration(cfg Config, seqRuns []ExecutionRun) time.Duration {
+	if cfg.ReferenceMs > 0 {
+		return time.Duration(cfg.ReferenceMs * float64(time.Millisecond))
+	}
+	return AverageDuration(seqRuns)
+}
+
+// ComputeSpeedup divide la línea base por el promedio especulativo. Si alguna de las dos duraciones
+// no es positiva (no hubo corridas, o fueron tan rápidas que el reloj no las distinguió) el speedup
+// no está definido y se devuelve NaN, que FormatSpeedup muestra como "n/a" para no confundirlo con
+// un speedup real de 0.
+func ComputeSpeedup(sequential, speculative time.Duration) float64 {
+	if speculative <= 0 || sequential <= 0 {
+		return math.NaN()
+	}
+	return sequential.Seconds() / speculative.Seconds()
+}
+
+// FormatSpeedup muestra speedup con tres decimales, o "n/a" si no está definido.
+func FormatSpeedup(speedup float64) string {
+	if math.IsNaN(speedup) {
+		return "n/a"
+	}
+	return floatToString(speedup)
+}
+
+func Milliseconds(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
+
+// geometricMeanSpeedup agrega los speedups de varias configuraciones con la media geométrica,
+// que es la tendencia central adecuada para razones. Devuelve NaN (no definida, como en
+// ComputeSpeedup) si la lista está vacía o si algún valor no es positivo y finito.
+func geometricMeanSpeedup(speedups []float64) float64 {
+	if len(speedups) == 0 {
+		return math.NaN()
+	}
+	var logSum float64
+	for _, speedup := range speedups {
+		if !(speedup > 0) || math.IsInf(speedup, 0) {
+			return math.NaN()
+		}
+		logSum += math.Log(speedup)
+	}
+	return math.Exp(logSum / float64(len(speedups)))
+}
+
+// arithmeticMeanSpeedup es la media aritmética de speedups, que se informa junto a la geométrica
+// para comparar; NaN si la lista está vacía.
+func arithmeticMeanSpeedup(speedups []float64) float64 {
+	if len(speedups) == 0 {
+		return math.NaN()
+	}
+	var total float64
+	for _, speedup := range speedups {
+		total += speedup
+	}
+	return total / float64(len(speedups))
+}
+
+// reportSpeedupMeans agrega al final del archivo de un barrido la línea de comentario
+// "# geometric_mean_speedup=...;arithmetic_mean_speedup=...;configurations=N" y muestra las mismas
+// medias por consola. Solo promedia los speedups definidos; los de configuraciones sin línea base
+// (NaN) se omiten y no cuentan en N.
+func reportSpeedupMeans(file io.Writer, stdout io.Writer, speedups []float64) error {
+	defined := make([]float64, 0, len(speedups))
+	for _, speedup := range speedups {
+		if !math.IsNaN(speedup) {
+			defined = append(defined, speedup)
+		}
+	}
+	geometric, arithmetic := geometricMeanSpeedup(defined), arithmeticMeanSpeedup(defined)
+	fmt.Fprintf(stdout, "Media de los speedups: geométrica %s, aritmética %s (%d configuraciones)\n",
+		FormatSpeedup(geometric), FormatSpeedup(arithmetic), len(defined))
+	_, err := fmt.Fprintf(file, "# geometric_mean_speedup=%s;arithmetic_mean_speedup=%s;configurations=%d\n",
+		FormatSpeedup(geometric), FormatSpeedup(arithmetic), len(defined))
+	return err
+}
+
+// ConsoleOutput es el destino del resumen por consola: stdout, stderr cuando las métricas se
+// escriben en stdout (nombre_archivo -) o, con -quiet, ninguno. Los errores y advertencias van
+// siempre a stderr.
+func ConsoleOutput(cfg Config) io.Writer {
+	switch {
+	case cfg.Quiet:
+		return io.Discard
+	case writesToStdout(cfg) && !cfg.Sweep && cfg.DifficultySweep == "":
+		// La salida estándar lleva las métricas; el resumen pasa a stderr para no mezclarse.
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// PrintRunDetail escribe en w una línea con el resultado de run, para -verbose.
+func PrintRunDetail(w io.Writer, run ExecutionRun) {
+	branches := make([]string, 0, len(run.Branches))
+	for _, branch := range run.Branches {
+		state := ""
+		switch {
+		case branch.Cancelled:
+			state = " cancelada"
+		case branch.Err != nil:
+			state = " con error"
+		}
+		branches = append(branches, fmt.Sprintf("%s %s%s", branch.Name, FormatDuration(branch.Duration), state))
+	}
+	fmt.Fprintf(w, "%s %d: ganadora=%s condición=%d (%s) total=%s ramas: %s\n",
+		run.Mode, run.RunIndex, run.Winner, run.ConditionValue, FormatDuration(run.ConditionDuration),
+		FormatDuration(run.TotalDuration), strings.Join(branches, ", "))
+}
+
+func FormatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f ms", d.Seconds()*1000)
+}
+
+// Directory devuelve el directorio que contiene path ("." para un nombre sin directorio). Usa
+// filepath.Dir, que en Windows reconoce tanto "\" como "/".
+func Directory(path string) string {
+	return filepath.Dir(filepath.Clean(path))
+}
+
+func boolToString(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
+
+func floatToString(value float64) string {
+	return fmt.Sprintf("%.3f", value)
+}
+
+func columnIndex(header []string, name string) int {
+	for i, column := range header {
+		if column == name {
+			return i
+		}
+	}
+	panic(fmt.Sprintf("columna %q inexistente", name))
+}
+
+// allocPerPrimeString deja la celda vacía cuando la rama no midió sus asignaciones.
+func allocPerPrimeString(value float64) string {
+	if value == 0 {
+		return ""
+	}
+	return floatToString(value)
+}
+
+func hashesString(hashes int64) string {
+	if hashes == 0 {
+		return ""
+	}
+	return strconv.FormatInt(hashes, 10)
+}
+
+func hashRateString(branch BranchResult) string {
+	rate := hashRate(branch)
+	if rate == 0 {
+		return ""
+	}
+	return floatToString(rate)
+}
+
+// hashRate devuelve los hashes por segundo de una rama de Proof-of-Work (0 si no informó hashes).
+// Para una rama cancelada cuenta solo el trabajo realizado hasta que atendió la cancelación.
+func hashRate(branch BranchResult) float64 {
+	if branch.Hashes == 0 || branch.Duration <= 0 {
+		return 0
+	}
+	return float64(branch.Hashes) / branch.Duration.Seconds()
+}
+
+func shadowNumericString(shadow *BranchOutput) string {
+	if shadow == nil {
+		return ""
+	}
+	return strconv.FormatInt(shadow.Numeric, 10)
+}
+
+func shadowDetailString(shadow *BranchOutput) string {
+	if shadow == nil {
+		return ""
+	}
+	return shadow.Detail
+}
+
+// percentileFields formatea los percentiles como ";p50_<mode>_ms=...;p90_<mode>_ms=...".
+func percentileFields(mode string, values []time.Duration) string {
+	var b strings.Builder
+	for i, p := range reportedPercentiles {
+		fmt.Fprintf(&b, ";p%g_%s_ms=%.3f", p, mode, Milliseconds(values[i]))
+	}
+	return b.String()
+}
+
+// dispersionFields formatea la dispersión como
+// ";stddev_<mode>_ms=...;min_<mode>_ms=...;max_<mode>_ms=...".
+func dispersionFields(mode string, dispersion DurationDispersion) string {
+	return fmt.Sprintf(";stddev_%[1]s_ms=%.3[2]f;min_%[1]s_ms=%.3[3]f;max_%[1]s_ms=%.3[4]f", mode,
+		Milliseconds(dispersion.Stddev), Milliseconds(dispersion.Min), Milliseconds(dispersion.Max))
+}
+
+// FormatDispersion formatea la dispersión para la salida por consola.
+func FormatDispersion(dispersion DurationDispersion) string {
+	return fmt.Sprintf("desv. estándar=%s mín=%s máx=%s",
+		FormatDuration(dispersion.Stddev), FormatDuration(dispersion.Min), FormatDuration(dispersion.Max))
+}
+
+// FormatPercentiles formatea los percentiles para la salida por consola.
+func FormatPercentiles(values []time.Duration) string {
+	parts := make([]string, len(reportedPercentiles))
+	for i, p := range reportedPercentiles {
+		parts[i] = fmt.Sprintf("p%g=%s", p, FormatDuration(values[i]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// branchErrorString es la celda error de branch: timed_out si venció -branch-timeout o, si no, el
+// mensaje de su error.
+func branchErrorString(branch BranchResult) string {
+	if branch.TimedOut {
+		return "timed_out"
+	}
+	return errorString(branch.Err)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,1347 @@
+package speculative
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingWork es una rama que solo termina cuando se la cancela, como una perdedora que atiende
+// la cancelación.
+func blockingWork(ctx context.Context) (BranchOutput, error) {
+	<-ctx.Done()
+	return BranchOutput{}, ErrCancelled
+}
+
+// fixedWork devuelve una rama que termina de inmediato con numeric y detail.
+func fixedWork(numeric int64, detail string) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		return BranchOutput{Numeric: numeric, Detail: detail}, nil
+	}
+}
+
+// writeMetrics ejecuta cfg con branches y escribe las métricas en cfg.OutputFile, como main.
+func writeMetrics(t *testing.T, cfg Config, branches []NamedBranch) SpeculativeReport {
+	t.Helper()
+	rows, err := NewMetricsWriter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	report, err := Engine{Branches: branches, OnRun: rows.WriteRun}.Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rows.Finish(report.Summary); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// readMetricsRows lee el CSV de métricas path y devuelve cada fila de corrida como un mapa
+// columna → valor, sin el registro de reproducibilidad, la línea en blanco ni la fila resumen.
+func readMetricsRows(t *testing.T, path string) []map[string]string {
+	t.Helper()
+	content, err := ReadMetricsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 {
+		t.Fatalf("%s: empty file", path)
+	}
+	var rows []map[string]string
+	for _, record := range records[1:] {
+		if len(record) < len(records[0]) {
+			continue
+		}
+		row := make(map[string]string, len(record))
+		for i, name := range records[0] {
+			row[name] = record[i]
+		}
+		if row["mode"] != "resumen" {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func TestSpeculativeRunWithThreeBranches(t *testing.T) {
+	cfg := testConfig()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	cfg.Selector = func(ConditionMetrics) string { return "C" }
+	branches := []NamedBranch{
+		{Name: "A", Work: blockingWork},
+		{Name: "B", Work: blockingWork},
+		{Name: "C", Work: fixedWork(7, "c")},
+	}
+	writeMetrics(t, cfg, branches)
+
+	winners := make(map[string]int)
+	rows := readMetricsRows(t, cfg.OutputFile)
+	for _, row := range rows {
+		if row["mode"] != ModeSpeculative {
+			continue
+		}
+		switch {
+		case row["was_winner"] == "true":
+			winners[row["run"]]++
+			if row["branch"] != "C" || row["cancelled"] != "false" {
+				t.Errorf("run %s: winner row %s cancelled=%s", row["run"], row["branch"], row["cancelled"])
+			}
+		case row["cancelled"] != "true":
+			t.Errorf("run %s: loser %s is not cancelled", row["run"], row["branch"])
+		}
+	}
+	if len(rows) != 4*cfg.Runs {
+		t.Errorf("%d rows, want %d (three per speculative run, one per sequential run)", len(rows), 4*cfg.Runs)
+	}
+	if len(winners) != cfg.Runs {
+		t.Errorf("winners in %d runs, want %d", len(winners), cfg.Runs)
+	}
+	for run, count := range winners {
+		if count != 1 {
+			t.Errorf("run %s: %d rows marked was_winner, want 1", run, count)
+		}
+	}
+}
+
+func TestSpeedupMeans(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name       string
+		speedups   []float64
+		geometric  float64
+		arithmetic float64
+	}{
+		{"empty", nil, nan, nan},
+		{"single", []float64{1.5}, 1.5, 1.5},
+		{"two", []float64{2, 8}, 4, 5},
+		{"three", []float64{1, 3, 9}, 3, 13.0 / 3},
+		{"zero", []float64{2, 0}, nan, 1},
+		{"negative", []float64{2, -1}, nan, 0.5},
+		{"infinite", []float64{2, math.Inf(1)}, nan, math.Inf(1)},
+	}
+	same := func(got, want float64) bool {
+		if math.IsNaN(want) {
+			return math.IsNaN(got)
+		}
+		return got == want || math.Abs(got-want) < 1e-12
+	}
+	for _, tt := range tests {
+		if got := geometricMeanSpeedup(tt.speedups); !same(got, tt.geometric) {
+			t.Errorf("%s: geometricMeanSpeedup(%v) = %v, want %v", tt.name, tt.speedups, got, tt.geometric)
+		}
+		if got := arithmeticMeanSpeedup(tt.speedups); !same(got, tt.arithmetic) {
+			t.Errorf("%s: arithmeticMeanSpeedup(%v) = %v, want %v", tt.name, tt.speedups, got, tt.arithmetic)
+		}
+	}
+}
+
+func TestReportSpeedupMeansSkipsUndefined(t *testing.T) {
+	var file, stdout strings.Builder
+	if err := reportSpeedupMeans(&file, &stdout, []float64{2, math.NaN(), 8}); err != nil {
+		t.Fatal(err)
+	}
+	want := "# geometric_mean_speedup=4.000;arithmetic_mean_speedup=5.000;configurations=2\n"
+	if file.String() != want {
+		t.Errorf("file line = %q, want %q", file.String(), want)
+	}
+	if !strings.Contains(stdout.String(), "geométrica 4.000, aritmética 5.000 (2 configuraciones)") {
+		t.Errorf("console line = %q", stdout.String())
+	}
+}
+
+func TestComputeSpeedupEdgeCases(t *testing.T) {
+	tests := []struct {
+		name                    string
+		sequential, speculative time.Duration
+		want                    string
+	}{
+		{"regular", 300 * time.Millisecond, 100 * time.Millisecond, "3.000"},
+		{"zero speculative", 300 * time.Millisecond, 0, "n/a"},
+		{"zero sequential", 0, 100 * time.Millisecond, "n/a"},
+		{"both zero", 0, 0, "n/a"},
+		{"negative", -time.Millisecond, 100 * time.Millisecond, "n/a"},
+	}
+	for _, tt := range tests {
+		if got := FormatSpeedup(ComputeSpeedup(tt.sequential, tt.speculative)); got != tt.want {
+			t.Errorf("%s: speedup %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if got := FormatSpeedup(math.NaN()); got != "n/a" {
+		t.Errorf("FormatSpeedup(NaN) = %q, want n/a", got)
+	}
+}
+
+// TestSummaryWithAllBranchesCancelled arma un resumen con corridas cuyas ramas se cancelaron todas
+// antes de que el reloj avanzara: el speedup no está definido y la fila de resumen muestra n/a.
+func TestSummaryWithAllBranchesCancelled(t *testing.T) {
+	cancelled := []BranchResult{{Name: branchA, Cancelled: true}, {Name: branchB, Cancelled: true}}
+	specRuns := []ExecutionRun{{Mode: ModeSpeculative, RunIndex: 1, Branches: cancelled}}
+	seqRuns := []ExecutionRun{{Mode: ModeSequential, RunIndex: 1, Branches: cancelled[:1]}}
+	summary := buildSummary(testConfig(), specRuns, seqRuns)
+	if !math.IsNaN(summary.Speedup) {
+		t.Errorf("speedup = %v, want NaN", summary.Speedup)
+	}
+	if len(summary.AvgNumericSpeculative) != 0 || len(summary.AvgNumericSequential) != 0 {
+		t.Errorf("averages %v and %v, want none for cancelled branches",
+			summary.AvgNumericSpeculative, summary.AvgNumericSequential)
+	}
+	if empty := buildSummary(testConfig(), nil, nil); !math.IsNaN(empty.Speedup) {
+		t.Errorf("speedup without runs = %v, want NaN", empty.Speedup)
+	}
+
+	cfg := testConfig()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	writer, err := NewMetricsWriter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Finish(summary); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), ";speedup=n/a;") {
+		t.Errorf("summary row has no speedup=n/a:\n%s", content)
+	}
+}
+
+func TestSieveMatchesTrialDivision(t *testing.T) {
+	for _, limit := range []int{0, 1, 2, 3, 4, 10, 100, 1000, 7919, 7920, 100000} {
+		trial, err := EncontrarPrimosWithCancel(nil, limit)
+		if err != nil {
+			t.Fatalf("limit %d: trial division: %v", limit, err)
+		}
+		sieve, err := EncontrarPrimosSieve(nil, limit)
+		if err != nil {
+			t.Fatalf("limit %d: sieve: %v", limit, err)
+		}
+		if !slices.Equal(sieve, trial) {
+			t.Errorf("limit %d: sieve found %d primes, trial division %d", limit, len(sieve), len(trial))
+		}
+	}
+}
+
+func TestSieveHonorsCancellation(t *testing.T) {
+	cancel := make(chan struct{})
+	close(cancel)
+	if _, err := EncontrarPrimosSieve(cancel, 1000000); !errors.Is(err, ErrCancelled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want ErrCancelled wrapping context.Canceled", err)
+	}
+
+	// Como las demás búsquedas, las cribas informan también por qué terminó el contexto.
+	ctx, stop := context.WithTimeout(context.Background(), 0)
+	defer stop()
+	if _, err := EncontrarPrimosSieveCtx(ctx, 1000000); !errors.Is(err, ErrCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("sieve: err = %v, want ErrCancelled wrapping context.DeadlineExceeded", err)
+	}
+	if _, err := EncontrarPrimosSieveParallelCtx(ctx, 1000000, 2); !errors.Is(err, ErrCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("sieve-parallel: err = %v, want ErrCancelled wrapping context.DeadlineExceeded", err)
+	}
+	if _, _, err := EncontrarPrimosSegmentedCtx(ctx, 1000000, 0); !errors.Is(err, ErrCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("segmented: err = %v, want ErrCancelled wrapping context.DeadlineExceeded", err)
+	}
+}
+
+// TestEncontrarPrimosCancelsPromptly cancela una búsqueda por división sucesiva que tardaría
+// minutos y comprueba que vuelve enseguida, sin esperar al siguiente primo ni al final del rango.
+func TestEncontrarPrimosCancelsPromptly(t *testing.T) {
+	cancel := make(chan struct{})
+	time.AfterFunc(20*time.Millisecond, func() { close(cancel) })
+	start := time.Now()
+	primes, err := EncontrarPrimosWithCancel(cancel, 50000000)
+	elapsed := time.Since(start)
+	if !errors.Is(err, ErrCancelled) || primes != nil {
+		t.Fatalf("got %d primes, err = %v; want nil and ErrCancelled", len(primes), err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("returned %v after starting, want shortly after the 20ms cancellation", elapsed)
+	}
+}
+
+// BenchmarkEncontrarPrimos mide la división sucesiva con un límite chico, donde domina el costo
+// fijo, y con uno grande, donde domina el de cada candidato (go test -bench EncontrarPrimos).
+func BenchmarkEncontrarPrimos(b *testing.B) {
+	for _, limit := range []int{10000, 1000000} {
+		b.Run(fmt.Sprintf("limit=%d", limit), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				EncontrarPrimos(limit)
+			}
+		})
+	}
+}
+
+// TestPrimeCountEstimateBoundsPi comprueba por qué primeCountEstimate usa la cota de Rosser y
+// Schoenfeld en lugar de x/ln(x): la cota nunca queda por debajo de π(x), así que la lista de
+// primos no crece, mientras que x/ln(x) ya se queda corta con límites chicos.
+func TestPrimeCountEstimateBoundsPi(t *testing.T) {
+	primes, err := EncontrarPrimosSieve(nil, 500000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	short := 0
+	for _, limit := range []int{3, 10, 17, 100, 1000, 60000, 500000} {
+		pi, _ := slices.BinarySearch(primes, limit)
+		if estimate := primeCountEstimate(limit); estimate < pi {
+			t.Errorf("primeCountEstimate(%d) = %d, below π = %d", limit, estimate, pi)
+		}
+		if int(float64(limit)/math.Log(float64(limit))) < pi {
+			short++
+		}
+		if got := EncontrarPrimos(limit); cap(got) != primeCountEstimate(limit) || !slices.Equal(got, primes[:pi]) {
+			t.Errorf("limit %d: %d primes with capacity %d, want the first %d with capacity %d",
+				limit, len(got), cap(got), pi, primeCountEstimate(limit))
+		}
+	}
+	if short == 0 {
+		t.Error("x/ln(x) never fell short of π(x); the bound would be unnecessary")
+	}
+}
+
+// BenchmarkPrimesCapacity compara las asignaciones de llenar la lista de primos menores que 10⁶
+// con la capacidad inicial de primeCountEstimate, con x/ln(x) y con la antigua max/10
+// (go test -bench PrimesCapacity -benchmem): solo la primera reserva una única vez.
+func BenchmarkPrimesCapacity(b *testing.B) {
+	const limit = 1000000
+	primes := EncontrarPrimos(limit)
+	estimates := []struct {
+		name     string
+		capacity int
+	}{
+		{"bound", primeCountEstimate(limit)},
+		{"x/ln(x)", int(float64(limit) / math.Log(float64(limit)))},
+		{"max/10", limit / 10},
+	}
+	for _, estimate := range estimates {
+		b.Run(estimate.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				list := make([]int, 0, estimate.capacity)
+				for _, p := range primes {
+					list = append(list, p)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTrace compara las asignaciones de la traza con matrices completas y de TrazaStreaming
+// (go test -bench Trace -benchmem): la primera asigna las dos matrices n×n en cada llamada y la
+// segunda, nada.
+func BenchmarkTrace(b *testing.B) {
+	const n = 200
+	b.Run("matrices", func(b *testing.B) {
+		b.ReportAllocs()
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < b.N; i++ {
+			CalcularTrazaConRNG(n, rng)
+		}
+	})
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < b.N; i++ {
+			TrazaStreaming(n, rng)
+		}
+	})
+}
+
+// TestCalcularTrazaDegenerateSizes compara con trazas calculadas a mano. Con la semilla 1 los
+// primeros valores de rng.Intn(10) son 1 7 7 9 1 8 5 0, que randomMatrices reparte intercalando m1
+// y m2: con n=2, m1 = [[1 7] [1 5]] y m2 = [[7 9] [8 0]].
+func TestCalcularTrazaDegenerateSizes(t *testing.T) {
+	tests := []struct {
+		n    int
+		want int64
+	}{
+		{0, 0},
+		{1, 1 * 7},
+		{2, 1*7 + 7*8 + 1*9 + 5*0},
+	}
+	for _, tt := range tests {
+		if got := CalcularTrazaConRNG(tt.n, rand.New(rand.NewSource(1))); got != tt.want {
+			t.Errorf("n=%d: trace %d, want %d", tt.n, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkCalcularTrazaConRNG y BenchmarkCalcularTrazaPlana comparan, con n=512, la traza con
+// una slice por fila y con matrices planas (go test -bench CalcularTraza -benchmem).
+func BenchmarkCalcularTrazaConRNG(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		CalcularTrazaConRNG(512, rng)
+	}
+}
+
+func BenchmarkCalcularTrazaPlana(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		CalcularTrazaPlana(512, rng)
+	}
+}
+
+func TestCalcularTrazaPlanaMatchesNested(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 17, 128} {
+		for seed := int64(1); seed <= 3; seed++ {
+			nested := CalcularTrazaConRNG(n, rand.New(rand.NewSource(seed)))
+			flat := CalcularTrazaPlana(n, rand.New(rand.NewSource(seed)))
+			if flat != nested {
+				t.Errorf("n=%d seed=%d: flat trace %d, nested trace %d", n, seed, flat, nested)
+			}
+		}
+	}
+}
+
+// TestReproducibilityHeader comprueba que el registro de reproducibilidad del CSV y del JSON
+// contiene la herramienta, la versión y la configuración completa, incluida la semilla.
+func TestReproducibilityHeader(t *testing.T) {
+	cfg := testConfig()
+	cfg.Threshold = 1234
+	cfg.PowData = "header"
+	dir := t.TempDir()
+
+	for _, format := range []string{formatCSV, formatJSON} {
+		cfg := cfg
+		cfg.Format = format
+		cfg.OutputFile = filepath.Join(dir, "metricas."+format)
+		writeMetrics(t, cfg, nil)
+
+		content, err := os.ReadFile(cfg.OutputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var header runHeader
+		if format == formatCSV {
+			line, _, _ := bytes.Cut(content, []byte("\n"))
+			encoded, ok := bytes.CutPrefix(line, []byte("# "))
+			if !ok {
+				t.Fatalf("csv: first line %q is not a comment", line)
+			}
+			err = json.Unmarshal(encoded, &header)
+		} else {
+			var report struct {
+				Config runHeader `json:"config"`
+			}
+			err = json.Unmarshal(content, &report)
+			header = report.Config
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if header.Tool != "tarea02" || header.Version != Version {
+			t.Errorf("%s: tool %q version %q", format, header.Tool, header.Version)
+		}
+		if !reflect.DeepEqual(header.Config, cfg) {
+			t.Errorf("%s: config does not round-trip:\n got %+v\nwant %+v", format, header.Config, cfg)
+		}
+	}
+}
+
+// bigTrace calcula la traza de m1·m2 con big.Int, como referencia sin desbordamiento.
+func bigTrace(m1, m2 [][]int) *big.Int {
+	trace := new(big.Int)
+	product := new(big.Int)
+	for i := range m1 {
+		for k := range m1 {
+			product.Mul(big.NewInt(int64(m1[i][k])), big.NewInt(int64(m2[k][i])))
+			trace.Add(trace, product)
+		}
+	}
+	return trace
+}
+
+// TestTraceDoesNotOverflowInt32 usa elementos de hasta 2^20 para que la traza supere
+// math.MaxInt32 con matrices pequeñas y compara cada forma de calcularla con big.Int.
+func TestTraceDoesNotOverflowInt32(t *testing.T) {
+	const n, maxValue = 64, 1 << 20
+	m1, m2 := randomMatrices(n, maxValue, rand.New(rand.NewSource(7)))
+	want := bigTrace(m1, m2)
+	if want.Cmp(big.NewInt(math.MaxInt32)) <= 0 {
+		t.Fatalf("reference trace %v does not exceed math.MaxInt32", want)
+	}
+	if !want.IsInt64() {
+		t.Fatalf("reference trace %v does not fit in int64", want)
+	}
+
+	// Con el umbral igual a la traza, la comparación solo queda decidida en la última fila.
+	early, rows := earlyTrace(m1, m2, want.Int64(), maxValue)
+	for name, got := range map[string]int64{
+		"productTrace":  productTrace(m1, m2),
+		"matrixMetrics": matrixMetrics(m1, m2).Trace,
+		"earlyTrace":    early,
+	} {
+		if got != want.Int64() {
+			t.Errorf("%s = %d, want %v", name, got, want)
+		}
+	}
+	if rows != n {
+		t.Errorf("earlyTrace stopped after %d rows, want %d", rows, n)
+	}
+}
+
+// runsWithDurations arma corridas especulativas con las duraciones totales indicadas, en orden.
+func runsWithDurations(durations ...time.Duration) []ExecutionRun {
+	runs := make([]ExecutionRun, len(durations))
+	for i, d := range durations {
+		runs[i] = ExecutionRun{Mode: ModeSpeculative, RunIndex: i + 1, TotalDuration: d}
+	}
+	return runs
+}
+
+// TestPercentileDurationInterpolates usa las duraciones 1..100 ms, desordenadas: el percentil p
+// cae en el rango p/100·99 y se interpola entre las dos corridas vecinas.
+func TestPercentileDurationInterpolates(t *testing.T) {
+	durations := make([]time.Duration, 100)
+	for i := range durations {
+		durations[i] = time.Duration(i+1) * time.Millisecond
+	}
+	rand.New(rand.NewSource(3)).Shuffle(len(durations), func(i, j int) {
+		durations[i], durations[j] = durations[j], durations[i]
+	})
+	runs := runsWithDurations(durations...)
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 50500 * time.Microsecond},
+		{90, 90100 * time.Microsecond},
+		{95, 95050 * time.Microsecond},
+		{99, 99010 * time.Microsecond},
+		{100, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentileDuration(runs, tt.p); got != tt.want {
+			t.Errorf("p%g = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if runs[0].TotalDuration != durations[0] {
+		t.Error("percentileDuration reordered runs")
+	}
+	if got := percentileDuration(nil, 50); got != 0 {
+		t.Errorf("p50 of no runs = %v, want 0", got)
+	}
+	if got := percentileDurations(runs); len(got) != len(reportedPercentiles) || got[0] != 50500*time.Microsecond {
+		t.Errorf("percentileDurations = %v", got)
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want int
+	}{
+		{"empty", nil, 0},
+		{"all zero", []byte{0, 0, 0}, 24},
+		{"no leading zero", []byte{0x80, 0}, 0},
+		{"partial byte", []byte{0x1f}, 3},
+		{"zero byte then partial", []byte{0, 0x01, 0xff}, 15},
+		{"zero bytes then full", []byte{0, 0, 0xff}, 16},
+		{"last bit set", []byte{0, 0, 1}, 23},
+	}
+	for _, tt := range tests {
+		if got := leadingZeroBits(tt.in); got != tt.want {
+			t.Errorf("%s: leadingZeroBits(%x) = %d, want %d", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPoWBitsFindsLeadingZeroBits(t *testing.T) {
+	for _, bits := range []int{1, 5, 9, 13} {
+		hash, nonce, err := SimularPoWBitsCtx(context.Background(), "bits", bits, 0)
+		if err != nil {
+			t.Fatalf("%d bits: %v", bits, err)
+		}
+		sum := sha256.Sum256([]byte(fmt.Sprintf("bits%d", nonce)))
+		if hex.EncodeToString(sum[:]) != hash {
+			t.Errorf("%d bits: nonce %d hashes to %x, not %s", bits, nonce, sum, hash)
+		}
+		if got := leadingZeroBits(sum[:]); got < bits {
+			t.Errorf("%d bits: hash %s has only %d leading zero bits", bits, hash, got)
+		}
+	}
+}
+
+func TestProofOfWorkExhaustsMaxNonce(t *testing.T) {
+	hash, nonce, err := SimularProofOfWorkCtx(context.Background(), "speculative", 20, 500)
+	if !errors.Is(err, ErrExhausted) {
+		t.Fatalf("err = %v (hash %q, nonce %d), want ErrExhausted", err, hash, nonce)
+	}
+
+	// En una corrida, el agotamiento queda registrado en la rama sin abortar el lote.
+	cfg := testConfig()
+	cfg.Threshold = 0
+	cfg.PowDifficulty = 20
+	cfg.MaxNonce = 500
+	report, err := Engine{}.Run(cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for _, run := range append(report.Speculative, report.Sequential...) {
+		for _, branch := range run.Branches {
+			if branch.Name == branchA && !errors.Is(branch.Err, ErrExhausted) {
+				t.Errorf("%s run %d: branch A err = %v, want ErrExhausted", run.Mode, run.RunIndex, branch.Err)
+			}
+		}
+	}
+}
+
+func TestVerificarProofOfWork(t *testing.T) {
+	for _, difficulty := range []int{1, 2, 3, 4} {
+		hash, nonce := SimularProofOfWork("speculative", difficulty)
+		valid, computed := VerificarProofOfWork("speculative", nonce, difficulty)
+		if !valid || computed != hash {
+			t.Errorf("difficulty %d: nonce %d verified %v with hash %s, want true with %s", difficulty, nonce, valid, computed, hash)
+		}
+		// Un nonce alterado produce otro hash; el anterior al encontrado no cumple la dificultad,
+		// porque la búsqueda devuelve el primero válido.
+		if nonce > 0 {
+			if valid, computed := VerificarProofOfWork("speculative", nonce-1, difficulty); valid || computed == hash {
+				t.Errorf("difficulty %d: tampered nonce %d verified %v with hash %s", difficulty, nonce-1, valid, computed)
+			}
+		}
+		if valid, _ := VerificarProofOfWork("tampered", nonce, difficulty+4); valid {
+			t.Errorf("difficulty %d: tampered data verified", difficulty)
+		}
+	}
+}
+
+func TestVerificarProofOfWorkHashHonorsHashAndMode(t *testing.T) {
+	ctx := context.Background()
+	for _, name := range PowHashNames() {
+		hash, nonce, err := SimularPoWBitsHashCtx(ctx, PowHashes[name], "modes", 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid, computed := VerificarProofOfWorkHash("modes", nonce, 10, PowModeBits, name)
+		if !valid || computed != hash {
+			t.Errorf("%s bits: verified %v with %s, want true with %s", name, valid, computed, hash)
+		}
+		if valid, _ := VerificarProofOfWorkHash("modes", nonce, 10, PowModeHex, name); valid {
+			t.Errorf("%s: a 10-bit hash should not have 10 leading zero hex digits", name)
+		}
+	}
+	if valid, computed := VerificarProofOfWorkHash("x", 1, 1, PowModeHex, "md5"); valid || computed != "" {
+		t.Errorf("unknown hash: verified %v with %q, want false with an empty hash", valid, computed)
+	}
+	_, nonce := SimularProofOfWork("speculative", 2)
+	if valid, _ := VerificarProofOfWorkHash("speculative", nonce, 2, "", ""); !valid {
+		t.Error("empty mode and hash should default to hex and sha256")
+	}
+}
+
+func TestWarmupRunsAreNotWritten(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 2
+	cfg.Warmup = 3
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	report := writeMetrics(t, cfg, nil)
+
+	if len(report.Speculative) != cfg.Runs || len(report.Sequential) != cfg.Runs {
+		t.Fatalf("%d speculative and %d sequential runs, want %d each", len(report.Speculative), len(report.Sequential), cfg.Runs)
+	}
+	runs := make(map[string]map[string]bool)
+	for _, row := range readMetricsRows(t, cfg.OutputFile) {
+		if runs[row["mode"]] == nil {
+			runs[row["mode"]] = make(map[string]bool)
+		}
+		runs[row["mode"]][row["run"]] = true
+	}
+	for _, mode := range []string{ModeSpeculative, ModeSequential} {
+		if len(runs[mode]) != cfg.Runs || !runs[mode]["1"] || !runs[mode]["2"] {
+			t.Errorf("%s: written runs %v, want only 1 and 2", mode, runs[mode])
+		}
+	}
+}
+
+func TestDurationDispersionHelpers(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		stddev    time.Duration
+		min, max  time.Duration
+	}{
+		{"none", nil, 0, 0, 0},
+		{"single", []time.Duration{7 * ms}, 0, 7 * ms, 7 * ms},
+		// media 20 ms, suma de cuadrados 200 ms², varianza muestral 200/2 = 100 ms².
+		{"three", []time.Duration{20 * ms, 10 * ms, 30 * ms}, 10 * ms, 10 * ms, 30 * ms},
+		// media 5 ms, suma de cuadrados 32 ms², desviación √(32/7) ms = 2,13809 ms.
+		{"eight", []time.Duration{2 * ms, 4 * ms, 4 * ms, 4 * ms, 5 * ms, 5 * ms, 7 * ms, 9 * ms}, 2138090 * time.Nanosecond, 2 * ms, 9 * ms},
+	}
+	for _, tt := range tests {
+		runs := runsWithDurations(tt.durations...)
+		if got := stddevDuration(runs); got != tt.stddev {
+			t.Errorf("%s: stddevDuration = %v, want %v", tt.name, got, tt.stddev)
+		}
+		if got := minDuration(runs); got != tt.min {
+			t.Errorf("%s: minDuration = %v, want %v", tt.name, got, tt.min)
+		}
+		if got := maxDuration(runs); got != tt.max {
+			t.Errorf("%s: maxDuration = %v, want %v", tt.name, got, tt.max)
+		}
+		want := DurationDispersion{Stddev: tt.stddev, Min: tt.min, Max: tt.max}
+		if got := durationDispersion(runs); got != want {
+			t.Errorf("%s: durationDispersion = %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"zero threshold", func(c *Config) { c.Threshold = 0 }, ""},
+		{"negative threshold", func(c *Config) { c.Threshold = -1 }, "umbral no puede ser negativo"},
+		{"empty pow-data", func(c *Config) { c.PowData = "" }, "pow-data no puede estar vacío"},
+		{"blank pow-data", func(c *Config) { c.PowData = " \t" }, "pow-data no puede estar vacío"},
+		{"zero n", func(c *Config) { c.MatrixSize = 0 }, "n debe ser mayor que cero"},
+		{"zero runs", func(c *Config) { c.Runs = 0 }, "runs debe ser mayor que cero"},
+		{"zero difficulty", func(c *Config) { c.PowDifficulty = 0 }, "difficulty debe ser mayor que cero"},
+		{"zero primes-limit", func(c *Config) { c.PrimesLimit = 0 }, "primes-limit debe ser mayor que cero"},
+		{"blank output file", func(c *Config) { c.OutputFile = "  " }, "nombre_archivo no puede estar vacío"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		tt.modify(&cfg)
+		err := ValidateConfig(cfg)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// TestBranchBAllocatesMoreThanA ejecuta cada rama por separado, como en la estrategia secuencial,
+// para que TotalAlloc no mezcle lo asignado por la otra rama. Usa la criba, que arma la lista de
+// primos; la división por tentativa de la rama B por defecto solo los cuenta.
+func TestBranchBAllocatesMoreThanA(t *testing.T) {
+	cfg := testConfig()
+	cfg.PrimesLimit = 200000
+	cfg.PrimesAlgo = PrimesSieve
+	branches, err := BuildBranchWorkload(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alloc := make(map[string]uint64)
+	for _, branch := range branches {
+		result := executeBranchSync(context.Background(), realClock{}, branch.Name, branch.Work)
+		if result.Err != nil {
+			t.Fatalf("%s: %v", branch.Name, result.Err)
+		}
+		alloc[branch.Name] = result.AllocBytes
+	}
+	if alloc[branchB] <= alloc[branchA] {
+		t.Errorf("alloc A = %d, B = %d; B keeps every prime and should allocate more", alloc[branchA], alloc[branchB])
+	}
+
+	cfg.OutputFile = t.TempDir() + "/metricas.csv"
+	writeMetrics(t, cfg, nil)
+	rows := readMetricsRows(t, cfg.OutputFile)
+	if len(rows) == 0 {
+		t.Fatal("no metrics rows written")
+	}
+	for _, row := range rows {
+		if _, err := strconv.ParseUint(row["branch_alloc_bytes"], 10, 64); err != nil {
+			t.Fatalf("branch_alloc_bytes = %q: %v", row["branch_alloc_bytes"], err)
+		}
+	}
+}
+
+func TestContarPrimosMatchesEncontrarPrimos(t *testing.T) {
+	for _, limit := range []int{0, 1, 2, 3, 10, 100, 7919, 7920, 100000} {
+		primes := EncontrarPrimos(limit)
+		wantLast := 0
+		if len(primes) > 0 {
+			wantLast = primes[len(primes)-1]
+		}
+		count, last, err := ContarPrimos(nil, limit)
+		if err != nil {
+			t.Fatalf("limit %d: %v", limit, err)
+		}
+		if count != len(primes) || last != wantLast {
+			t.Errorf("limit %d: ContarPrimos = %d, %d; EncontrarPrimos has %d primes, last %d", limit, count, last, len(primes), wantLast)
+		}
+
+		// La rama B por defecto cuenta los primos; su detalle debe ser el de la lista completa.
+		listed, err := primeSearchWork(func(ctx context.Context) ([]int, error) {
+			return EncontrarPrimosCtx(ctx, limit)
+		})(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		counted, err := primesWork(2, limit)(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counted.Numeric != listed.Numeric || counted.Detail != listed.Detail {
+			t.Errorf("limit %d: counting branch %d %q, listing branch %d %q", limit, counted.Numeric, counted.Detail, listed.Numeric, listed.Detail)
+		}
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		windows string // resultado esperado en Windows, donde \ también separa; vacío si coincide
+	}{
+		{"metricas.csv", ".", ""},
+		{"out/metricas.csv", "out", ""},
+		{"out/sub/metricas.csv", "out/sub", `out\sub`},
+		{"./out//metricas.csv", "out", ""},
+		{"/tmp/metricas.csv", "/tmp", `\tmp`},
+		{`out\sub/metricas.csv`, `out\sub`, ""},
+		{`out/sub\metricas.csv`, "out", `out\sub`},
+		{`out\metricas.csv`, ".", `out`},
+	}
+	for _, tt := range tests {
+		want := tt.want
+		if runtime.GOOS == "windows" && tt.windows != "" {
+			want = tt.windows
+		}
+		if got := Directory(tt.path); got != want {
+			t.Errorf("Directory(%q) = %q, want %q", tt.path, got, want)
+		}
+	}
+	if got := Directory(filepath.Join("a", "b", "metricas.csv")); got != filepath.Join("a", "b") {
+		t.Errorf("Directory of a joined path = %q, want %q", got, filepath.Join("a", "b"))
+	}
+}
+
+// TestFinishOrderRecordsTheFirstResult hace ganar por umbral a una rama A lenta y deja que la
+// perdedora B, que no necesita atender la cancelación, termine antes: B debe quedar con orden 1.
+func TestFinishOrderRecordsTheFirstResult(t *testing.T) {
+	cfg := testConfig()
+	cfg.Threshold = 0 // gana siempre A
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	slowA := func(ctx context.Context) (BranchOutput, error) {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return BranchOutput{Numeric: 1, Detail: "lenta"}, nil
+		case <-ctx.Done():
+			return BranchOutput{}, ErrCancelled
+		}
+	}
+	branches := []NamedBranch{{Name: branchA, Work: slowA}, {Name: branchB, Work: fixedWork(2, "rapida")}}
+	report := writeMetrics(t, cfg, branches)
+
+	for _, run := range report.Speculative {
+		if run.Winner != branchA {
+			t.Fatalf("run %d: winner %s, want A", run.RunIndex, run.Winner)
+		}
+		order := make(map[string]int)
+		for _, branch := range run.Branches {
+			order[branch.Name] = branch.FinishOrder
+		}
+		if order[branchB] != 1 || order[branchA] != 2 {
+			t.Errorf("run %d: finish order %v, want B=1 A=2", run.RunIndex, order)
+		}
+	}
+	checked := 0
+	for _, row := range readMetricsRows(t, cfg.OutputFile) {
+		if row["mode"] != ModeSpeculative {
+			continue
+		}
+		want := "1"
+		if row["branch"] == branchA {
+			want = "2"
+		}
+		if row["finish_order"] != want {
+			t.Errorf("run %s branch %s: finish_order %q, want %s", row["run"], row["branch"], row["finish_order"], want)
+		}
+		checked++
+	}
+	if checked != 2*cfg.Runs {
+		t.Errorf("%d speculative rows, want %d", checked, 2*cfg.Runs)
+	}
+}
+
+func TestWastedWork(t *testing.T) {
+	ms := time.Millisecond
+	runs := []ExecutionRun{
+		{RunIndex: 1, Branches: []BranchResult{
+			{Name: branchA, Duration: 5 * ms},
+			{Name: branchB, Duration: 3 * ms, Cancelled: true},
+		}},
+		{RunIndex: 2, Branches: []BranchResult{
+			{Name: branchA, Duration: 7 * ms, Cancelled: true},
+			{Name: branchB, Duration: 2 * ms},
+			{Name: "C", Duration: 11 * ms, Cancelled: true},
+		}},
+		{RunIndex: 3}, // sin ramas, como una corrida abandonada antes de recibir resultados
+	}
+	tests := []struct {
+		name string
+		runs []ExecutionRun
+		want time.Duration
+	}{
+		{"none", nil, 0},
+		{"one run", runs[:1], 3 * ms},
+		{"all runs", runs, 21 * ms},
+	}
+	for _, tt := range tests {
+		if got := wastedWork(tt.runs); got != tt.want {
+			t.Errorf("%s: wastedWork = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got := speculationBenefit(10*ms, 6*ms, 3); got != 12*ms {
+		t.Errorf("speculationBenefit(10ms, 6ms, 3) = %v, want 12ms", got)
+	}
+	if got := speculationBenefit(6*ms, 10*ms, 3); got != -12*ms {
+		t.Errorf("speculationBenefit(6ms, 10ms, 3) = %v, want -12ms", got)
+	}
+}
+
+// flakyWork devuelve una rama que falla las primeras failures veces y luego termina bien, y el
+// contador de sus ejecuciones.
+func flakyWork(failures int) (BranchWork, *int) {
+	calls := 0
+	return func(ctx context.Context) (BranchOutput, error) {
+		calls++
+		if calls <= failures {
+			return BranchOutput{}, errors.New("fallo transitorio")
+		}
+		return BranchOutput{Numeric: 7, Detail: "ok"}, nil
+	}, &calls
+}
+
+func TestRetryWork(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		wantErr   bool
+		wantCalls int
+	}{
+		{"enough retries", 3, false, 3},
+		{"exact retries", 2, false, 3},
+		{"too few retries", 1, true, 2},
+	}
+	for _, tt := range tests {
+		work, calls := flakyWork(2)
+		result := executeBranchSync(context.Background(), realClock{}, "C", retryWork(work, tt.retries))
+		if (result.Err != nil) != tt.wantErr || *calls != tt.wantCalls {
+			t.Errorf("%s: err = %v after %d calls, want error=%v after %d", tt.name, result.Err, *calls, tt.wantErr, tt.wantCalls)
+		}
+		if !tt.wantErr && (result.Retries != 2 || result.Numeric != 7) {
+			t.Errorf("%s: retries %d numeric %d, want 2 and 7", tt.name, result.Retries, result.Numeric)
+		}
+	}
+
+	// La cancelación y el agotamiento de nonces no se reintentan.
+	for _, stop := range []error{ErrCancelled, ErrExhausted} {
+		calls := 0
+		work := func(context.Context) (BranchOutput, error) {
+			calls++
+			return BranchOutput{}, stop
+		}
+		if _, err := retryWork(work, 3)(context.Background()); !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("%v: err = %v after %d calls, want it returned after 1", stop, err, calls)
+		}
+	}
+}
+
+func TestPrimesRangeMatchesFilteredSieve(t *testing.T) {
+	const limit = 1001000
+	all, err := EncontrarPrimosSieve(nil, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows := [][2]int{{0, 10}, {2, 3}, {3, 3}, {10, 2}, {14, 17}, {90, 100}, {7919, 7920}, {1000, 5000}, {1000000, 1001000}}
+	for _, w := range windows {
+		lo, hi := w[0], w[1]
+		var want []int
+		for _, p := range all {
+			if p >= lo && p < hi {
+				want = append(want, p)
+			}
+		}
+		got, err := EncontrarPrimosRango(nil, lo, hi)
+		if err != nil {
+			t.Fatalf("[%d, %d): %v", lo, hi, err)
+		}
+		if len(got) != len(want) || (len(want) > 0 && !slices.Equal(got, want)) {
+			t.Errorf("[%d, %d): %d primes, filtered sieve %d", lo, hi, len(got), len(want))
+		}
+
+		// El detalle de la rama B con -primes-low informa el conteo de la ventana.
+		if lo >= 2 && hi > lo {
+			output, err := primesWork(lo, hi)(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output.Numeric != int64(len(want)) {
+				t.Errorf("[%d, %d): branch B counted %d primes, want %d", lo, hi, output.Numeric, len(want))
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		low, limit int
+		ok         bool
+	}{{2, 2, true}, {-1, 100, false}, {500, 500, false}, {500, 400, false}, {500, 501, true}} {
+		cfg := testConfig()
+		cfg.PrimesLow, cfg.PrimesLimit = tt.low, tt.limit
+		if err := ValidateConfig(cfg); (err == nil) != tt.ok {
+			t.Errorf("primes-low %d, primes-limit %d: err = %v, want ok=%v", tt.low, tt.limit, err, tt.ok)
+		}
+	}
+}
+
+// TestCPUTimeSourceWithWorkers comprueba que las ramas que reparten su trabajo en goroutines
+// informan su tiempo de CPU como thread-only y las demás como thread (o wall fuera de Linux).
+func TestCPUTimeSourceWithWorkers(t *testing.T) {
+	single, workers := cpuTimeThread, cpuTimeThreadOnly
+	if runtime.GOOS != "linux" {
+		single, workers = cpuTimeWall, cpuTimeWall
+	}
+	cfg := testConfig()
+	parallel := testConfig()
+	parallel.PowWorkers = 2
+	parallel.PrimesAlgo = PrimesSieveParallel
+	parallel.PrimesWorkers = 2
+	for _, tt := range []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"single goroutine", cfg, single},
+		{"worker goroutines", parallel, workers},
+	} {
+		branches, err := BuildBranchWorkload(tt.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, branch := range branches {
+			result := executeBranchSync(context.Background(), realClock{}, branch.Name, branch.Work)
+			if result.Err != nil {
+				t.Fatalf("%s %s: %v", tt.name, branch.Name, result.Err)
+			}
+			if result.CPUTimeSource != tt.want {
+				t.Errorf("%s %s: cpu_time_source = %q, want %q", tt.name, branch.Name, result.CPUTimeSource, tt.want)
+			}
+		}
+	}
+}
+
+// TestPrimeStatsBelow100 compara -primes-stats con los valores conocidos bajo 100: 25 primos, ocho
+// pares gemelos (3-5, 5-7, 11-13, 17-19, 29-31, 41-43, 59-61 y 71-73) y la brecha 89-97 de 8.
+func TestPrimeStatsBelow100(t *testing.T) {
+	for _, algo := range []string{PrimesTrial, PrimesSieve, PrimesSieveParallel} {
+		cfg := testConfig()
+		cfg.PrimesLimit = 100
+		cfg.PrimesAlgo = algo
+		output, err := primeStatsWork(primeVisitor(cfg))(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", algo, err)
+		}
+		if want := "count=25,last=97;twins=8;maxgap=8"; output.Numeric != 25 || output.Detail != want {
+			t.Errorf("%s: numeric %d detail %q, want 25 and %q", algo, output.Numeric, output.Detail, want)
+		}
+	}
+}
+
+// TestTrazaAAtIsDiagonalSum arma A·Aᵀ completa con la misma semilla que TrazaAAt y compara la suma
+// de su diagonal con el resultado.
+func TestTrazaAAtIsDiagonalSum(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 7, 30} {
+		a := randomMatrix(n, DefaultMatrixMax, rand.New(rand.NewSource(int64(n)+1)))
+		product := make([][]int64, n)
+		for i := range product {
+			product[i] = make([]int64, n)
+			for j := range product[i] {
+				for k := 0; k < n; k++ {
+					product[i][j] += int64(a[i][k]) * int64(a[j][k])
+				}
+			}
+		}
+		var diagonal int64
+		for i := range product {
+			diagonal += product[i][i]
+		}
+		if got := TrazaAAt(n, rand.New(rand.NewSource(int64(n)+1))); got != diagonal {
+			t.Errorf("n=%d: TrazaAAt = %d, diagonal of A·Aᵀ = %d", n, got, diagonal)
+		}
+	}
+
+	if got := TrazaAAt(0, rand.New(rand.NewSource(1))); got != 0 {
+		t.Errorf("n=0: TrazaAAt = %d, want 0", got)
+	}
+	a := rand.New(rand.NewSource(5)).Intn(DefaultMatrixMax)
+	if got := TrazaAAt(1, rand.New(rand.NewSource(5))); got != int64(a*a) {
+		t.Errorf("n=1: TrazaAAt = %d, want %d²", got, a)
+	}
+}
+
+// TestAbandonDoesNotWaitForSlowLoser usa una perdedora que ignora la cancelación durante un
+// tiempo fijo: con drain la corrida la espera y con abandon termina con el resultado de la ganadora.
+func TestAbandonDoesNotWaitForSlowLoser(t *testing.T) {
+	const lag = 200 * time.Millisecond
+	stubborn := func(ctx context.Context) (BranchOutput, error) {
+		time.Sleep(lag)
+		return BranchOutput{}, ErrCancelled
+	}
+	branches := []NamedBranch{{Name: branchA, Work: fixedWork(1, "a")}, {Name: branchB, Work: stubborn}}
+	for _, tt := range []struct {
+		mode     string
+		waits    bool
+		branches int
+	}{
+		{CancelDrain, true, 2},
+		{CancelAbandon, false, 1},
+	} {
+		cfg := testConfig()
+		cfg.Selector = func(ConditionMetrics) string { return branchA }
+		cfg.CancelMode = tt.mode
+		run, err := runSpeculative(context.Background(), cfg, 1, branches)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.mode, err)
+		}
+		if waited := run.TotalDuration >= lag; waited != tt.waits {
+			t.Errorf("%s: total duration %v, want it to include the %v loser: %v", tt.mode, run.TotalDuration, lag, tt.waits)
+		}
+		if len(run.Branches) != tt.branches {
+			t.Errorf("%s: %d branch results, want %d", tt.mode, len(run.Branches), tt.branches)
+		}
+	}
+}
+
+// TestBranchTimeoutMarksTimedOut da a la rama A un Proof-of-Work que no termina dentro de
+// -branch-timeout y comprueba que ambas estrategias la registran como timed_out.
+func TestBranchTimeoutMarksTimedOut(t *testing.T) {
+	cfg := testConfig()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "metricas.csv")
+	cfg.Runs = 2
+	cfg.Threshold = 0 // gana siempre A
+	cfg.PowDifficulty = 60
+	cfg.BranchTimeout = 20 * time.Millisecond
+	report := writeMetrics(t, cfg, nil)
+
+	if got := TimedOutWinners(report.Speculative) + TimedOutWinners(report.Sequential); got != 2*cfg.Runs {
+		t.Errorf("%d timed-out winners, want %d", got, 2*cfg.Runs)
+	}
+	for _, row := range readMetricsRows(t, cfg.OutputFile) {
+		if row["branch"] != branchA {
+			continue
+		}
+		if row["error"] != "timed_out" || row["cancelled"] != "true" {
+			t.Errorf("%s run %s: A error=%q cancelled=%s, want timed_out and true", row["mode"], row["run"], row["error"], row["cancelled"])
+		}
+		if ms, _ := strconv.ParseFloat(row["branch_duration_ms"], 64); ms > 1000 {
+			t.Errorf("%s run %s: A ran %.0f ms despite the 20 ms timeout", row["mode"], row["run"], ms)
+		}
+	}
+}
+
+func TestBranchErrorUnwraps(t *testing.T) {
+	failure := errors.New("disk on fire")
+	cfg := testConfig()
+	cfg.Selector = func(ConditionMetrics) string { return branchB }
+	branches := []NamedBranch{
+		{Name: branchA, Work: blockingWork},
+		{Name: branchB, Work: func(context.Context) (BranchOutput, error) { return BranchOutput{}, failure }},
+	}
+	_, err := Engine{Branches: branches}.Run(cfg)
+	var branchErr *BranchError
+	if !errors.As(err, &branchErr) {
+		t.Fatalf("err = %v, want a *BranchError", err)
+	}
+	if branchErr.Name != branchB || branchErr.RunIndex != 1 || !errors.Is(err, failure) {
+		t.Errorf("BranchError name %q run %d err %v, want B, 1 and %v", branchErr.Name, branchErr.RunIndex, branchErr.Err, failure)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tt := range []struct {
+		err       error
+		sentinels []error
+	}{
+		{cancelledError(ctx), []error{ErrCancelled, context.Canceled}},
+		{fmt.Errorf("nonce 10: %w", ErrExhausted), []error{ErrExhausted}},
+	} {
+		wrapped := measuredRunError(ModeSpeculative, 2, &BranchError{Name: branchA, RunIndex: 2, Err: tt.err})
+		for _, sentinel := range tt.sentinels {
+			if !errors.Is(wrapped, sentinel) {
+				t.Errorf("%v does not unwrap to %v", wrapped, sentinel)
+			}
+		}
+		if !errors.As(wrapped, &branchErr) || branchErr.Name != branchA || branchErr.RunIndex != 2 {
+			t.Errorf("%v: errors.As gave %+v, want A in run 2", wrapped, branchErr)
+		}
+	}
+}
+
+// runsWithWinners devuelve una corrida por elemento de winners, con ese ganador.
+func runsWithWinners(winners ...string) []ExecutionRun {
+	runs := make([]ExecutionRun, len(winners))
+	for i, winner := range winners {
+		runs[i] = ExecutionRun{RunIndex: i + 1, Winner: winner}
+	}
+	return runs
+}
+
+func TestWinnerCounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		winners []string
+		want    map[string]int
+		fields  string
+	}{
+		{"no runs", nil, map[string]int{}, "wins_a=0;wins_b=0"},
+		{"only A", []string{"A", "A"}, map[string]int{"A": 2}, "wins_a=2;wins_b=0"},
+		{"mixed", []string{"B", "A", "B", "B"}, map[string]int{"A": 1, "B": 3}, "wins_a=1;wins_b=3"},
+		{"extra branch", []string{"C", "A", "C"}, map[string]int{"A": 1, "C": 2}, "wins_a=1;wins_b=0;wins_c=2"},
+	}
+	for _, tt := range tests {
+		counts := winnerCounts(runsWithWinners(tt.winners...))
+		if !reflect.DeepEqual(counts, tt.want) {
+			t.Errorf("%s: winnerCounts = %v, want %v", tt.name, counts, tt.want)
+		}
+		if got := WinsFields(counts, ""); got != tt.fields {
+			t.Errorf("%s: WinsFields = %q, want %q", tt.name, got, tt.fields)
+		}
+	}
+	if got := WinsFields(map[string]int{"A": 1, "B": 3}, "_sequential"); got != "wins_a_sequential=1;wins_b_sequential=3" {
+		t.Errorf("WinsFields with suffix = %q", got)
+	}
+}
+
+func TestPairedWinRate(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name      string
+		spec, seq []ExecutionRun
+		rate      float64
+		pairs     int
+	}{
+		{"no runs", nil, nil, 0, 0},
+		{"no sequential", runsWithDurations(ms), nil, 0, 0},
+		{"all faster", runsWithDurations(1*ms, 2*ms), runsWithDurations(5*ms, 5*ms), 1, 2},
+		{"half", runsWithDurations(1*ms, 9*ms, 1*ms, 9*ms), runsWithDurations(5*ms, 5*ms, 5*ms, 5*ms), 0.5, 4},
+		{"tie is not a win", runsWithDurations(5 * ms), runsWithDurations(5 * ms), 0, 1},
+		// Solo cuentan las corridas con el mismo número en ambas estrategias.
+		{"more speculative", runsWithDurations(1*ms, 1*ms, 9*ms), runsWithDurations(5*ms, 5*ms), 1, 2},
+		{"more sequential", runsWithDurations(9 * ms), runsWithDurations(5*ms, 1*ms, 1*ms), 0, 1},
+	}
+	for _, tt := range tests {
+		rate, pairs := pairedWinRate(tt.spec, tt.seq)
+		if rate != tt.rate || pairs != tt.pairs {
+			t.Errorf("%s: pairedWinRate = %v over %d pairs, want %v over %d", tt.name, rate, pairs, tt.rate, tt.pairs)
+		}
+	}
+}
+
+func TestAverageNumericByBranch(t *testing.T) {
+	branch := func(name string, numeric int64, cancelled bool) BranchResult {
+		return BranchResult{Name: name, Numeric: numeric, Cancelled: cancelled}
+	}
+	runs := []ExecutionRun{
+		{RunIndex: 1, Winner: branchA, Branches: []BranchResult{branch(branchA, 10, false), branch(branchB, 999, true)}},
+		{RunIndex: 2, Winner: branchB, Branches: []BranchResult{branch(branchA, 1, true), branch(branchB, 100, false)}},
+		{RunIndex: 3, Winner: branchA, Branches: []BranchResult{branch(branchA, 20, false), branch(branchB, 5, true)}},
+		{RunIndex: 4, Winner: branchB, Branches: []BranchResult{branch(branchB, 300, false)}},
+	}
+	got := averageNumericByBranch(runs)
+	want := map[string]float64{branchA: 15, branchB: 200}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("averageNumericByBranch = %v, want %v (cancelled branches excluded)", got, want)
+	}
+	if got := numericFields(got, "_sequential"); got != "avg_numeric_A_sequential=15.000;avg_numeric_B_sequential=200.000" {
+		t.Errorf("numericFields = %q", got)
+	}
+}
+
+// TestDecisionLogWritesEachRun comprueba, desde OnRun, que la línea de cada corrida especulativa
+// ya está en el archivo cuando la siguiente todavía no empezó.
+func TestDecisionLogWritesEachRun(t *testing.T) {
+	cfg := testConfig()
+	path := filepath.Join(t.TempDir(), "logs", "decisiones.csv")
+	decisions, err := NewDecisionLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decisions.Close()
+
+	written := 0
+	engine := Engine{OnRun: func(run ExecutionRun) error {
+		if run.Mode != ModeSpeculative {
+			return nil
+		}
+		if err := decisions.WriteRun(run); err != nil {
+			return err
+		}
+		written++
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+		want := fmt.Sprintf("%d,%s,%d", run.RunIndex, run.Winner, run.ConditionValue)
+		if len(lines) != written || !strings.HasSuffix(lines[written-1], want) {
+			t.Errorf("after run %d the log holds %q, want %d lines ending in %q", run.RunIndex, lines, written, want)
+		}
+		return nil
+	}}
+	if _, err := engine.Run(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := decisions.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if written != cfg.Runs {
+		t.Errorf("%d lines written, want one per speculative run (%d)", written, cfg.Runs)
+	}
+
+	// Una segunda invocación agrega sus líneas al final en lugar de truncar el archivo.
+	report, err := Engine{}.Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendDecisionLog(path, report.Speculative); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != 2*cfg.Runs {
+		t.Errorf("%d lines after appending, want %d", lines, 2*cfg.Runs)
+	}
+}
@@ -0,0 +1,124 @@
+package speculative
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseSweepSizes interpreta la lista de -sizes: dimensiones de matriz positivas separadas por
+// comas, en el orden en que se ejecutan.
+func parseSweepSizes(spec string) ([]int, error) {
+	return parsePositiveList(spec, "tamaño inválido")
+}
+
+// parsePositiveList interpreta una lista de enteros positivos separados por comas; invalid encabeza
+// el error de un valor que no lo es.
+func parsePositiveList(spec, invalid string) ([]int, error) {
+	var values []int
+	for _, raw := range strings.Split(spec, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s %q", invalid, raw)
+		}
+		values = append(values, n)
+	}
+	if len(values) == 0 {
+		return nil, errors.New("la lista está vacía")
+	}
+	return values, nil
+}
+
+// RunSweep ejecuta la comparación completa (cfg.Runs corridas por estrategia) para cada tamaño de
+// -sizes y escribe en cfg.SweepFile una fila n,avg_spec_ms,avg_seq_ms,speedup por tamaño, en
+// cuanto termina. Por consola muestra las mismas filas y el primer n cuyo speedup supera 1, que es
+// el punto a partir del cual la estrategia especulativa le gana a la secuencial; al final agrega
+// las medias geométrica y aritmética de los speedups (ver reportSpeedupMeans). Si ctx termina,
+// el archivo conserva los tamaños completados y se devuelve ErrInterrupted.
+func RunSweep(ctx context.Context, cfg Config, branches []NamedBranch) error {
+	sizes, err := parseSweepSizes(cfg.SweepSizes)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(Directory(cfg.SweepFile), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(cfg.SweepFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writeRow := func(record []string) error {
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	if err := writeRow([]string{"n", "avg_spec_ms", "avg_seq_ms", "speedup"}); err != nil {
+		return err
+	}
+
+	stdout := ConsoleOutput(cfg)
+	engine := Engine{Branches: branches}
+	if cfg.Verbose {
+		engine.OnRun = func(run ExecutionRun) error {
+			PrintRunDetail(os.Stdout, run)
+			return nil
+		}
+	}
+	crossover := 0
+	speedups := make([]float64, 0, len(sizes))
+	for _, n := range sizes {
+		sizeCfg := cfg
+		sizeCfg.MatrixSize = n
+		// El selector de la política multi depende de n; Engine lo reconstruye.
+		sizeCfg.Selector = nil
+
+		report, err := engine.RunContext(ctx, sizeCfg)
+		if errors.Is(err, ErrInterrupted) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("n=%d: %w", n, err)
+		}
+
+		summary := report.Summary
+		if err := writeRow([]string{
+			strconv.Itoa(n),
+			floatToString(Milliseconds(summary.AvgSpeculative)),
+			floatToString(Milliseconds(summary.Baseline)),
+			FormatSpeedup(summary.Speedup),
+		}); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "n=%d: especulativo %s, secuencial %s, speedup %s\n",
+			n, FormatDuration(summary.AvgSpeculative), FormatDuration(summary.Baseline), FormatSpeedup(summary.Speedup))
+		if crossover == 0 && summary.Speedup > 1 {
+			crossover = n
+		}
+		speedups = append(speedups, summary.Speedup)
+	}
+	if err := reportSpeedupMeans(file, stdout, speedups); err != nil {
+		return err
+	}
+
+	if crossover > 0 {
+		fmt.Fprintf(stdout, "Punto de cruce: primer tamaño con speedup > 1 en n=%d\n", crossover)
+	} else {
+		fmt.Fprintln(stdout, "Punto de cruce: la estrategia especulativa no superó a la secuencial en ningún tamaño")
+	}
+	fmt.Fprintf(stdout, "Barrido almacenado en: %s\n", cfg.SweepFile)
+	return file.Close()
+}
@@ -0,0 +1,51 @@
+package speculative
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestRunSweepWritesOneRowPerSize(t *testing.T) {
+	cfg := testConfig()
+	cfg.Runs = 2
+	cfg.Sweep = true
+	cfg.SweepSizes = "8,16"
+	cfg.SweepFile = filepath.Join(t.TempDir(), "sweep", "barrido.csv")
+	cfg.Quiet = true
+	if err := RunSweep(context.Background(), cfg, nil); err != nil {
+		t.Fatalf("RunSweep: %v", err)
+	}
+
+	file, err := os.Open(cfg.SweepFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("%d records, want a header and 2 rows: %v", len(records), records)
+	}
+	if got := records[0]; len(got) != 4 || got[0] != "n" || got[1] != "avg_spec_ms" || got[2] != "avg_seq_ms" || got[3] != "speedup" {
+		t.Errorf("header = %v", got)
+	}
+	for i, n := range []string{"8", "16"} {
+		row := records[i+1]
+		if row[0] != n {
+			t.Errorf("row %d: n = %s, want %s", i+1, row[0], n)
+		}
+		for _, value := range row[1:] {
+			if v, err := strconv.ParseFloat(value, 64); err != nil || v <= 0 {
+				t.Errorf("row %d: value %q is not a positive number", i+1, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package speculative
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// WorkloadSpec describe de forma declarativa las ramas de un experimento.
+type WorkloadSpec struct {
+	Branches []BranchSpec `json:"branches"`
+}
+
+// BranchSpec define una rama: su nombre, el tipo de trabajo y los parámetros de ese tipo.
+type BranchSpec struct {
+	Name   string                     `json:"name"`
+	Type   string                     `json:"type"`
+	Params map[string]json.RawMessage `json:"params"`
+}
+
+// branchFactories construye el trabajo de cada tipo admitido en el archivo de especificación.
+var branchFactories = map[string]func(spec BranchSpec) (BranchWork, error){
+	"pow": func(spec BranchSpec) (BranchWork, error) {
+		difficulty, err := spec.intParam("difficulty")
+		if err != nil {
+			return nil, err
+		}
+		data, err := spec.stringParam("data")
+		if err != nil {
+			return nil, err
+		}
+		return powWork(nil, data, difficulty, 0), nil
+	},
+	"primes": func(spec BranchSpec) (BranchWork, error) {
+		limit, err := spec.intParam("limit")
+		if err != nil {
+			return nil, err
+		}
+		return primesWork(2, limit), nil
+	},
+	"fib": func(spec BranchSpec) (BranchWork, error) {
+		n, err := spec.intParam("n")
+		if err != nil {
+			return nil, err
+		}
+		return fibWork(n), nil
+	},
+	"sort": func(spec BranchSpec) (BranchWork, error) {
+		size, err := spec.intParam("size")
+		if err != nil {
+			return nil, err
+		}
+		return sortWork(size), nil
+	},
+}
+
+// loadWorkloadSpec lee el archivo JSON y construye el trabajo de cada rama declarada, respetando
+// el orden del archivo. Se admite cualquier cantidad de ramas, pero deben existir A y B porque el
+// selector por umbral elige entre ellas.
+func loadWorkloadSpec(path string) ([]NamedBranch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec WorkloadSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	branches := make([]NamedBranch, 0, len(spec.Branches))
+	for i, branch := range spec.Branches {
+		if strings.TrimSpace(branch.Name) == "" {
+			return nil, fmt.Errorf("%s: la rama %d no tiene nombre", path, i+1)
+		}
+		if _, dup := findBranch(branches, branch.Name); dup {
+			return nil, fmt.Errorf("%s: la rama %s está definida más de una vez", path, branch.Name)
+		}
+		factory, ok := branchFactories[branch.Type]
+		if !ok {
+			return nil, fmt.Errorf("%s: la rama %s tiene un tipo desconocido %q", path, branch.Name, branch.Type)
+		}
+		work, err := factory(branch)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		branches = append(branches, NamedBranch{Name: branch.Name, Work: work})
+	}
+
+	for _, name := range []string{branchA, branchB} {
+		if _, ok := findBranch(branches, name); !ok {
+			return nil, fmt.Errorf("%s: la especificación debe definir la rama %s", path, name)
+		}
+	}
+	return branches, nil
+}
+
+func (spec BranchSpec) intParam(name string) (int, error) {
+	raw, ok := spec.Params[name]
+	if !ok {
+		return 0, fmt.Errorf("la rama %s (%s) requiere el parámetro %q", spec.Name, spec.Type, name)
+	}
+	var value int
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0, fmt.Errorf("la rama %s (%s): el parámetro %q debe ser un entero", spec.Name, spec.Type, name)
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("la rama %s (%s): el parámetro %q debe ser mayor que cero", spec.Name, spec.Type, name)
+	}
+	return value, nil
+}
+
+func (spec BranchSpec) stringParam(name string) (string, error) {
+	raw, ok := spec.Params[name]
+	if !ok {
+		return "", fmt.Errorf("la rama %s (%s) requiere el parámetro %q", spec.Name, spec.Type, name)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("la rama %s (%s): el parámetro %q debe ser un texto", spec.Name, spec.Type, name)
+	}
+	return value, nil
+}
+
+func fibWork(n int) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		value, err := fibonacciCtx(ctx, n)
+		if err != nil {
+			return BranchOutput{Detail: fmt.Sprintf("n=%d", n)}, err
+		}
+		return BranchOutput{
+			Numeric: int64(value.BitLen()),
+			Detail:  fmt.Sprintf("n=%d,bits=%d", n, value.BitLen()),
+		}, nil
+	}
+}
+
+func sortWork(size int) BranchWork {
+	return func(ctx context.Context) (BranchOutput, error) {
+		values := make([]int, size)
+		for i := range values {
+			values[i] = rand.Int()
+		}
+		if err := mergeSortCtx(ctx, values); err != nil {
+			return BranchOutput{Detail: fmt.Sprintf("size=%d", size)}, err
+		}
+		return BranchOutput{
+			Numeric: int64(size),
+			Detail:  fmt.Sprintf("size=%d,min=%d,max=%d", size, values[0], values[size-1]),
+		}, nil
+	}
+}
+
+// fibonacciCtx calcula F(n) de forma iterativa con enteros de precisión arbitraria.
+func fibonacciCtx(ctx context.Context, n int) (*big.Int, error) {
+	a, b := big.NewInt(0), big.NewInt(1)
+	done := ctx.Done()
+	for i := 0; i < n; i++ {
+		if done != nil && i%1024 == 0 {
+			select {
+			case <-done:
+				return nil, cancelledError(ctx)
+			default:
+			}
+		}
+		a.Add(a, b)
+		a, b = b, a
+	}
+	return a, nil
+}
+
+// mergeSortCtx ordena values con un merge sort ascendente iterativo, revisando la cancelación
+// al inicio de cada bloque fusionado.
+func mergeSortCtx(ctx context.Context, values []int) error {
+	original := values
+	buffer := make([]int, len(values))
+	done := ctx.Done()
+	for width := 1; width < len(values); width *= 2 {
+		for lo := 0; lo < len(values); lo += 2 * width {
+			if done != nil {
+				select {
+				case <-done:
+					return cancelledError(ctx)
+				default:
+				}
+			}
+			mid := min(lo+width, len(values))
+			hi := min(lo+2*width, len(values))
+			i, j, k := lo, mid, lo
+			for i < mid && j < hi {
+				if values[i] <= values[j] {
+					buffer[k] = values[i]
+					i++
+				} else {
+					buffer[k] = values[j]
+					j++
+				}
+				k++
+			}
+			k += copy(buffer[k:], values[i:mid])
+			copy(buffer[k:], values[j:hi])
+		}
+		values, buffer = buffer, values
+	}
+	copy(original, values)
+	return nil
+}
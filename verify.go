@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"tarea02/speculative"
+)
+
+// verifyFlags son las flags de verify que, si no se indican, toman el valor registrado en el
+// encabezado "# {...}" del CSV, de modo que basta con pasar el archivo.
+var verifyFlags = []string{"difficulty", "pow-data", "pow-mode", "pow-hash", "delimiter"}
+
+// runVerify implementa el subcomando verify: recorre las filas del CSV de métricas cuyo
+// result_detail es "hash=...", recalcula el hash del nonce de result_numeric y comprueba que
+// coincida con el registrado y que cumpla la dificultad. Informa en w cada fila inválida y un
+// resumen; devuelve un error si alguna falló.
+func runVerify(args []string, w io.Writer) error {
+	fs := newCommandFlagSet(commandVerify)
+	input := fs.String("nombre_archivo", "metricas.csv", "archivo CSV de métricas que se verifica")
+	difficulty := fs.Int("difficulty", 5, "dificultad con que se generó el archivo (por defecto, la de su encabezado)")
+	data := fs.String("pow-data", "speculative", "dato base del Proof-of-Work (por defecto, el de su encabezado)")
+	powMode := fs.String("pow-mode", speculative.PowModeHex, "cómo se interpreta difficulty: hex, bits o target (por defecto, el de su encabezado)")
+	powHash := fs.String("pow-hash", speculative.DefaultPowHash, "función de hash: sha1, sha256 o sha512 (por defecto, la de su encabezado)")
+	delimiter := fs.String("delimiter", ",", "separador de columnas del CSV (por defecto, el de su encabezado)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	content, err := speculative.ReadMetricsFile(*input)
+	if err != nil {
+		return err
+	}
+	if err := applyHeaderValues(fs, content); err != nil {
+		return fmt.Errorf("%s: %w", *input, err)
+	}
+
+	switch {
+	case *difficulty <= 0:
+		return errors.New("difficulty debe ser mayor que cero")
+	case speculative.PowHashes[*powHash] == nil:
+		return fmt.Errorf("pow-hash debe ser uno de %s", strings.Join(speculative.PowHashNames(), ", "))
+	case *powMode != speculative.PowModeHex && *powMode != speculative.PowModeBits && *powMode != speculative.PowModeTarget:
+		return fmt.Errorf("pow-mode debe ser %q, %q o %q", speculative.PowModeHex, speculative.PowModeBits, speculative.PowModeTarget)
+	case speculative.CSVDelimiters[*delimiter] == 0:
+		return errors.New(`delimiter debe ser ",", ";", "|" o "tab"`)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comma = speculative.CSVDelimiters[*delimiter]
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *input, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%s: el archivo está vacío", *input)
+	}
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{"mode", "run", "branch", "cancelled", "result_numeric", "result_detail"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("%s: falta la columna %s", *input, name)
+		}
+	}
+
+	checked, invalid := 0, 0
+	for _, record := range records[1:] {
+		cell := func(name string) string {
+			if i := columns[name]; i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		recorded, ok := strings.CutPrefix(cell("result_detail"), "hash=")
+		if !ok || recorded == "" || cell("cancelled") == "true" {
+			continue
+		}
+		checked++
+		nonce, err := strconv.Atoi(cell("result_numeric"))
+		if err != nil {
+			invalid++
+			fmt.Fprintf(w, "%s %s rama %s: nonce inválido %q\n", cell("mode"), cell("run"), cell("branch"), cell("result_numeric"))
+			continue
+		}
+		switch valid, computed := speculative.VerificarProofOfWorkHash(*data, nonce, *difficulty, *powMode, *powHash); {
+		case computed != recorded:
+			invalid++
+			fmt.Fprintf(w, "%s %s rama %s: el nonce %d produce %s, no %s\n", cell("mode"), cell("run"), cell("branch"), nonce, computed, recorded)
+		case !valid:
+			invalid++
+			fmt.Fprintf(w, "%s %s rama %s: el hash %s no cumple difficulty %d (%s)\n", cell("mode"), cell("run"), cell("branch"), computed, *difficulty, *powMode)
+		}
+	}
+
+	fmt.Fprintf(w, "Hashes verificados: %d (inválidos: %d)\n", checked, invalid)
+	if invalid > 0 {
+		return fmt.Errorf("%d de %d hashes no son válidos", invalid, checked)
+	}
+	return nil
+}
+
+// applyHeaderValues asigna a las flags de verifyFlags que no se indicaron en la línea de comandos
+// el valor registrado en la configuración del encabezado "# {...}" de content, si lo tiene. Un
+// archivo generado con -workload-spec se rechaza, porque sus ramas no usan esos parámetros.
+func applyHeaderValues(fs *flag.FlagSet, content []byte) error {
+	config, err := headerConfig(content)
+	if err != nil {
+		return err
+	}
+	if spec, err := configFlagValue(config["workload-spec"]); err == nil && spec != "" {
+		return errors.New("verify no admite archivos generados con -workload-spec")
+	}
+	return setFlagsFromHeader(fs, config, verifyFlags)
+}
+
+// headerConfig devuelve la configuración registrada en el encabezado "# {...}" de content, o nil
+// si el archivo no lo tiene.
+func headerConfig(content []byte) (map[string]json.RawMessage, error) {
+	line, _, _ := bytes.Cut(content, []byte("\n"))
+	encoded, ok := bytes.CutPrefix(line, []byte("# "))
+	if !ok {
+		return nil, nil
+	}
+	var header struct {
+		Config map[string]json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(encoded, &header); err != nil {
+		return nil, fmt.Errorf("encabezado inválido: %w", err)
+	}
+	return header.Config, nil
+}
+
+// setFlagsFromHeader asigna a cada flag de names que no se indicó en la línea de comandos el valor
+// de config, si lo tiene.
+func setFlagsFromHeader(fs *flag.FlagSet, config map[string]json.RawMessage, names []string) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for _, name := range names {
+		raw, ok := config[name]
+		if !ok || explicit[name] {
+			continue
+		}
+		value, err := configFlagValue(raw)
+		if err != nil {
+			return fmt.Errorf("encabezado: clave %q: %w", name, err)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("encabezado: clave %q: %w", name, err)
+		}
+	}
+	return nil
+}
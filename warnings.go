@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"tarea02/speculative"
+)
+
+// Códigos estables de las advertencias, pensados para que los procesos automatizados filtren por
+// ellos con -warnings-json.
+const (
+	warnThermalThrottling = "thermal_throttling"
+	warnOutputTruncated   = "output_truncated"
+	warnWastedWork        = "wasted_work"
+	warnBranchTimeout     = "branch_timeout"
+)
+
+// warningRecord es la forma JSON de una advertencia: una por línea en stderr.
+type warningRecord struct {
+	Level   string         `json:"level"`
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// warn emite una advertencia en stderr: como texto libre ("warning: ...") o, con -warnings-json,
+// como un objeto JSON con su código y los campos relevantes.
+func warn(cfg speculative.Config, code, message string, fields map[string]any) {
+	if cfg.WarningsJSON {
+		encoded, err := json.Marshal(warningRecord{Level: "warning", Code: code, Message: message, Fields: fields})
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "%s\n", encoded)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s\n", message)
+}